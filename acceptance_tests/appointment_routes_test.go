@@ -73,8 +73,8 @@ var _ = Describe("Appointment Routes", func() {
 			Expect(res.StatusCode).To(Equal(http.StatusCreated))
 			Expect(a.ID).To(BeNumerically(">", 0))
 			Expect(a.ScheduleID).To(Equal(scheduleID))
-			Expect(a.StartTime).To(Equal(5))
-			Expect(a.EndTime).To(Equal(9))
+			Expect(a.StartTime).To(Equal(scheduler.UnixTimestamp(5)))
+			Expect(a.EndTime).To(Equal(scheduler.UnixTimestamp(9)))
 
 			// Send additional request to confirm ID is being incremented
 			reqBody = []byte(`
@@ -153,16 +153,16 @@ var _ = Describe("Schedule Routes", func() {
 			// Create appointments
 			appointments := []scheduler.Appointment{
 				scheduler.Appointment{
-					StartTime: 10,
-					EndTime:   12,
+					StartTime: scheduler.UnixTimestamp(10),
+					EndTime:   scheduler.UnixTimestamp(12),
 				},
 				scheduler.Appointment{
-					StartTime: 1,
-					EndTime:   3,
+					StartTime: scheduler.UnixTimestamp(1),
+					EndTime:   scheduler.UnixTimestamp(3),
 				},
 				scheduler.Appointment{
-					StartTime: 5,
-					EndTime:   9,
+					StartTime: scheduler.UnixTimestamp(5),
+					EndTime:   scheduler.UnixTimestamp(9),
 				},
 			}
 
@@ -196,9 +196,9 @@ var _ = Describe("Schedule Routes", func() {
 
 			Expect(res.StatusCode).To(Equal(http.StatusOK))
 			// Check for start times from created appointments
-			Expect(foundSchedule.Appointments[0].StartTime).To(Equal(1))
-			Expect(foundSchedule.Appointments[1].StartTime).To(Equal(5))
-			Expect(foundSchedule.Appointments[2].StartTime).To(Equal(10))
+			Expect(foundSchedule.Appointments[0].StartTime).To(Equal(scheduler.UnixTimestamp(1)))
+			Expect(foundSchedule.Appointments[1].StartTime).To(Equal(scheduler.UnixTimestamp(5)))
+			Expect(foundSchedule.Appointments[2].StartTime).To(Equal(scheduler.UnixTimestamp(10)))
 		})
 
 		It("Should return a bad request for a non-numeric scheduleID", func() {
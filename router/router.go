@@ -8,7 +8,7 @@ import (
 	"github.com/go-chi/chi/middleware"
 )
 
-func InitializeRouter() *chi.Mux {
+func InitializeRouter(h *scheduler.Handler) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
@@ -16,12 +16,47 @@ func InitializeRouter() *chi.Mux {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
 
-	r.Post("/schedules", scheduler.CreateScheduleHandler)
-	r.Get("/schedules/{scheduleID}", scheduler.ScheduleDetailsHandler)
-	r.Delete("/schedules/{scheduleID}", scheduler.DeleteScheduleHandler)
+	r.Get("/schedules", h.ListSchedulesHandler)
+	r.Post("/schedules", h.Idempotent(h.CreateScheduleHandler))
+	r.Get("/schedules/{scheduleID}", h.ScheduleDetailsHandler)
+	r.Put("/schedules/{scheduleID}", h.UpdateScheduleHandler)
+	r.Patch("/schedules/{scheduleID}", h.PatchScheduleHandler)
+	r.Delete("/schedules/{scheduleID}", h.DeleteScheduleHandler)
+	r.Get("/schedules/{scheduleID}/slots", h.FindSlotsHandler)
+	r.Post("/schedules/{scheduleID}/blackouts", h.CreateScheduleBlackoutHandler)
+	r.Delete("/schedules/{scheduleID}/blackouts/{blackoutID}", h.DeleteScheduleBlackoutHandler)
+	r.Post("/schedules/{scheduleID}/holds", h.CreateHoldHandler)
+	r.Get("/schedules/{scheduleID}/holds/{holdID}", h.HoldDetailsHandler)
+	r.Delete("/schedules/{scheduleID}/holds/{holdID}", h.DeleteHoldHandler)
+	r.Post("/schedules/{scheduleID}/holds/{holdID}/book", h.BookHoldHandler)
 
-	r.Post("/schedules/{scheduleID}/appointments", scheduler.CreateAppointmentHandler)
-	r.Get("/schedules/{scheduleID}/appointments/{appointmentID}", scheduler.AppointmentDetailsHandler)
-	r.Delete("/schedules/{scheduleID}/appointments/{appointmentID}", scheduler.DeleteAppointmentHandler)
+	r.Get("/schedules/{scheduleID}/appointments", h.ListAppointmentsHandler)
+	r.Post("/schedules/{scheduleID}/appointments", h.Idempotent(h.CreateAppointmentHandler))
+	r.Get("/schedules/{scheduleID}/appointments/{appointmentID}", h.AppointmentDetailsHandler)
+	r.Put("/schedules/{scheduleID}/appointments/{appointmentID}", h.UpdateAppointmentHandler)
+	r.Patch("/schedules/{scheduleID}/appointments/{appointmentID}", h.PatchAppointmentHandler)
+	r.Delete("/schedules/{scheduleID}/appointments/{appointmentID}", h.DeleteAppointmentHandler)
+	r.Post("/schedules/{scheduleID}/appointments/{appointmentID}/move", h.MoveAppointmentHandler)
+	r.Post("/schedules/{scheduleID}/appointments/{appointmentID}/confirm", h.ConfirmAppointmentHandler)
+	r.Post("/schedules/{scheduleID}/appointments/{appointmentID}/complete", h.CompleteAppointmentHandler)
+	r.Post("/schedules/{scheduleID}/appointments/{appointmentID}/no-show", h.NoShowAppointmentHandler)
+	r.Post("/schedules/{scheduleID}/appointments/{appointmentID}/cancel", h.CancelAppointmentHandler)
+
+	r.Delete("/schedules/{scheduleID}/appointments/{appointmentID}/occurrences/{occurrence}", h.SkipOccurrenceHandler)
+	r.Put("/schedules/{scheduleID}/appointments/{appointmentID}/occurrences/{occurrence}", h.ModifyOccurrenceHandler)
+	r.Post("/schedules/{scheduleID}/appointments/{appointmentID}/occurrences/{occurrence}/split", h.SplitSeriesHandler)
+
+	r.Post("/meetings", h.CreateMeetingHandler)
+	r.Get("/meetings/{meetingID}", h.MeetingDetailsHandler)
+	r.Delete("/meetings/{meetingID}", h.DeleteMeetingHandler)
+
+	r.Post("/calendars", h.CreateCalendarHandler)
+	r.Get("/calendars/{calendarID}", h.CalendarDetailsHandler)
+	r.Delete("/calendars/{calendarID}", h.DeleteCalendarHandler)
+	r.Post("/calendars/{calendarID}/blackouts", h.CreateCalendarBlackoutHandler)
+	r.Delete("/calendars/{calendarID}/blackouts/{blackoutID}", h.DeleteCalendarBlackoutHandler)
+
+	r.Get("/freebusy", h.FreeBusyHandler)
+	r.Get("/availability", h.CommonAvailabilityHandler)
 	return r
 }
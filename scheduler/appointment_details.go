@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const (
+	maxTitleLength          = 200
+	maxLocationLength       = 200
+	maxDescriptionLength    = 4000
+	maxAttributes           = 50
+	maxAttributeKeyLength   = 64
+	maxAttributeValueLength = 500
+)
+
+// AppointmentDetails describes what an appointment is for. Attributes holds
+// any further information clients want to keep with it, as JSON values
+// listings can be filtered by.
+type AppointmentDetails struct {
+	Title       string                     `json:"title,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Location    string                     `json:"location,omitempty"`
+	Attributes  map[string]json.RawMessage `json:"attributes,omitempty"`
+}
+
+// merge returns d with the members set on changes replacing its own.
+func (d AppointmentDetails) merge(changes AppointmentDetails) AppointmentDetails {
+	if changes.Title != "" {
+		d.Title = changes.Title
+	}
+	if changes.Description != "" {
+		d.Description = changes.Description
+	}
+	if changes.Location != "" {
+		d.Location = changes.Location
+	}
+	if changes.Attributes != nil {
+		d.Attributes = changes.Attributes
+	}
+
+	return d
+}
+
+// matches reports whether, for every key of filter, d has an attribute
+// holding one of its values. A string attribute holds its text; any other
+// holds its JSON encoding, such as 3 or true.
+func (d AppointmentDetails) matches(filter map[string][]string) bool {
+	for key, values := range filter {
+		raw, found := d.Attributes[key]
+		if !found {
+			return false
+		}
+
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			value = string(compactJSON(raw))
+		}
+
+		matched := false
+		for _, v := range values {
+			matched = matched || v == value
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkDetails enforces the size limits on d.
+func checkDetails(d AppointmentDetails) error {
+	invalid := func(format string, args ...interface{}) error {
+		return http_helpers.HttpError{
+			Message:    fmt.Sprintf(format, args...),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		limit int
+	}{
+		{"title", d.Title, maxTitleLength},
+		{"description", d.Description, maxDescriptionLength},
+		{"location", d.Location, maxLocationLength},
+	} {
+		if len(field.value) > field.limit {
+			return invalid("%v must be at most %v bytes", field.name, field.limit)
+		}
+	}
+
+	if len(d.Attributes) > maxAttributes {
+		return invalid("An appointment can have at most %v attributes", maxAttributes)
+	}
+	for key, value := range d.Attributes {
+		if key == "" || len(key) > maxAttributeKeyLength {
+			return invalid("Attribute names must be between 1 and %v bytes", maxAttributeKeyLength)
+		}
+		if len(compactJSON(value)) > maxAttributeValueLength {
+			return invalid("Attribute %q must encode to at most %v bytes", key, maxAttributeValueLength)
+		}
+	}
+
+	return nil
+}
+
+// compactJSON returns raw without insignificant white space, as it is
+// encoded in responses and storage.
+func compactJSON(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+
+	return buf.Bytes()
+}
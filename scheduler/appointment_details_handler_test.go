@@ -0,0 +1,177 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Appointment Details", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+		s     Schedule
+	)
+
+	create := func(reqBody string) (*httptest.ResponseRecorder, Appointment) {
+		recorder := serve(h.CreateAppointmentHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+
+		var a Appointment
+		json.NewDecoder(recorder.Body).Decode(&a)
+		return recorder, a
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC"})
+	})
+
+	It("Should return the details from the appointment and schedule details", func() {
+		recorder, a := create(`
+			{
+				"start_time": "2019-06-03T09:00:00Z",
+				"end_time": "2019-06-03T10:00:00Z",
+				"title": "Small council",
+				"description": "Debts to the Iron Bank",
+				"location": "Red Keep",
+				"attributes": {"room": "tower of the hand", "priority": 2, "catering": {"wine": true}}
+			}
+		`)
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+		recorder = serve(h.AppointmentDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var fetched Appointment
+		json.NewDecoder(recorder.Body).Decode(&fetched)
+		Expect(fetched.AppointmentDetails).To(Equal(AppointmentDetails{
+			Title:       "Small council",
+			Description: "Debts to the Iron Bank",
+			Location:    "Red Keep",
+			Attributes: map[string]json.RawMessage{
+				"room":     json.RawMessage(`"tower of the hand"`),
+				"priority": json.RawMessage(`2`),
+				"catering": json.RawMessage(`{"wine":true}`),
+			},
+		}))
+
+		recorder = serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID))
+		var schedule ScheduleResponse
+		json.NewDecoder(recorder.Body).Decode(&schedule)
+		Expect(schedule.Appointments).To(HaveLen(1))
+		Expect(schedule.Appointments[0].AppointmentDetails).To(Equal(fetched.AppointmentDetails))
+	})
+
+	It("Should merge attribute changes from a merge patch", func() {
+		_, a := create(`
+			{
+				"start_time": "2019-06-03T09:00:00Z",
+				"end_time": "2019-06-03T10:00:00Z",
+				"title": "Small council",
+				"attributes": {"room": "tower of the hand", "priority": "high"}
+			}
+		`)
+
+		recorder := serve(h.PatchAppointmentHandler, "PATCH", "/", `{"attributes": {"priority": null, "scribe": "Pycelle"}}`,
+			"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var updated Appointment
+		json.NewDecoder(recorder.Body).Decode(&updated)
+		Expect(updated.Title).To(Equal("Small council"))
+		Expect(updated.Attributes).To(Equal(map[string]json.RawMessage{
+			"room":   json.RawMessage(`"tower of the hand"`),
+			"scribe": json.RawMessage(`"Pycelle"`),
+		}))
+	})
+
+	It("Should reject details over the size limits", func() {
+		for _, details := range []string{
+			fmt.Sprintf(`"title": %q`, strings.Repeat("a", 201)),
+			fmt.Sprintf(`"description": %q`, strings.Repeat("a", 4001)),
+			fmt.Sprintf(`"attributes": {%q: "x"}`, strings.Repeat("k", 65)),
+			fmt.Sprintf(`"attributes": {"room": %q}`, strings.Repeat("a", 501)),
+			fmt.Sprintf(`"attributes": {"room": %q}`, strings.Repeat("a", 499)),
+			fmt.Sprintf(`"attributes": {"room": {"name": %q}}`, strings.Repeat("a", 490)),
+		} {
+			recorder, _ := create(fmt.Sprintf(
+				`{"start_time": "2019-06-03T09:00:00Z", "end_time": "2019-06-03T10:00:00Z", %v}`, details,
+			))
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), details)
+		}
+
+		attributes := make(map[string]string)
+		for i := 0; i < 51; i++ {
+			attributes[strconv.Itoa(i)] = "x"
+		}
+		encoded, _ := json.Marshal(attributes)
+		recorder, _ := create(fmt.Sprintf(
+			`{"start_time": "2019-06-03T09:00:00Z", "end_time": "2019-06-03T10:00:00Z", "attributes": %s}`, encoded,
+		))
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("Should filter appointment listings by attribute values", func() {
+		for i, room := range []string{"throne room", "tower of the hand", "throne room"} {
+			start := time.Date(2019, 6, 3, 9+2*i, 0, 0, 0, time.UTC)
+			recorder, _ := create(fmt.Sprintf(
+				`{"start_time": %q, "end_time": %q, "attributes": {"room": %q, "order": "%v", "vip": %v}}`,
+				start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339), room, i, i == 1,
+			))
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+		}
+
+		list := func(filter string) []Appointment {
+			recorder := serve(h.ListAppointmentsHandler, "GET",
+				"/?from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z&"+filter, "", "scheduleID", strconv.Itoa(s.ID))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resBody struct {
+				Appointments []Appointment `json:"appointments"`
+			}
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			return resBody.Appointments
+		}
+
+		Expect(list("attributes.room=throne+room")).To(HaveLen(2))
+		Expect(list("attributes.room=throne+room&attributes.order=2")).To(HaveLen(1))
+		Expect(list("attributes.order=0&attributes.order=1")).To(HaveLen(2))
+		Expect(list("attributes.room=dungeon")).To(BeEmpty())
+		Expect(list("attributes.scribe=Pycelle")).To(BeEmpty())
+		Expect(list("attributes.vip=true")).To(HaveLen(1))
+		Expect(list("attributes.vip=false")).To(HaveLen(2))
+	})
+
+	It("Should keep the series' details on a modified occurrence", func() {
+		_, series := create(`
+			{
+				"start_time": "2019-06-03T09:00:00Z",
+				"end_time": "2019-06-03T10:00:00Z",
+				"recurrence": "FREQ=DAILY;COUNT=5",
+				"title": "Small council",
+				"location": "Red Keep"
+			}
+		`)
+
+		recorder := serve(h.ModifyOccurrenceHandler, "PUT", "/",
+			`{"start_time": "2019-06-04T11:00:00Z", "end_time": "2019-06-04T12:00:00Z", "location": "Great Sept"}`,
+			"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(series.ID), "occurrence", "2019-06-04T09:00:00Z")
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+		var replacement Appointment
+		json.NewDecoder(recorder.Body).Decode(&replacement)
+		Expect(replacement.Title).To(Equal("Small council"))
+		Expect(replacement.Location).To(Equal("Great Sept"))
+	})
+})
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	defaultAppointmentLimit = 100
+	maxAppointmentLimit     = 500
+
+	attributeParamPrefix = "attributes."
+)
+
+// AppointmentQuery selects a page of the occurrences intersecting
+// [From, To) on one schedule. Statuses and Attributes, when set, keep only
+// appointments with one of the listed statuses and holding one of the listed
+// values for every attribute named.
+type AppointmentQuery struct {
+	From       time.Time
+	To         time.Time
+	Statuses   []string
+	Attributes map[string][]string
+	After      *AppointmentCursor
+	Limit      int
+}
+
+// AppointmentCursor is the position of the last occurrence of a page.
+// Occurrences are ordered by start time and then appointment ID, which
+// together identify an occurrence.
+type AppointmentCursor struct {
+	StartTime int64 `json:"t"`
+	ID        int   `json:"i"`
+}
+
+// Encode renders the cursor as the opaque token handed to clients.
+func (c AppointmentCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAppointmentCursor(token string) (*AppointmentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	var c AppointmentCursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return &c, nil
+}
+
+// hasStatus reports whether a has one of statuses, or statuses is empty.
+func hasStatus(a Appointment, statuses []string) bool {
+	for _, status := range statuses {
+		if a.Status == status {
+			return true
+		}
+	}
+
+	return len(statuses) == 0
+}
+
+func (c AppointmentCursor) before(a Appointment) bool {
+	start := a.StartTime.Unix()
+	return c.StartTime < start || (c.StartTime == start && c.ID < a.ID)
+}
+
+// listAppointments returns one page of the occurrences on a schedule that
+// intersect the query window and, when another page follows, its cursor.
+func listAppointments(store Store, scheduleID int, q AppointmentQuery) ([]Appointment, *AppointmentCursor, error) {
+	stored, err := store.ListAppointmentsBetween(scheduleID, q.From, q.To)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var occurrences []Appointment
+	for _, a := range stored {
+		if !a.matches(q.Attributes) || !hasStatus(a, q.Statuses) {
+			continue
+		}
+		occurrences = append(occurrences, a.Occurrences(q.From, q.To)...)
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		a, b := occurrences[i], occurrences[j]
+		if !a.StartTime.Equal(b.StartTime.Time) {
+			return a.StartTime.Before(b.StartTime.Time)
+		}
+		return a.ID < b.ID
+	})
+
+	page := []Appointment{}
+	for _, a := range occurrences {
+		if q.After != nil && !q.After.before(a) {
+			continue
+		}
+		if len(page) == q.Limit {
+			last := page[len(page)-1]
+			return page, &AppointmentCursor{StartTime: last.StartTime.Unix(), ID: last.ID}, nil
+		}
+		page = append(page, a)
+	}
+
+	return page, nil, nil
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const defaultCandidateCount = 5
+
+type AvailabilityResponse struct {
+	Slots []CandidateSlot `json:"slots"`
+}
+
+// CommonAvailabilityHandler searches for slots shared by the schedules listed
+// in schedule_ids. It accepts the query parameters of FindSlotsHandler plus
+// quorum, which defaults to all of the schedules.
+func (h *Handler) CommonAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleIDs, err := parseScheduleIDs(r.URL.Query().Get("schedule_ids"))
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slotQuery, err := parseSlotQuery(r, defaultCandidateCount)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q := AvailabilityQuery{SlotQuery: slotQuery, ScheduleIDs: scheduleIDs, Quorum: len(scheduleIDs)}
+	if value := r.URL.Query().Get("quorum"); value != "" {
+		q.Quorum, err = strconv.Atoi(value)
+		if err != nil || q.Quorum < 1 || q.Quorum > len(scheduleIDs) {
+			http_helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("quorum must be between 1 and %v", len(scheduleIDs)))
+			return
+		}
+	}
+
+	slots, err := findCommonSlots(h.Store, q)
+	if err != nil {
+		log.Println("CommonAvailabilityHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to find common availability")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, AvailabilityResponse{Slots: slots})
+}
@@ -0,0 +1,145 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Common Availability Handler", func() {
+	var (
+		store  *MemoryStore
+		h      *Handler
+		tyrion Schedule
+		arya   Schedule
+		day    time.Time
+	)
+
+	type candidate struct {
+		Start         string `json:"start"`
+		End           string `json:"end"`
+		Available     []int  `json:"available_schedule_ids"`
+		Fragmentation int    `json:"fragmentation"`
+	}
+
+	search := func(params string) (*httptest.ResponseRecorder, []candidate) {
+		recorder := serve(h.CommonAvailabilityHandler, "GET", "/availability?"+params, "")
+
+		var resBody struct {
+			Slots []candidate `json:"slots"`
+		}
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		return recorder, resBody.Slots
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		tyrion, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		arya, _ = store.CreateSchedule(Schedule{OwnerName: "Arya Stark"})
+		day = time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC)
+
+		bookAppointment(store, tyrion, day.Add(9*time.Hour), time.Hour)
+		bookAppointment(store, arya, day.Add(10*time.Hour+30*time.Minute), time.Hour)
+	})
+
+	It("Should rank slots where everyone is free by least fragmentation within the earliest day", func() {
+		recorder, slots := search(fmt.Sprintf(
+			"schedule_ids=%v,%v&duration=1h&granularity=30m&earliest_start=09:00&latest_end=17:00&count=3&from=2019-06-03T00:00:00Z&to=2019-06-05T00:00:00Z",
+			tyrion.ID, arya.ID,
+		))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]candidate{
+			{Start: "2019-06-03T16:00:00Z", End: "2019-06-03T17:00:00Z", Available: []int{tyrion.ID, arya.ID}, Fragmentation: 2},
+			{Start: "2019-06-03T12:00:00Z", End: "2019-06-03T13:00:00Z", Available: []int{tyrion.ID, arya.ID}, Fragmentation: 3},
+			{Start: "2019-06-03T12:30:00Z", End: "2019-06-03T13:30:00Z", Available: []int{tyrion.ID, arya.ID}, Fragmentation: 4},
+		}))
+	})
+
+	It("Should only count schedules as free within their working hours", func() {
+		arya.WorkingHours = &WorkingHours{Weekly: map[string][]TimeRange{
+			"monday": {{Start: TimeOfDay{Hour: 14}, End: TimeOfDay{Hour: 16}}},
+		}}
+		store.UpdateSchedule(arya)
+
+		recorder, slots := search(fmt.Sprintf(
+			"schedule_ids=%v,%v&duration=1h&granularity=30m&count=2&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z",
+			tyrion.ID, arya.ID,
+		))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]candidate{
+			{Start: "2019-06-03T14:00:00Z", End: "2019-06-03T15:00:00Z", Available: []int{tyrion.ID, arya.ID}, Fragmentation: 3},
+			{Start: "2019-06-03T15:00:00Z", End: "2019-06-03T16:00:00Z", Available: []int{tyrion.ID, arya.ID}, Fragmentation: 3},
+		}))
+	})
+
+	It("Should offer slots where only a quorum of the schedules is free", func() {
+		recorder, slots := search(fmt.Sprintf(
+			"schedule_ids=%v,%v&duration=1h&granularity=30m&quorum=1&from=2019-06-03T09:00:00Z&to=2019-06-03T12:00:00Z",
+			tyrion.ID, arya.ID,
+		))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots[0]).To(Equal(candidate{
+			Start: "2019-06-03T09:00:00Z", End: "2019-06-03T10:00:00Z", Available: []int{arya.ID}, Fragmentation: 0,
+		}))
+		for _, slot := range slots {
+			Expect(slot.Available).To(HaveLen(1))
+		}
+	})
+
+	It("Should only count schedules as free for slots their booking policy allows", func() {
+		tyrion.BookingPolicy = BookingPolicy{MinDurationMinutes: 90}
+		store.UpdateSchedule(tyrion)
+
+		recorder, slots := search(fmt.Sprintf(
+			"schedule_ids=%v,%v&duration=1h&granularity=30m&quorum=1&count=10&from=2019-06-03T12:00:00Z&to=2019-06-03T18:00:00Z",
+			tyrion.ID, arya.ID,
+		))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).NotTo(BeEmpty())
+		for _, slot := range slots {
+			Expect(slot.Available).To(Equal([]int{arya.ID}))
+		}
+	})
+
+	It("Should return no slots when the schedules are never free together", func() {
+		bookAppointment(store, arya, day.Add(11*time.Hour+30*time.Minute), 12*time.Hour)
+
+		recorder, slots := search(fmt.Sprintf(
+			"schedule_ids=%v,%v&duration=1h&from=2019-06-03T09:00:00Z&to=2019-06-03T23:00:00Z",
+			tyrion.ID, arya.ID,
+		))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]candidate{}))
+	})
+
+	It("Should return a StatusNotFound if any schedule is missing", func() {
+		recorder, _ := search(fmt.Sprintf("schedule_ids=%v,32&duration=1h&from=2019-06-03T09:00:00Z&to=2019-06-03T23:00:00Z", tyrion.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should return a StatusBadRequest for an invalid quorum", func() {
+		for _, quorum := range []string{"0", "3", "blamo"} {
+			recorder, _ := search(fmt.Sprintf(
+				"schedule_ids=%v,%v&duration=1h&quorum=%v&from=2019-06-03T09:00:00Z&to=2019-06-03T23:00:00Z",
+				tyrion.ID, arya.ID, quorum,
+			))
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest), quorum)
+		}
+	})
+})
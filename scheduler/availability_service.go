@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// AvailabilityQuery searches for slots shared by several schedules. The first
+// schedule is the organizer's: its time zone anchors days, alignment and the
+// daily EarliestStart/LatestEnd bounds.
+type AvailabilityQuery struct {
+	SlotQuery
+	ScheduleIDs []int
+
+	// Quorum is how many of the schedules must be free for a slot to be
+	// offered.
+	Quorum int
+}
+
+// CandidateSlot is a slot in which at least a quorum of schedules is free.
+// Fragmentation counts the free periods the slot would leave behind on those
+// schedules that are too short to be useful, as defined by findCommonSlots;
+// lower is better.
+type CandidateSlot struct {
+	Interval
+	Available     []int `json:"available_schedule_ids"`
+	Fragmentation int   `json:"fragmentation"`
+}
+
+// findCommonSlots returns up to q.Count candidate slots. Candidates are ranked
+// by the organizer's calendar day they start on, then by least
+// fragmentation, then by start.
+//
+// Each schedule is only free for slots its booking policy allows; the
+// organizer's policy supplies the default granularity.
+//
+// A slot leaves a fragment on a schedule when there is at least Granularity
+// of free time between it and the neighbouring busy period (or the edge of
+// the window, of the daily bounds or of working hours) on either side. A slot
+// placed snugly against existing appointments therefore scores 0 on that
+// schedule, while one dropped in the middle of a free afternoon scores 2.
+func findCommonSlots(store Store, q AvailabilityQuery) ([]CandidateSlot, error) {
+	var schedules []Schedule
+	var busy, available [][]Interval
+	for _, id := range q.ScheduleIDs {
+		// Widened so that appointments touching the window or reaching it
+		// through buffers count, as they do in ValidateAppointmentInput.
+		s, err := store.GetScheduleBetween(id, q.From.Add(-maxReach-time.Second), q.To.Add(maxReach+time.Second))
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+
+		margin := s.OverlapPolicy.reach() + time.Second
+		busy = append(busy, scheduleBusy(s, q.From.Add(-margin), q.To.Add(margin)))
+		available = append(available, s.available(q.From, q.To))
+	}
+
+	loc := schedules[0].Location()
+	now := time.Now()
+	locations := make([]*time.Location, len(schedules))
+	for i, s := range schedules {
+		locations[i] = s.Location()
+	}
+	q.Granularity = schedules[0].BookingPolicy.slotGranularity(q.Granularity)
+	align := func(t time.Time) time.Time {
+		return alignTime(t, loc, q.Granularity)
+	}
+
+	results := []CandidateSlot{}
+	var day time.Time
+	var sameDay []CandidateSlot
+	flush := func() {
+		sort.SliceStable(sameDay, func(i, j int) bool {
+			return sameDay[i].Fragmentation < sameDay[j].Fragmentation
+		})
+		for _, c := range sameDay {
+			if len(results) == q.Count {
+				break
+			}
+			results = append(results, c)
+		}
+		sameDay = nil
+	}
+
+	next, nextAvailable := make([]int, len(schedules)), make([]int, len(schedules))
+	for start := align(q.From); len(results) < q.Count; start = align(start.Add(q.Granularity)) {
+		end := start.Add(q.Duration)
+		if end.After(q.To) {
+			break
+		}
+
+		if midnight := (TimeOfDay{}).On(start); !midnight.Equal(day) {
+			flush()
+			day = midnight
+		}
+
+		lower, upper := q.From, q.To
+		if q.EarliestStart != nil {
+			if bound := q.EarliestStart.On(start); start.Before(bound) {
+				continue
+			} else if bound.After(lower) {
+				lower = bound
+			}
+		}
+		if q.LatestEnd != nil {
+			if bound := q.LatestEnd.On(start); end.After(bound) {
+				continue
+			} else if bound.Before(upper) {
+				upper = bound
+			}
+		}
+
+		candidate := CandidateSlot{
+			Interval:  Interval{Start: NewTimestamp(start), End: NewTimestamp(end.In(loc))},
+			Available: []int{},
+		}
+		for i, s := range schedules {
+			if len(s.BookingPolicy.violations(candidate.Interval, locations[i], now)) > 0 {
+				continue
+			}
+
+			hours := available[i]
+			for nextAvailable[i] < len(hours) && hours[nextAvailable[i]].End.Before(end) {
+				nextAvailable[i]++
+			}
+			if nextAvailable[i] == len(hours) || start.Before(hours[nextAvailable[i]].Start.Time) {
+				continue
+			}
+
+			b, p := busy[i], s.OverlapPolicy
+			blocked := p.blocked(candidate.Interval)
+			for next[i] < len(b) && !blocked.Start.Before(p.freeFrom(b[next[i]])) {
+				next[i]++
+			}
+			if next[i] < len(b) && p.conflicts(b[next[i]], blocked) {
+				continue
+			}
+			candidate.Available = append(candidate.Available, s.ID)
+
+			// The earliest start and latest end the working hours and the
+			// neighbouring busy periods allow under the schedule's overlap
+			// policy.
+			earliest, latest := lower, upper
+			if bound := hours[nextAvailable[i]].Start.Time; bound.After(earliest) {
+				earliest = bound
+			}
+			if bound := hours[nextAvailable[i]].End.Time; bound.Before(latest) {
+				latest = bound
+			}
+			if next[i] > 0 {
+				if bound := p.freeFrom(b[next[i]-1]).Add(p.bufferBefore()); bound.After(earliest) {
+					earliest = bound
+				}
+			}
+			if next[i] < len(b) {
+				if bound := p.freeUntil(b[next[i]]).Add(-p.bufferAfter()); bound.Before(latest) {
+					latest = bound
+				}
+			}
+			if start.Sub(align(earliest)) >= q.Granularity {
+				candidate.Fragmentation++
+			}
+			if latest.Sub(end) >= q.Granularity {
+				candidate.Fragmentation++
+			}
+		}
+
+		if len(candidate.Available) >= q.Quorum {
+			sameDay = append(sameDay, candidate)
+		}
+	}
+	flush()
+
+	return results, nil
+}
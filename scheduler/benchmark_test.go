@@ -0,0 +1,132 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var benchmarkSizes = []int{1000, 10000, 50000}
+
+// benchmarkSchedule books n appointments of 20 minutes on the hour, one
+// hour apart, leaving every half past free.
+func benchmarkSchedule(b *testing.B, n int) (*MemoryStore, Schedule, time.Time) {
+	store := NewMemoryStore()
+	s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC"})
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		_, err := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(t),
+			EndTime:    NewTimestamp(t.Add(20 * time.Minute)),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	s, err := store.GetSchedule(s.ID)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return store, s, start
+}
+
+func BenchmarkValidateAppointmentInput(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			_, s, start := benchmarkSchedule(b, n)
+			middle := start.Add(time.Duration(n/2)*time.Hour + 30*time.Minute)
+			a := Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(middle),
+				EndTime:    NewTimestamp(middle.Add(20 * time.Minute)),
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateAppointmentInput(s, a); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLinearConflictScan is the baseline for
+// BenchmarkValidateAppointmentInput: it looks for conflicts with the same
+// appointment the way schedules were checked before they were indexed, by
+// expanding every appointment on them.
+func BenchmarkLinearConflictScan(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			_, s, start := benchmarkSchedule(b, n)
+			middle := start.Add(time.Duration(n/2)*time.Hour + 30*time.Minute)
+			from, to := middle, middle.Add(20*time.Minute)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, e := range s.Appointments {
+					for _, o := range e.Occurrences(from.Add(-time.Second), to.Add(time.Second)) {
+						if !o.StartTime.After(to) && !o.EndTime.Before(from) {
+							b.Fatal("unexpected conflict with appointment ", o.ID)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCreateAppointmentHandler(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			store, s, start := benchmarkSchedule(b, n)
+			h := NewHandler(store)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Free half hours are used up one by one and then rejected,
+				// so the conflict check runs either way.
+				t := start.Add(time.Duration(i%n)*time.Hour + 30*time.Minute)
+				body := fmt.Sprintf(`{"start_time": %q, "end_time": %q}`,
+					t.Format(time.RFC3339), t.Add(20*time.Minute).Format(time.RFC3339))
+
+				r, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(body)))
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+				http.HandlerFunc(h.CreateAppointmentHandler).ServeHTTP(httptest.NewRecorder(), r)
+			}
+		})
+	}
+}
+
+func BenchmarkScheduleMarshalJSON(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			_, s, _ := benchmarkSchedule(b, n)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
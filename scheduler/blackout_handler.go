@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+func (h *Handler) CreateScheduleBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	var b Blackout
+	err = json.NewDecoder(r.Body).Decode(&b)
+	if err != nil {
+		log.Println("CreateScheduleBlackoutHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	b, err = createScheduleBlackout(h.Store, b, scheduleID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("CreateScheduleBlackoutHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to create blackout")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusCreated, b)
+}
+
+func (h *Handler) DeleteScheduleBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+	blackoutID, err := convertIDParam(r, "blackoutID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid blackout ID")
+		return
+	}
+
+	b, err := deleteScheduleBlackout(h.Store, scheduleID, blackoutID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("DeleteScheduleBlackoutHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete blackout")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, b)
+}
+
+func (h *Handler) CreateCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	var c Calendar
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		log.Println("CreateCalendarHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	c, err = createCalendar(h.Store, c)
+	if err != nil {
+		log.Println("CreateCalendarHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to create calendar")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusCreated, c)
+}
+
+func (h *Handler) CalendarDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	calendarID, err := convertIDParam(r, "calendarID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid calendar ID")
+		return
+	}
+
+	c, err := h.Store.GetCalendar(calendarID)
+	if err != nil {
+		log.Println("CalendarDetailsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to retrieve calendar")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) DeleteCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	calendarID, err := convertIDParam(r, "calendarID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid calendar ID")
+		return
+	}
+
+	c, err := h.Store.DeleteCalendar(calendarID)
+	if err != nil {
+		log.Println("DeleteCalendarHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete calendar")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) CreateCalendarBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	calendarID, err := convertIDParam(r, "calendarID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid calendar ID")
+		return
+	}
+
+	var b Blackout
+	err = json.NewDecoder(r.Body).Decode(&b)
+	if err != nil {
+		log.Println("CreateCalendarBlackoutHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	b, err = createCalendarBlackout(h.Store, b, calendarID)
+	if err != nil {
+		log.Println("CreateCalendarBlackoutHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to create blackout")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusCreated, b)
+}
+
+func (h *Handler) DeleteCalendarBlackoutHandler(w http.ResponseWriter, r *http.Request) {
+	calendarID, err := convertIDParam(r, "calendarID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid calendar ID")
+		return
+	}
+	blackoutID, err := convertIDParam(r, "blackoutID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid blackout ID")
+		return
+	}
+
+	b, err := h.Store.DeleteBlackout(Blackout{ID: blackoutID, CalendarID: calendarID})
+	if err != nil {
+		log.Println("DeleteCalendarBlackoutHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete blackout")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, b)
+}
@@ -0,0 +1,270 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Blackout Handlers", func() {
+	var (
+		store  *MemoryStore
+		h      *Handler
+		tyrion Schedule
+		arya   Schedule
+	)
+
+	book := func(s Schedule, start string) *httptest.ResponseRecorder {
+		t, _ := time.Parse(time.RFC3339, start)
+		reqBody := fmt.Sprintf(`{"start_time": %q, "end_time": %q}`, start, t.Add(time.Hour).Format(time.RFC3339))
+		return serve(h.CreateAppointmentHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+	}
+
+	createCalendar := func(reqBody string) (*httptest.ResponseRecorder, Calendar) {
+		recorder := serve(h.CreateCalendarHandler, "POST", "/", reqBody)
+
+		var c Calendar
+		json.NewDecoder(recorder.Body).Decode(&c)
+		return recorder, c
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		tyrion, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		arya, _ = store.CreateSchedule(Schedule{OwnerName: "Arya Stark", TimeZone: "UTC"})
+	})
+
+	Context("Schedule blackouts", func() {
+		createBlackout := func(s Schedule, reqBody string) (*httptest.ResponseRecorder, Blackout) {
+			recorder := serve(h.CreateScheduleBlackoutHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+
+			var b Blackout
+			json.NewDecoder(recorder.Body).Decode(&b)
+			return recorder, b
+		}
+
+		It("Should block appointments during the blackout and explain why", func() {
+			recorder, b := createBlackout(tyrion, `
+				{
+					"start_time": "2019-06-10T00:00:00Z",
+					"end_time": "2019-06-15T00:00:00Z",
+					"reason": "Vacation"
+				}
+			`)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(b.ScheduleID).To(Equal(tyrion.ID))
+			Expect(b.StartTime.Format(time.RFC3339)).To(Equal("2019-06-09T18:00:00-06:00"))
+			Expect(b.Reason).To(Equal("Vacation"))
+
+			recorder = book(tyrion, "2019-06-12T15:00:00Z")
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+
+			var problem Problem
+			json.NewDecoder(recorder.Body).Decode(&problem)
+			Expect(problem.Violations).To(HaveLen(1))
+			Expect(problem.Violations[0].Rule).To(Equal(RuleNoBlackout))
+			Expect(problem.Violations[0].BlackoutIDs).To(Equal([]int{b.ID}))
+
+			Expect(book(tyrion, "2019-06-14T23:00:00Z").Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(book(tyrion, "2019-06-15T00:00:00Z").Code).To(Equal(http.StatusCreated))
+			Expect(book(arya, "2019-06-12T15:00:00Z").Code).To(Equal(http.StatusCreated))
+		})
+
+		It("Should block every occurrence of a recurring blackout", func() {
+			recorder, _ := createBlackout(tyrion, `
+				{
+					"start_time": "2019-06-03T12:00:00-06:00",
+					"end_time": "2019-06-03T13:00:00-06:00",
+					"recurrence": "FREQ=WEEKLY;BYDAY=MO,WE"
+				}
+			`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+			Expect(book(tyrion, "2019-07-10T11:30:00-06:00").Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(book(tyrion, "2019-07-09T11:30:00-06:00").Code).To(Equal(http.StatusCreated))
+		})
+
+		It("Should keep appointments already booked during a new blackout", func() {
+			Expect(book(tyrion, "2019-06-12T15:00:00Z").Code).To(Equal(http.StatusCreated))
+
+			recorder, _ := createBlackout(tyrion, `{"start_time": "2019-06-12T00:00:00Z", "end_time": "2019-06-13T00:00:00Z"}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.Appointments).To(HaveLen(1))
+			Expect(s.Blackouts).To(HaveLen(1))
+		})
+
+		It("Should lift the blackout once it is deleted", func() {
+			_, b := createBlackout(tyrion, `{"start_time": "2019-06-12T00:00:00Z", "end_time": "2019-06-13T00:00:00Z"}`)
+
+			recorder := serve(h.DeleteScheduleBlackoutHandler, "DELETE", "/", "",
+				"scheduleID", strconv.Itoa(tyrion.ID), "blackoutID", strconv.Itoa(b.ID))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(book(tyrion, "2019-06-12T15:00:00Z").Code).To(Equal(http.StatusCreated))
+
+			recorder = serve(h.DeleteScheduleBlackoutHandler, "DELETE", "/", "",
+				"scheduleID", strconv.Itoa(tyrion.ID), "blackoutID", strconv.Itoa(b.ID))
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should change nothing when If-Match does not list the schedule's ETag", func() {
+			reqBody := `{"start_time": "2019-06-12T00:00:00Z", "end_time": "2019-06-13T00:00:00Z"}`
+			recorder := serveWithHeaders(h.CreateScheduleBlackoutHandler, "POST", "/", reqBody, map[string]string{"If-Match": `"42"`},
+				"scheduleID", strconv.Itoa(tyrion.ID))
+			Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+
+			etag := serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(tyrion.ID)).Header().Get("ETag")
+			recorder = serveWithHeaders(h.CreateScheduleBlackoutHandler, "POST", "/", reqBody, map[string]string{"If-Match": etag},
+				"scheduleID", strconv.Itoa(tyrion.ID))
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			var b Blackout
+			json.NewDecoder(recorder.Body).Decode(&b)
+
+			recorder = serveWithHeaders(h.DeleteScheduleBlackoutHandler, "DELETE", "/", "", map[string]string{"If-Match": etag},
+				"scheduleID", strconv.Itoa(tyrion.ID), "blackoutID", strconv.Itoa(b.ID))
+			Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.Blackouts).To(HaveLen(1))
+		})
+
+		It("Should reject invalid blackouts", func() {
+			for _, reqBody := range []string{
+				`{"start_time": "2019-06-13T00:00:00Z", "end_time": "2019-06-12T00:00:00Z"}`,
+				`{"end_time": "2019-06-12T00:00:00Z"}`,
+				fmt.Sprintf(`{"start_time": "2019-06-12T00:00:00Z", "end_time": "2019-06-13T00:00:00Z", "reason": %q}`,
+					string(bytes.Repeat([]byte("a"), 501))),
+			} {
+				recorder, _ := createBlackout(tyrion, reqBody)
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), reqBody)
+			}
+
+			recorder, _ := createBlackout(tyrion, `{"start_time": "nope"}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+			recorder, _ = createBlackout(Schedule{ID: 32}, `{"start_time": "2019-06-12T00:00:00Z", "end_time": "2019-06-13T00:00:00Z"}`)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Holiday calendars", func() {
+		var westeros Calendar
+
+		createBlackout := func(c Calendar, reqBody string) (*httptest.ResponseRecorder, Blackout) {
+			recorder := serve(h.CreateCalendarBlackoutHandler, "POST", "/", reqBody, "calendarID", strconv.Itoa(c.ID))
+
+			var b Blackout
+			json.NewDecoder(recorder.Body).Decode(&b)
+			return recorder, b
+		}
+
+		subscribe := func(s Schedule, calendarIDs string) *httptest.ResponseRecorder {
+			return serve(h.PatchScheduleHandler, "PATCH", "/", fmt.Sprintf(`{"calendar_ids": %v}`, calendarIDs),
+				"scheduleID", strconv.Itoa(s.ID))
+		}
+
+		BeforeEach(func() {
+			var recorder *httptest.ResponseRecorder
+			recorder, westeros = createCalendar(`{"name": "Westeros", "time_zone": "America/Denver"}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+		})
+
+		It("Should block appointments on every subscribed schedule", func() {
+			recorder, b := createBlackout(westeros, `
+				{
+					"start_time": "2019-12-25T00:00:00-07:00",
+					"end_time": "2019-12-26T00:00:00-07:00",
+					"recurrence": "FREQ=YEARLY",
+					"reason": "Winter festival"
+				}
+			`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(b.CalendarID).To(Equal(westeros.ID))
+
+			Expect(subscribe(tyrion, fmt.Sprintf("[%v]", westeros.ID)).Code).To(Equal(http.StatusOK))
+			Expect(book(tyrion, "2020-12-25T10:00:00-07:00").Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(book(tyrion, "2020-12-25T06:00:00Z").Code).To(Equal(http.StatusCreated))
+			Expect(book(arya, "2020-12-25T17:00:00Z").Code).To(Equal(http.StatusCreated))
+
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.CalendarIDs).To(Equal([]int{westeros.ID}))
+			Expect(s.Blackouts).To(HaveLen(1))
+			Expect(s.Blackouts[0].Reason).To(Equal("Winter festival"))
+
+			recorder = serve(h.CalendarDetailsHandler, "GET", "/", "", "calendarID", strconv.Itoa(westeros.ID))
+			var c Calendar
+			json.NewDecoder(recorder.Body).Decode(&c)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(c.Blackouts).To(HaveLen(1))
+			Expect(c.Blackouts[0].ID).To(Equal(b.ID))
+		})
+
+		It("Should stop blocking appointments once the blackout or calendar is deleted", func() {
+			subscribe(tyrion, fmt.Sprintf("[%v]", westeros.ID))
+			_, b := createBlackout(westeros, `{"start_time": "2019-12-25T00:00:00-07:00", "end_time": "2019-12-26T00:00:00-07:00"}`)
+			createBlackout(westeros, `{"start_time": "2019-12-31T00:00:00-07:00", "end_time": "2020-01-01T00:00:00-07:00"}`)
+
+			recorder := serve(h.DeleteCalendarBlackoutHandler, "DELETE", "/", "",
+				"calendarID", strconv.Itoa(westeros.ID), "blackoutID", strconv.Itoa(b.ID))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(book(tyrion, "2019-12-25T10:00:00-07:00").Code).To(Equal(http.StatusCreated))
+			Expect(book(tyrion, "2019-12-31T10:00:00-07:00").Code).To(Equal(http.StatusUnprocessableEntity))
+
+			recorder = serve(h.DeleteCalendarHandler, "DELETE", "/", "", "calendarID", strconv.Itoa(westeros.ID))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(book(tyrion, "2019-12-31T10:00:00-07:00").Code).To(Equal(http.StatusCreated))
+
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.CalendarIDs).To(BeEmpty())
+
+			recorder = serve(h.CalendarDetailsHandler, "GET", "/", "", "calendarID", strconv.Itoa(westeros.ID))
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should subscribe schedules when they are created", func() {
+			recorder := serve(h.CreateScheduleHandler, "POST", "/",
+				fmt.Sprintf(`{"owner_name": "Sansa Stark", "calendar_ids": [%v, %v]}`, westeros.ID, westeros.ID))
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+			var resBody ScheduleResponse
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.CalendarIDs).To(Equal([]int{westeros.ID}))
+
+			recorder = serve(h.CreateScheduleHandler, "POST", "/", `{"owner_name": "Sansa Stark", "calendar_ids": [32]}`)
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(subscribe(tyrion, "[32]").Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		It("Should default the time zone and reject invalid calendars", func() {
+			recorder, c := createCalendar(`{"name": "The North"}`)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(c.TimeZone).To(Equal("UTC"))
+			Expect(c.Blackouts).To(BeEmpty())
+
+			for _, reqBody := range []string{
+				`{"time_zone": "UTC"}`,
+				`{"name": "The North", "time_zone": "Winterfell"}`,
+				`{"name": "The North", "blackouts": [{"start_time": "2019-12-25T00:00:00Z", "end_time": "2019-12-26T00:00:00Z"}]}`,
+			} {
+				recorder, _ = createCalendar(reqBody)
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), reqBody)
+			}
+
+			recorder, _ = createBlackout(Calendar{ID: 32}, `{"start_time": "2019-12-25T00:00:00Z", "end_time": "2019-12-26T00:00:00Z"}`)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
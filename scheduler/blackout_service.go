@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const maxBlackoutReasonLength = 500
+
+// Blackout closes a schedule for a period of time, once or repeatedly.
+// Blackouts belong either to a schedule or to a holiday calendar, in which
+// case they apply to every schedule subscribed to it. Unlike appointments,
+// blackouts are half-open: an appointment may end exactly when one starts.
+type Blackout struct {
+	ID         int             `json:"id"`
+	ScheduleID int             `json:"schedule_id,omitempty"`
+	CalendarID int             `json:"calendar_id,omitempty"`
+	StartTime  Timestamp       `json:"start_time"`
+	EndTime    Timestamp       `json:"end_time"`
+	Recurrence *RecurrenceRule `json:"recurrence,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+}
+
+// Calendar is a set of blackouts shared between schedules, such as an
+// organisation's public holidays. Recurring blackouts keep their wall-clock
+// times in the calendar's time zone.
+type Calendar struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	TimeZone  string     `json:"time_zone"`
+	Blackouts []Blackout `json:"blackouts"`
+}
+
+func (c Calendar) Location() *time.Location {
+	return Schedule{TimeZone: c.TimeZone}.Location()
+}
+
+// occurrences expands b into its occurrences intersecting [from, to).
+func (b Blackout) occurrences(from, to time.Time) []Appointment {
+	return Appointment{ID: b.ID, StartTime: b.StartTime, EndTime: b.EndTime, Recurrence: b.Recurrence}.Occurrences(from, to)
+}
+
+// blackedOut returns the merged times within reach of [from, to) covered by
+// the blackouts that apply to s.
+func (s Schedule) blackedOut(from, to time.Time) []Interval {
+	var intervals []Interval
+	for _, b := range s.Blackouts {
+		for _, o := range b.occurrences(from, to) {
+			intervals = append(intervals, o.interval())
+		}
+	}
+
+	return mergeIntervals(intervals)
+}
+
+// blackoutsOverlapping returns the IDs of the blackouts that any of proposed,
+// which lie within [from, to), overlaps.
+func blackoutsOverlapping(s Schedule, proposed []Appointment, from, to time.Time) []int {
+	var ids []int
+	for _, b := range s.Blackouts {
+		occurrences := b.occurrences(from, to)
+		for _, o := range proposed {
+			// Occurrences share a duration, so they are ordered by end too.
+			k := sort.Search(len(occurrences), func(k int) bool {
+				return occurrences[k].EndTime.After(o.StartTime.Time)
+			})
+			if k < len(occurrences) && occurrences[k].StartTime.Before(o.EndTime.Time) {
+				ids = append(ids, b.ID)
+				break
+			}
+		}
+	}
+
+	return ids
+}
+
+// subtractIntervals removes the sorted, merged intervals of cut from those of
+// from.
+func subtractIntervals(from, cut []Interval) []Interval {
+	var remaining []Interval
+	next := 0
+	for _, i := range from {
+		start := i.Start
+		for next < len(cut) && !cut[next].End.After(start.Time) {
+			next++
+		}
+		for k := next; k < len(cut) && cut[k].Start.Before(i.End.Time); k++ {
+			if cut[k].Start.After(start.Time) {
+				remaining = append(remaining, Interval{Start: start, End: cut[k].Start})
+			}
+			if cut[k].End.After(start.Time) {
+				start = cut[k].End
+			}
+		}
+		if start.Before(i.End.Time) {
+			remaining = append(remaining, Interval{Start: start, End: i.End})
+		}
+	}
+
+	return remaining
+}
+
+func createCalendar(store Store, c Calendar) (Calendar, error) {
+	if c.Name == "" {
+		return c, http_helpers.HttpError{
+			Message:    "name is required",
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+	if len(c.Blackouts) > 0 {
+		return c, http_helpers.HttpError{
+			Message:    "Blackouts are added to a calendar separately",
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	s := Schedule{TimeZone: c.TimeZone}
+	if err := checkTimeZone(&s); err != nil {
+		return c, err
+	}
+	c.TimeZone = s.TimeZone
+
+	return store.CreateCalendar(c)
+}
+
+// createScheduleBlackout adds a blackout to a schedule. Appointments already
+// booked during it are kept.
+func createScheduleBlackout(store Store, b Blackout, scheduleID int, ifMatch string) (created Blackout, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		s, err := scheduleFor(tx, scheduleID)
+		if err != nil {
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		b.ScheduleID, b.CalendarID = s.ID, 0
+		if err = checkBlackout(&b, s.Location()); err != nil {
+			return err
+		}
+
+		created, err = tx.CreateBlackout(b)
+		return err
+	})
+	return
+}
+
+// deleteScheduleBlackout removes one of a schedule's own blackouts.
+func deleteScheduleBlackout(store Store, scheduleID, blackoutID int, ifMatch string) (deleted Blackout, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		s, err := scheduleFor(tx, scheduleID)
+		if err != nil {
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		deleted, err = tx.DeleteBlackout(Blackout{ID: blackoutID, ScheduleID: s.ID})
+		return err
+	})
+	return
+}
+
+func createCalendarBlackout(store Store, b Blackout, calendarID int) (Blackout, error) {
+	c, err := store.GetCalendar(calendarID)
+	if err != nil {
+		return b, err
+	}
+
+	b.ScheduleID, b.CalendarID = 0, c.ID
+	if err = checkBlackout(&b, c.Location()); err != nil {
+		return b, err
+	}
+
+	return store.CreateBlackout(b)
+}
+
+// checkBlackout validates the times and reason of b and normalizes its times
+// into loc.
+func checkBlackout(b *Blackout, loc *time.Location) error {
+	if b.StartTime.IsZero() || !b.StartTime.Before(b.EndTime.Time) {
+		return http_helpers.HttpError{
+			Message:    "Invalid blackout time",
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+	if len(b.Reason) > maxBlackoutReasonLength {
+		return http_helpers.HttpError{
+			Message:    fmt.Sprintf("reason must be at most %v bytes", maxBlackoutReasonLength),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	b.StartTime = NewTimestamp(b.StartTime.Time.In(loc))
+	b.EndTime = NewTimestamp(b.EndTime.Time.In(loc))
+	return nil
+}
+
+// checkCalendarIDs rejects subscriptions to calendars that do not exist and
+// sorts the rest, dropping duplicates.
+func checkCalendarIDs(store Store, s *Schedule) error {
+	sort.Ints(s.CalendarIDs)
+
+	var ids []int
+	for i, id := range s.CalendarIDs {
+		if i > 0 && s.CalendarIDs[i-1] == id {
+			continue
+		}
+		if _, err := store.GetCalendar(id); err != nil {
+			if _, notFound := err.(http_helpers.NotFoundError); !notFound {
+				return err
+			}
+			return http_helpers.HttpError{
+				Message:    fmt.Sprintf("Unknown calendar %v", id),
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		}
+		ids = append(ids, id)
+	}
+	s.CalendarIDs = ids
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const (
+	minutesPerDay = 24 * 60
+
+	maxBookingPolicyMinutes = 366 * minutesPerDay
+	maxAdvanceDays          = 10 * 366
+)
+
+// BookingPolicy limits the appointments that can be booked on a schedule.
+// Zero values impose no limit.
+//
+// GranularityMinutes aligns the start and end of every appointment to
+// multiples of it counted from midnight in the schedule's time zone. Lead
+// time and the advance horizon are measured from the time of booking to the
+// start of the appointment, or of the first occurrence of a series.
+type BookingPolicy struct {
+	MinDurationMinutes int `json:"min_duration_minutes"`
+	MaxDurationMinutes int `json:"max_duration_minutes"`
+	GranularityMinutes int `json:"granularity_minutes"`
+	MinLeadTimeMinutes int `json:"min_lead_time_minutes"`
+	MaxAdvanceDays     int `json:"max_advance_days"`
+}
+
+func (p BookingPolicy) leadTime() time.Duration {
+	return time.Duration(p.MinLeadTimeMinutes) * time.Minute
+}
+
+func (p BookingPolicy) horizon() time.Duration {
+	return time.Duration(p.MaxAdvanceDays) * 24 * time.Hour
+}
+
+// violations returns the rules of p that an appointment spanning i, booked
+// at now, breaks. Alignment is checked on wall-clock times in loc.
+func (p BookingPolicy) violations(i Interval, loc *time.Location, now time.Time) []Violation {
+	var violations []Violation
+	if v := p.durationViolation(i.End.Sub(i.Start.Time)); v != nil {
+		violations = append(violations, *v)
+	}
+
+	if g := p.GranularityMinutes; g > 0 {
+		for _, field := range []struct {
+			name string
+			t    time.Time
+		}{{"start_time", i.Start.In(loc).Time}, {"end_time", i.End.In(loc).Time}} {
+			if field.t.Second() != 0 || (field.t.Hour()*60+field.t.Minute())%g != 0 {
+				violations = append(violations, Violation{
+					Rule:    RuleGranularity,
+					Field:   field.name,
+					Message: fmt.Sprintf("%v must be a multiple of %v minutes after midnight", field.name, g),
+				})
+				break
+			}
+		}
+	}
+
+	if p.MinLeadTimeMinutes > 0 && i.Start.Before(now.Add(p.leadTime())) {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLeadTime,
+			Field:   "start_time",
+			Message: fmt.Sprintf("Appointments must be booked at least %v minutes in advance", p.MinLeadTimeMinutes),
+		})
+	}
+	if p.MaxAdvanceDays > 0 && i.Start.After(now.Add(p.horizon())) {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxAdvance,
+			Field:   "start_time",
+			Message: fmt.Sprintf("Appointments can be booked at most %v days in advance", p.MaxAdvanceDays),
+		})
+	}
+
+	return violations
+}
+
+// durationViolation reports an appointment duration outside p's limits.
+func (p BookingPolicy) durationViolation(d time.Duration) *Violation {
+	if p.MinDurationMinutes > 0 && d < time.Duration(p.MinDurationMinutes)*time.Minute {
+		return &Violation{
+			Rule:    RuleMinDuration,
+			Field:   "end_time",
+			Message: fmt.Sprintf("Appointments must last at least %v minutes", p.MinDurationMinutes),
+		}
+	}
+	if p.MaxDurationMinutes > 0 && d > time.Duration(p.MaxDurationMinutes)*time.Minute {
+		return &Violation{
+			Rule:    RuleMaxDuration,
+			Field:   "end_time",
+			Message: fmt.Sprintf("Appointments must last at most %v minutes", p.MaxDurationMinutes),
+		}
+	}
+
+	return nil
+}
+
+// window narrows [from, to) to the times at which an appointment of the
+// given duration, booked at now, could start and end under p. The result is
+// empty when to does not follow from.
+func (p BookingPolicy) window(from, to time.Time, duration time.Duration, now time.Time) (time.Time, time.Time) {
+	if earliest := now.Add(p.leadTime()); p.MinLeadTimeMinutes > 0 && earliest.After(from) {
+		from = earliest
+	}
+	if latest := now.Add(p.horizon()).Add(duration); p.MaxAdvanceDays > 0 && latest.Before(to) {
+		to = latest
+	}
+
+	return from, to
+}
+
+// slotGranularity returns the granularity a slot search should use: the one
+// requested or, failing that, p's own or the default.
+func (p BookingPolicy) slotGranularity(requested time.Duration) time.Duration {
+	switch {
+	case requested > 0:
+		return requested
+	case p.GranularityMinutes > 0:
+		return time.Duration(p.GranularityMinutes) * time.Minute
+	}
+
+	return defaultSlotGranularity
+}
+
+// checkBookingPolicy rejects negative or out of range limits, a minimum
+// duration above the maximum and granularities that do not divide a day.
+func checkBookingPolicy(s *Schedule) error {
+	p := s.BookingPolicy
+	invalid := func(format string, args ...interface{}) error {
+		return http_helpers.HttpError{
+			Message:    fmt.Sprintf(format, args...),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	for _, limit := range []struct {
+		name    string
+		minutes int
+	}{
+		{"min_duration_minutes", p.MinDurationMinutes},
+		{"max_duration_minutes", p.MaxDurationMinutes},
+		{"min_lead_time_minutes", p.MinLeadTimeMinutes},
+	} {
+		if limit.minutes < 0 || limit.minutes > maxBookingPolicyMinutes {
+			return invalid("booking_policy %v must be between 0 and %v", limit.name, maxBookingPolicyMinutes)
+		}
+	}
+	if p.MaxAdvanceDays < 0 || p.MaxAdvanceDays > maxAdvanceDays {
+		return invalid("booking_policy max_advance_days must be between 0 and %v", maxAdvanceDays)
+	}
+	if p.MaxDurationMinutes > 0 && p.MinDurationMinutes > p.MaxDurationMinutes {
+		return invalid("booking_policy min_duration_minutes must not exceed max_duration_minutes")
+	}
+	if g := p.GranularityMinutes; g < 0 || g > minutesPerDay || (g > 0 && minutesPerDay%g != 0) {
+		return invalid("booking_policy granularity_minutes must divide a day evenly")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+// scheduleTag formats the version of s as a strong ETag. Tags name the
+// resource as well as its version, so that the tag of one resource never
+// satisfies a precondition on another, as when a request lists several.
+func scheduleTag(s Schedule) string {
+	return fmt.Sprintf(`"%v-%v"`, s.ID, s.Version)
+}
+
+// appointmentTag formats the version of a as a strong ETag, like scheduleTag.
+func appointmentTag(a Appointment) string {
+	return fmt.Sprintf(`"%v-%v-%v"`, a.ScheduleID, a.ID, a.Version)
+}
+
+// checkIfMatch evaluates an If-Match header against the current tag of the
+// resource about to change. An empty header always passes.
+func checkIfMatch(ifMatch, tag string) error {
+	if ifMatch == "" || etagListMatches(ifMatch, tag, false) {
+		return nil
+	}
+
+	return http_helpers.HttpError{
+		Message:    "Resource has been modified",
+		StatusCode: http.StatusPreconditionFailed,
+	}
+}
+
+// etagListMatches reports whether a comma separated If-Match or If-None-Match
+// header names tag or "*". If-Match uses the strong comparison, under which
+// weak tags never match.
+func etagListMatches(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified sets the ETag header to tag and answers 304 Not Modified when
+// the request's If-None-Match already names it.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagListMatches(ifNoneMatch, tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
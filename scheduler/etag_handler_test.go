@@ -0,0 +1,118 @@
+package scheduler_test
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Conditional Requests", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+		s     Schedule
+		a     Appointment
+		start time.Time
+	)
+
+	params := func() []string {
+		return []string{"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID)}
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		start = time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+		a, _ = store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+		})
+	})
+
+	Context("GET", func() {
+		It("Should return an ETag and a StatusNotModified while it still matches", func() {
+			recorder := serve(h.ScheduleDetailsHandler, "GET", "/", "", params()...)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			etag := recorder.Header().Get("ETag")
+			Expect(etag).NotTo(BeEmpty())
+
+			recorder = serveWithHeaders(h.ScheduleDetailsHandler, "GET", "/", "", map[string]string{"If-None-Match": "W/" + etag}, params()...)
+			Expect(recorder.Code).To(Equal(http.StatusNotModified))
+			Expect(recorder.Body.Len()).To(BeZero())
+
+			store.CreateAppointment(Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(start.Add(2 * time.Hour)),
+				EndTime:    NewTimestamp(start.Add(3 * time.Hour)),
+			})
+
+			recorder = serveWithHeaders(h.ScheduleDetailsHandler, "GET", "/", "", map[string]string{"If-None-Match": etag}, params()...)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("ETag")).NotTo(Equal(etag))
+		})
+
+		It("Should return a StatusNotModified for an unchanged appointment", func() {
+			etag := serve(h.AppointmentDetailsHandler, "GET", "/", "", params()...).Header().Get("ETag")
+
+			recorder := serveWithHeaders(h.AppointmentDetailsHandler, "GET", "/", "", map[string]string{"If-None-Match": `"0", ` + etag}, params()...)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotModified))
+		})
+	})
+
+	Context("If-Match", func() {
+		It("Should update a schedule only while its ETag matches", func() {
+			etag := serve(h.ScheduleDetailsHandler, "GET", "/", "", params()...).Header().Get("ETag")
+
+			recorder := serveWithHeaders(h.PatchScheduleHandler, "PATCH", "/", `{"owner_name": "Tyrion, Hand of the Queen"}`,
+				map[string]string{"If-Match": etag}, params()...)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("ETag")).NotTo(Equal(etag))
+
+			recorder = serveWithHeaders(h.PatchScheduleHandler, "PATCH", "/", `{"owner_name": "Imp"}`,
+				map[string]string{"If-Match": etag}, params()...)
+			Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.OwnerName).To(Equal("Tyrion, Hand of the Queen"))
+		})
+
+		It("Should refuse to book on a schedule that changed", func() {
+			etag := serve(h.ScheduleDetailsHandler, "GET", "/", "", params()...).Header().Get("ETag")
+			store.DeleteAppointment(s.ID, a.ID)
+
+			recorder := serveWithHeaders(h.CreateAppointmentHandler, "POST", "/", `{"start_time": 1559556000, "end_time": 1559559600}`,
+				map[string]string{"If-Match": etag}, params()...)
+
+			Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+		})
+
+		It("Should delete an appointment only for a strong match or *", func() {
+			etag := serve(h.AppointmentDetailsHandler, "GET", "/", "", params()...).Header().Get("ETag")
+
+			for _, ifMatch := range []string{"W/" + etag, `"42"`} {
+				recorder := serveWithHeaders(h.DeleteAppointmentHandler, "DELETE", "/", "", map[string]string{"If-Match": ifMatch}, params()...)
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed), ifMatch)
+			}
+
+			recorder := serveWithHeaders(h.DeleteAppointmentHandler, "DELETE", "/", "", map[string]string{"If-Match": "*"}, params()...)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("Should delete a schedule only while its ETag matches", func() {
+			recorder := serveWithHeaders(h.DeleteScheduleHandler, "DELETE", "/", "", map[string]string{"If-Match": `"42"`}, params()...)
+			Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+
+			_, err := store.GetSchedule(s.ID)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
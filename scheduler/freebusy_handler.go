@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+// FreeBusyHandler reports busy intervals for the schedules listed in
+// schedule_ids within the required from/to window. union=true adds the
+// merged busy time across all of them.
+func (h *Handler) FreeBusyHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleIDs, err := parseScheduleIDs(r.URL.Query().Get("schedule_ids"))
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from, to, windowed, err := parseWindow(r)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !windowed {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Both from and to are required")
+		return
+	}
+
+	union := false
+	if param := r.URL.Query().Get("union"); param != "" {
+		if union, err = strconv.ParseBool(param); err != nil {
+			http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid union")
+			return
+		}
+	}
+
+	fb, err := freeBusy(h.Store, scheduleIDs, from, to, union)
+	if err != nil {
+		log.Println("FreeBusyHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to retrieve free/busy")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, fb)
+}
+
+// parseScheduleIDs reads a comma separated list of distinct schedule IDs.
+func parseScheduleIDs(param string) ([]int, error) {
+	if param == "" {
+		return nil, errors.New("schedule_ids is required")
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+	for _, value := range strings.Split(param, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errors.New("Invalid schedule ID")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > maxFreeBusySchedules {
+		return nil, fmt.Errorf("At most %v schedules may be queried at once", maxFreeBusySchedules)
+	}
+
+	return ids, nil
+}
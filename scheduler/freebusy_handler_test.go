@@ -0,0 +1,178 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Free/Busy Handler", func() {
+	var (
+		store  *MemoryStore
+		h      *Handler
+		tyrion Schedule
+		arya   Schedule
+		day    time.Time
+	)
+
+	type interval struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+
+	type freeBusyResponse struct {
+		Schedules []struct {
+			ScheduleID int        `json:"schedule_id"`
+			Busy       []interval `json:"busy"`
+		} `json:"schedules"`
+		Busy []interval `json:"busy"`
+	}
+
+	query := func(params string) (*httptest.ResponseRecorder, freeBusyResponse) {
+		recorder := serve(h.FreeBusyHandler, "GET", "/freebusy?"+params, "")
+
+		var resBody freeBusyResponse
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		return recorder, resBody
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		tyrion, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		arya, _ = store.CreateSchedule(Schedule{OwnerName: "Arya Stark", TimeZone: "UTC"})
+		day = time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC)
+	})
+
+	It("Should merge touching appointments and clip them to the window", func() {
+		bookAppointment(store, tyrion, day.Add(15*time.Hour), time.Hour)
+		bookAppointment(store, tyrion, day.Add(16*time.Hour), 30*time.Minute)
+		bookAppointment(store, tyrion, day.Add(18*time.Hour), time.Hour)
+		bookAppointment(store, tyrion, day.Add(-time.Hour), 2*time.Hour)
+
+		recorder, resBody := query(fmt.Sprintf("schedule_ids=%v&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z", tyrion.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(resBody.Schedules).To(HaveLen(1))
+		Expect(resBody.Schedules[0].ScheduleID).To(Equal(tyrion.ID))
+		Expect(resBody.Schedules[0].Busy).To(Equal([]interval{
+			{Start: "2019-06-02T18:00:00-06:00", End: "2019-06-02T19:00:00-06:00"},
+			{Start: "2019-06-03T09:00:00-06:00", End: "2019-06-03T10:30:00-06:00"},
+			{Start: "2019-06-03T12:00:00-06:00", End: "2019-06-03T13:00:00-06:00"},
+		}))
+		Expect(resBody.Busy).To(BeEmpty())
+	})
+
+	It("Should report each schedule and the union across them", func() {
+		bookAppointment(store, tyrion, day.Add(15*time.Hour), time.Hour)
+		bookAppointment(store, arya, day.Add(15*time.Hour+30*time.Minute), time.Hour)
+		bookAppointment(store, arya, day.Add(20*time.Hour), time.Hour)
+
+		recorder, resBody := query(fmt.Sprintf("schedule_ids=%v,%v&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z&union=true", tyrion.ID, arya.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(resBody.Schedules).To(HaveLen(2))
+		Expect(resBody.Schedules[1].Busy).To(HaveLen(2))
+		Expect(resBody.Busy).To(Equal([]interval{
+			{Start: "2019-06-03T15:00:00Z", End: "2019-06-03T16:30:00Z"},
+			{Start: "2019-06-03T20:00:00Z", End: "2019-06-03T21:00:00Z"},
+		}))
+	})
+
+	It("Should return an empty list for a schedule with no appointments in the window", func() {
+		recorder, resBody := query(fmt.Sprintf("schedule_ids=%v&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z", arya.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(resBody.Schedules[0].Busy).To(Equal([]interval{}))
+	})
+
+	It("Should leave out cancelled appointments", func() {
+		bookAppointment(store, arya, day.Add(9*time.Hour), time.Hour)
+		store.CreateAppointment(Appointment{
+			ScheduleID: arya.ID,
+			StartTime:  NewTimestamp(day.Add(12 * time.Hour)),
+			EndTime:    NewTimestamp(day.Add(13 * time.Hour)),
+			Status:     StatusCancelled,
+		})
+
+		_, resBody := query(fmt.Sprintf("schedule_ids=%v&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z", arya.ID))
+
+		Expect(resBody.Schedules[0].Busy).To(Equal([]interval{
+			{Start: "2019-06-03T09:00:00Z", End: "2019-06-03T10:00:00Z"},
+		}))
+	})
+
+	It("Should include the buffers of the schedule's overlap policy in busy time", func() {
+		arya.OverlapPolicy = OverlapPolicy{Intervals: HalfOpenIntervals, BufferBeforeMinutes: 15, BufferAfterMinutes: 30}
+		store.UpdateSchedule(arya)
+		bookAppointment(store, arya, day.Add(9*time.Hour), time.Hour)
+		bookAppointment(store, arya, day.Add(23*time.Hour+45*time.Minute), 30*time.Minute)
+
+		_, resBody := query(fmt.Sprintf("schedule_ids=%v&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z", arya.ID))
+
+		Expect(resBody.Schedules[0].Busy).To(Equal([]interval{
+			{Start: "2019-06-03T08:45:00Z", End: "2019-06-03T10:30:00Z"},
+			{Start: "2019-06-03T23:30:00Z", End: "2019-06-04T00:00:00Z"},
+		}))
+	})
+
+	It("Should report time outside working hours as busy", func() {
+		arya.WorkingHours = &WorkingHours{Weekly: map[string][]TimeRange{
+			"monday": {{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 17}}},
+		}}
+		store.UpdateSchedule(arya)
+		bookAppointment(store, arya, day.Add(9*time.Hour), time.Hour)
+		bookAppointment(store, arya, day.Add(12*time.Hour), time.Hour)
+
+		_, resBody := query(fmt.Sprintf("schedule_ids=%v&from=2019-06-03T06:00:00Z&to=2019-06-04T06:00:00Z", arya.ID))
+
+		Expect(resBody.Schedules[0].Busy).To(Equal([]interval{
+			{Start: "2019-06-03T06:00:00Z", End: "2019-06-03T10:00:00Z"},
+			{Start: "2019-06-03T12:00:00Z", End: "2019-06-03T13:00:00Z"},
+			{Start: "2019-06-03T17:00:00Z", End: "2019-06-04T06:00:00Z"},
+		}))
+	})
+
+	It("Should report blackouts as busy", func() {
+		rule, _ := ParseRecurrenceRule("FREQ=DAILY")
+		store.CreateBlackout(Blackout{
+			ScheduleID: arya.ID, StartTime: NewTimestamp(day.AddDate(0, 0, -3).Add(12 * time.Hour)),
+			EndTime: NewTimestamp(day.AddDate(0, 0, -3).Add(13 * time.Hour)), Recurrence: rule,
+		})
+		bookAppointment(store, arya, day.Add(9*time.Hour), time.Hour)
+
+		_, resBody := query(fmt.Sprintf("schedule_ids=%v&from=2019-06-03T06:00:00Z&to=2019-06-04T06:00:00Z", arya.ID))
+
+		Expect(resBody.Schedules[0].Busy).To(Equal([]interval{
+			{Start: "2019-06-03T09:00:00Z", End: "2019-06-03T10:00:00Z"},
+			{Start: "2019-06-03T12:00:00Z", End: "2019-06-03T13:00:00Z"},
+		}))
+	})
+
+	It("Should return a StatusNotFound if any schedule is missing", func() {
+		recorder, _ := query(fmt.Sprintf("schedule_ids=%v,32&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z", tyrion.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should return a StatusBadRequest for invalid parameters", func() {
+		for _, params := range []string{
+			"from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z",
+			"schedule_ids=blamo&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z",
+			"schedule_ids=1",
+			"schedule_ids=1&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z&union=blamo",
+		} {
+			recorder, _ := query(params)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest), params)
+		}
+	})
+})
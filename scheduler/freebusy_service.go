@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+const maxFreeBusySchedules = 100
+
+// Interval is a span of time with no further details, as reported by
+// free/busy queries.
+type Interval struct {
+	Start Timestamp `json:"start"`
+	End   Timestamp `json:"end"`
+}
+
+func (a Appointment) interval() Interval {
+	return Interval{Start: a.StartTime, End: a.EndTime}
+}
+
+// overlaps treats intervals as closed, so intervals that share a boundary
+// overlap.
+func (i Interval) overlaps(j Interval) bool {
+	outsideRange := i.Start.After(j.End.Time) || i.End.Before(j.Start.Time)
+	return !outsideRange
+}
+
+// mergeIntervals sorts intervals and combines those that overlap, as defined
+// by Interval.overlaps.
+func mergeIntervals(intervals []Interval) []Interval {
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start.Time)
+	})
+
+	merged := []Interval{}
+	for _, interval := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && merged[last].overlaps(interval) {
+			if interval.End.After(merged[last].End.Time) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+type ScheduleBusy struct {
+	ScheduleID int        `json:"schedule_id"`
+	Busy       []Interval `json:"busy"`
+}
+
+type FreeBusy struct {
+	From      Timestamp      `json:"from"`
+	To        Timestamp      `json:"to"`
+	Schedules []ScheduleBusy `json:"schedules"`
+	Busy      []Interval     `json:"busy,omitempty"`
+}
+
+// freeBusy reports when each of the given schedules is busy within
+// [from, to), clipped to the window and in each schedule's time zone. Time
+// outside a schedule's working hours or blacked out counts as busy. With
+// union set it also merges the busy time of all of them, in UTC.
+func freeBusy(store Store, scheduleIDs []int, from, to time.Time, union bool) (FreeBusy, error) {
+	fb := FreeBusy{
+		From:      NewTimestamp(from.UTC()),
+		To:        NewTimestamp(to.UTC()),
+		Schedules: []ScheduleBusy{},
+	}
+
+	var all []Interval
+	for _, id := range scheduleIDs {
+		s, err := store.GetScheduleBetween(id, from.Add(-maxReach), to.Add(maxReach))
+		if err != nil {
+			return fb, err
+		}
+
+		busy := scheduleBusy(s, from, to)
+		if s.WorkingHours != nil || len(s.Blackouts) > 0 {
+			busy = mergeIntervals(append(busy, s.unavailable(from, to)...))
+		}
+		fb.Schedules = append(fb.Schedules, ScheduleBusy{ScheduleID: s.ID, Busy: busy})
+		all = append(all, busy...)
+	}
+
+	if union {
+		fb.Busy = mergeIntervals(all)
+		for i := range fb.Busy {
+			fb.Busy[i].Start = NewTimestamp(fb.Busy[i].Start.UTC())
+			fb.Busy[i].End = NewTimestamp(fb.Busy[i].End.UTC())
+		}
+	}
+
+	return fb, nil
+}
+
+// scheduleBusy returns the merged times blocked on s within [from, to) by
+// its appointments and active holds, including the buffers of its overlap
+// policy.
+func scheduleBusy(s Schedule, from, to time.Time) []Interval {
+	loc := s.Location()
+	p := s.OverlapPolicy
+
+	var busy []Interval
+	for _, a := range busyAppointments(s, from.Add(-p.bufferAfter()), to.Add(p.bufferBefore())) {
+		busy = append(busy, a.interval())
+	}
+	for _, h := range s.activeHolds(time.Now()) {
+		busy = append(busy, h.interval())
+	}
+
+	var intervals []Interval
+	for _, i := range busy {
+		blocked := p.blocked(i)
+		start, end := blocked.Start.Time, blocked.End.Time
+		if !end.After(from) || !start.Before(to) {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		intervals = append(intervals, Interval{
+			Start: NewTimestamp(start.In(loc)),
+			End:   NewTimestamp(end.In(loc)),
+		})
+	}
+
+	return mergeIntervals(intervals)
+}
@@ -2,16 +2,31 @@ package scheduler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ckaminer/go-utils/http_helpers"
 	"github.com/go-chi/chi"
 )
 
-func CreateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+// Handler serves the schedule and appointment routes backed by a Store.
+type Handler struct {
+	Store       Store
+	Idempotency *IdempotencyCache
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{Store: store, Idempotency: NewIdempotencyCache(DefaultIdempotencyWindow, DefaultIdempotencyMaxEntries)}
+}
+
+func (h *Handler) CreateScheduleHandler(w http.ResponseWriter, r *http.Request) {
 	var s Schedule
 	err := json.NewDecoder(r.Body).Decode(&s)
 	if err != nil {
@@ -21,52 +36,136 @@ func CreateScheduleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	s.ID = SchedulesCreatedCount + 1
-	s.Appointments = make(map[int]Appointment)
-	ScheduleCollection[s.ID] = s
-	SchedulesCreatedCount++
+	s, err = createSchedule(h.Store, s)
+	if err != nil {
+		log.Println("CreateScheduleHandler Err: ", err.Error())
+		respondWithServiceError(w, err, "Unable to create schedule")
+		return
+	}
 
 	http_helpers.RespondWithJSON(w, http.StatusCreated, s)
 }
 
-func ScheduleDetailsHandler(w http.ResponseWriter, r *http.Request) {
+// ListSchedulesHandler pages through schedules. See parseScheduleQuery for the
+// supported query parameters.
+func (h *Handler) ListSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseScheduleQuery(r)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Ask for one extra schedule to learn whether another page follows.
+	limit := q.Limit
+	q.Limit++
+	schedules, total, err := h.Store.ListSchedules(q)
+	if err != nil {
+		log.Println("ListSchedulesHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to list schedules")
+		return
+	}
+
+	res := ScheduleListResponse{Schedules: []ScheduleSummary{}, Total: total}
+	for i, s := range schedules {
+		if i == limit {
+			res.NextCursor = newScheduleCursor(q, schedules[i-1]).Encode()
+			break
+		}
+		res.Schedules = append(res.Schedules, ScheduleSummary{ID: s.ID, OwnerName: s.OwnerName, TimeZone: s.TimeZone})
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) ScheduleDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	scheduleID, err := convertIDParam(r, "scheduleID")
 	if err != nil {
 		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
 		return
 	}
 
+	from, to, windowed, err := parseWindow(r)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	var s Schedule
-	s, found := ScheduleCollection[scheduleID]
-	if !found {
-		log.Println("ScheduleDetailsHandler - no schedule found for ID: ", scheduleID)
-		http_helpers.RespondWithError(w, http.StatusNotFound, "Schedule not found")
+	if windowed {
+		s, err = h.Store.GetScheduleBetween(scheduleID, from, to)
+	} else {
+		s, err = h.Store.GetSchedule(scheduleID)
+	}
+	if err != nil {
+		log.Println("ScheduleDetailsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to retrieve schedule")
+		return
+	}
+
+	if notModified(w, r, scheduleTag(s)) {
+		return
+	}
+
+	if windowed {
+		http_helpers.RespondWithJSON(w, http.StatusOK, newScheduleResponse(s, expandAppointments(s, from, to)))
 		return
 	}
 
 	http_helpers.RespondWithJSON(w, http.StatusOK, s)
 }
 
-func DeleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateScheduleHandler replaces a schedule's owner_name, time_zone,
+// overlap_policy, booking_policy, working_hours and calendar_ids.
+func (h *Handler) UpdateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	h.updateSchedule(w, r, false)
+}
+
+// PatchScheduleHandler applies a JSON merge patch (RFC 7396) to a schedule.
+func (h *Handler) PatchScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	h.updateSchedule(w, r, true)
+}
+
+func (h *Handler) updateSchedule(w http.ResponseWriter, r *http.Request, merge bool) {
 	scheduleID, err := convertIDParam(r, "scheduleID")
 	if err != nil {
 		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
 		return
 	}
 
-	var s Schedule
-	s, found := ScheduleCollection[scheduleID]
-	if !found {
-		log.Println("DeleteScheduleHandler - no schedule found for ID: ", scheduleID)
-		http_helpers.RespondWithError(w, http.StatusNotFound, "Schedule not found")
+	body, ok := readUpdateBody(w, r, merge)
+	if !ok {
 		return
 	}
 
-	delete(ScheduleCollection, scheduleID)
+	s, err := updateSchedule(h.Store, scheduleID, body, merge, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("UpdateScheduleHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to update schedule")
+		return
+	}
+
+	w.Header().Set("ETag", scheduleTag(s))
 	http_helpers.RespondWithJSON(w, http.StatusOK, s)
 }
 
-func CreateAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	s, err := deleteSchedule(h.Store, scheduleID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("DeleteScheduleHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete schedule")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, s)
+}
+
+func (h *Handler) CreateAppointmentHandler(w http.ResponseWriter, r *http.Request) {
 	scheduleID, err := convertIDParam(r, "scheduleID")
 	if err != nil {
 		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
@@ -82,20 +181,47 @@ func CreateAppointmentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	createdAppt, err := createAppointment(a, scheduleID)
+	createdAppt, err := createAppointment(h.Store, a, scheduleID, r.Header.Get("If-Match"))
 	if err != nil {
-		if httpErr, ok := err.(http_helpers.HttpError); ok {
-			http_helpers.RespondWithError(w, httpErr.StatusCode, httpErr.Message)
-		} else {
-			http_helpers.RespondWithError(w, http.StatusServiceUnavailable, "Unable to create appointment")
-		}
+		respondWithServiceError(w, err, "Unable to create appointment")
 		return
 	}
 
+	w.Header().Set("ETag", appointmentTag(createdAppt))
 	http_helpers.RespondWithJSON(w, http.StatusCreated, createdAppt)
 }
 
-func AppointmentDetailsHandler(w http.ResponseWriter, r *http.Request) {
+// ListAppointmentsHandler pages through the occurrences on a schedule within
+// the required from/to window.
+func (h *Handler) ListAppointmentsHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	q, err := parseAppointmentQuery(r)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	appointments, next, err := listAppointments(h.Store, scheduleID, q)
+	if err != nil {
+		log.Println("ListAppointmentsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to list appointments")
+		return
+	}
+
+	res := AppointmentListResponse{Appointments: appointments}
+	if next != nil {
+		res.NextCursor = next.Encode()
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (h *Handler) AppointmentDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	scheduleID, err := convertIDParam(r, "scheduleID")
 	if err != nil {
 		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
@@ -108,26 +234,33 @@ func AppointmentDetailsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var s Schedule
-	s, found := ScheduleCollection[scheduleID]
-	if !found {
-		log.Println("AppointmentDetailsHandler - no schedule found for ID: ", scheduleID)
-		http_helpers.RespondWithError(w, http.StatusNotFound, "Schedule not found")
+	a, err := h.Store.GetAppointment(scheduleID, appointmentID)
+	if err != nil {
+		log.Println("AppointmentDetailsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to retrieve appointment")
 		return
 	}
 
-	var a Appointment
-	a, found = s.Appointments[appointmentID]
-	if !found {
-		log.Println("AppointmentDetailsHandler - no appointment found for ID: ", appointmentID)
-		http_helpers.RespondWithError(w, http.StatusNotFound, "Appointment not found")
+	if notModified(w, r, appointmentTag(a)) {
 		return
 	}
 
 	http_helpers.RespondWithJSON(w, http.StatusOK, a)
 }
 
-func DeleteAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateAppointmentHandler replaces an appointment's times, recurrence,
+// exceptions, title, description, location and attributes, keeping its ID.
+func (h *Handler) UpdateAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.updateAppointment(w, r, false)
+}
+
+// PatchAppointmentHandler applies a JSON merge patch (RFC 7396) to an
+// appointment.
+func (h *Handler) PatchAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.updateAppointment(w, r, true)
+}
+
+func (h *Handler) updateAppointment(w http.ResponseWriter, r *http.Request, merge bool) {
 	scheduleID, err := convertIDParam(r, "scheduleID")
 	if err != nil {
 		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
@@ -140,23 +273,42 @@ func DeleteAppointmentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var s Schedule
-	s, found := ScheduleCollection[scheduleID]
-	if !found {
-		log.Println("DeleteScheduleHandler - no schedule found for ID: ", scheduleID)
-		http_helpers.RespondWithError(w, http.StatusNotFound, "Schedule not found")
+	body, ok := readUpdateBody(w, r, merge)
+	if !ok {
 		return
 	}
 
-	var a Appointment
-	a, found = s.Appointments[appointmentID]
-	if !found {
-		log.Println("DeleteAppointmentHandler - no appointment found for ID: ", appointmentID)
-		http_helpers.RespondWithError(w, http.StatusNotFound, "Appointment not found")
+	a, err := updateAppointment(h.Store, scheduleID, appointmentID, body, merge, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("UpdateAppointmentHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to update appointment")
+		return
+	}
+
+	w.Header().Set("ETag", appointmentTag(a))
+	http_helpers.RespondWithJSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) DeleteAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	appointmentID, err := convertIDParam(r, "appointmentID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	a, err := deleteAppointment(h.Store, scheduleID, appointmentID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("DeleteAppointmentHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete appointment")
 		return
 	}
 
-	delete(s.Appointments, appointmentID)
 	http_helpers.RespondWithJSON(w, http.StatusOK, a)
 }
 
@@ -170,19 +322,223 @@ func convertIDParam(r *http.Request, paramName string) (int, error) {
 	return id, err
 }
 
+// readUpdateBody reads the body of a PUT or PATCH request. Merge patches may
+// be sent as application/merge-patch+json or plain application/json.
+func readUpdateBody(w http.ResponseWriter, r *http.Request, merge bool) ([]byte, bool) {
+	if contentType := r.Header.Get("Content-Type"); merge && contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			http_helpers.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+			return nil, false
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("readUpdateBody Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	return body, true
+}
+
+// parseWindow reads the optional from/to query parameters bounding a time
+// window. Both must be given together and the window may not be longer than
+// recurrenceHorizon.
+func parseWindow(r *http.Request) (from, to time.Time, windowed bool, err error) {
+	fromParam, toParam := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromParam == "" && toParam == "" {
+		return
+	}
+	if fromParam == "" || toParam == "" {
+		err = errors.New("Both from and to are required")
+		return
+	}
+
+	fromTS, err := ParseTimestamp(fromParam)
+	if err != nil {
+		err = errors.New("Invalid from")
+		return
+	}
+	toTS, err := ParseTimestamp(toParam)
+	if err != nil {
+		err = errors.New("Invalid to")
+		return
+	}
+
+	from, to, windowed = fromTS.Time, toTS.Time, true
+	if !from.Before(to) {
+		err = errors.New("from must be before to")
+	} else if to.Sub(from) > recurrenceHorizon {
+		err = errors.New("Window may not exceed 366 days")
+	}
+	return
+}
+
+// parseScheduleQuery reads the owner_name, owner_name_prefix, sort (id or
+// owner_name, prefixed with "-" for descending order), limit and cursor query
+// parameters.
+func parseScheduleQuery(r *http.Request) (q ScheduleQuery, err error) {
+	params := r.URL.Query()
+	q.OwnerName = params.Get("owner_name")
+	q.OwnerNamePrefix = params.Get("owner_name_prefix")
+
+	sortBy := params.Get("sort")
+	if strings.HasPrefix(sortBy, "-") {
+		q.Descending, sortBy = true, sortBy[1:]
+	}
+	switch sortBy {
+	case "", SortByID:
+		q.SortBy = SortByID
+	case SortByOwnerName:
+		q.SortBy = SortByOwnerName
+	default:
+		return q, errors.New("Invalid sort")
+	}
+
+	q.Limit = defaultScheduleLimit
+	if limit := params.Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 1 || q.Limit > maxScheduleLimit {
+			return q, fmt.Errorf("limit must be between 1 and %v", maxScheduleLimit)
+		}
+	}
+
+	if cursor := params.Get("cursor"); cursor != "" {
+		if q.After, err = decodeScheduleCursor(cursor); err != nil {
+			return q, err
+		}
+		if q.After.SortBy != q.SortBy || q.After.Descending != q.Descending {
+			return q, errors.New("Cursor was issued for a different sort")
+		}
+	}
+
+	return q, nil
+}
+
+// parseAppointmentQuery reads the from/to window, which is required, the
+// limit, cursor and status query parameters and attribute filters, given as
+// attributes.<name>=<value>.
+func parseAppointmentQuery(r *http.Request) (q AppointmentQuery, err error) {
+	from, to, windowed, err := parseWindow(r)
+	if err != nil {
+		return q, err
+	}
+	if !windowed {
+		return q, errors.New("Both from and to are required")
+	}
+	q.From, q.To = from, to
+
+	q.Limit = defaultAppointmentLimit
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 1 || q.Limit > maxAppointmentLimit {
+			return q, fmt.Errorf("limit must be between 1 and %v", maxAppointmentLimit)
+		}
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if q.After, err = decodeAppointmentCursor(cursor); err != nil {
+			return q, err
+		}
+	}
+
+	q.Statuses = r.URL.Query()["status"]
+	for _, status := range q.Statuses {
+		if _, found := statusTransitions[status]; !found {
+			return q, fmt.Errorf("Unknown status %q", status)
+		}
+	}
+
+	for param, values := range r.URL.Query() {
+		if name := strings.TrimPrefix(param, attributeParamPrefix); name != param {
+			if q.Attributes == nil {
+				q.Attributes = make(map[string][]string)
+			}
+			q.Attributes[name] = values
+		}
+	}
+
+	return q, nil
+}
+
+// respondWithServiceError maps errors returned by the service and store layers
+// onto a response, falling back to a 503 with fallbackMessage.
+func respondWithServiceError(w http.ResponseWriter, err error, fallbackMessage string) {
+	switch e := err.(type) {
+	case ValidationError:
+		respondWithProblem(w, e.Problem())
+	case http_helpers.HttpError:
+		http_helpers.RespondWithError(w, e.StatusCode, e.Message)
+	case http_helpers.ResourceError:
+		http_helpers.RespondWithError(w, e.Code(), e.ClientMessage())
+	default:
+		http_helpers.RespondWithError(w, http.StatusServiceUnavailable, fallbackMessage)
+	}
+}
+
+// respondWithProblem writes an RFC 7807 application/problem+json response.
+func respondWithProblem(w http.ResponseWriter, p Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusInternalServerError, "Unable to encode problem")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
 type ScheduleResponse struct {
-	ID           int           `json:"id"`
-	OwnerName    string        `json:"owner_name"`
+	ID            int           `json:"id"`
+	OwnerName     string        `json:"owner_name"`
+	TimeZone      string        `json:"time_zone"`
+	Appointments  []Appointment `json:"appointments"`
+	OverlapPolicy OverlapPolicy `json:"overlap_policy"`
+	BookingPolicy BookingPolicy `json:"booking_policy"`
+	WorkingHours  *WorkingHours `json:"working_hours,omitempty"`
+	CalendarIDs   []int         `json:"calendar_ids,omitempty"`
+	Blackouts     []Blackout    `json:"blackouts,omitempty"`
+	Holds         []Hold        `json:"holds,omitempty"`
+	Version       int           `json:"version"`
+}
+
+type ScheduleSummary struct {
+	ID        int    `json:"id"`
+	OwnerName string `json:"owner_name"`
+	TimeZone  string `json:"time_zone"`
+}
+
+type ScheduleListResponse struct {
+	Schedules  []ScheduleSummary `json:"schedules"`
+	Total      int               `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type AppointmentListResponse struct {
 	Appointments []Appointment `json:"appointments"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
 }
 
-func (s Schedule) MarshalJSON() ([]byte, error) {
-	sortedAppointments := sortAppointments(s)
-	scheduleRes := ScheduleResponse{
-		ID:           s.ID,
-		OwnerName:    s.OwnerName,
-		Appointments: sortedAppointments,
+func newScheduleResponse(s Schedule, appointments []Appointment) ScheduleResponse {
+	return ScheduleResponse{
+		ID:            s.ID,
+		OwnerName:     s.OwnerName,
+		TimeZone:      s.TimeZone,
+		Appointments:  appointments,
+		OverlapPolicy: s.OverlapPolicy,
+		BookingPolicy: s.BookingPolicy,
+		WorkingHours:  s.WorkingHours,
+		CalendarIDs:   s.CalendarIDs,
+		Blackouts:     s.Blackouts,
+		Holds:         s.Holds,
+		Version:       s.Version,
 	}
+}
 
-	return json.Marshal(scheduleRes)
+func (s Schedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newScheduleResponse(s, sortAppointments(s)))
 }
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi"
 	. "github.com/onsi/ginkgo"
@@ -15,11 +19,21 @@ import (
 )
 
 var _ = Describe("Handlers", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+	)
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+	})
+
 	Context("Schedule Handlers", func() {
 		Context("#CreateSchedule", func() {
 			It("Should return a StatusCreated and the created entity upon successful creation of a schedule", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateScheduleHandler)
+				handler := http.HandlerFunc(h.CreateScheduleHandler)
 
 				reqBody := []byte(`
 					{
@@ -45,9 +59,12 @@ var _ = Describe("Handlers", func() {
 			})
 
 			It("Should increment the ID by one for each created schedule", func() {
-				SchedulesCreatedCount = 4
+				for i := 0; i < 4; i++ {
+					store.CreateSchedule(Schedule{OwnerName: "Jaime Lannister"})
+				}
+
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateScheduleHandler)
+				handler := http.HandlerFunc(h.CreateScheduleHandler)
 
 				reqBody := []byte(`
 					{
@@ -70,12 +87,41 @@ var _ = Describe("Handlers", func() {
 				Expect(resBody.OwnerName).To(Equal("Tyrion Lannister"))
 				Expect(resBody.ID).To(Equal(5))
 
-				Expect(SchedulesCreatedCount).To(Equal(5))
+				_, err = store.GetSchedule(5)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("Should default the time zone to UTC", func() {
+				recorder := httptest.NewRecorder()
+				handler := http.HandlerFunc(h.CreateScheduleHandler)
+
+				reqBody := []byte(`{"owner_name": "Tyrion Lannister"}`)
+				r, _ := http.NewRequest("POST", "/schedules", bytes.NewReader(reqBody))
+
+				handler.ServeHTTP(recorder, r)
+
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+				var resBody ScheduleResponse
+				json.NewDecoder(recorder.Body).Decode(&resBody)
+				Expect(resBody.TimeZone).To(Equal("UTC"))
+			})
+
+			It("Should return a StatusUnprocessableEntity for an unknown time zone", func() {
+				recorder := httptest.NewRecorder()
+				handler := http.HandlerFunc(h.CreateScheduleHandler)
+
+				reqBody := []byte(`{"owner_name": "Tyrion Lannister", "time_zone": "Westeros/Kings_Landing"}`)
+				r, _ := http.NewRequest("POST", "/schedules", bytes.NewReader(reqBody))
+
+				handler.ServeHTTP(recorder, r)
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
 			})
 
 			It("Should return a StatusBadRequest if the reqBody is invalid", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateScheduleHandler)
+				handler := http.HandlerFunc(h.CreateScheduleHandler)
 
 				reqBody := []byte(`{"owner_name": true}`)
 
@@ -87,21 +133,102 @@ var _ = Describe("Handlers", func() {
 			})
 		})
 
+		Context("#ListSchedules", func() {
+			It("Should page through schedules with a cursor and report the total", func() {
+				for _, name := range []string{"Tyrion Lannister", "Arya Stark", "Tywin Lannister", "Cersei Lannister"} {
+					store.CreateSchedule(Schedule{OwnerName: name})
+				}
+
+				var names []string
+				cursor := ""
+				for page := 0; page < 3; page++ {
+					recorder := httptest.NewRecorder()
+					handler := http.HandlerFunc(h.ListSchedulesHandler)
+
+					r, _ := http.NewRequest("GET", "/schedules?sort=owner_name&limit=2&cursor="+cursor, nil)
+
+					handler.ServeHTTP(recorder, r)
+
+					Expect(recorder.Code).To(Equal(http.StatusOK))
+
+					var resBody ScheduleListResponse
+					json.NewDecoder(recorder.Body).Decode(&resBody)
+					Expect(resBody.Total).To(Equal(4))
+					for _, s := range resBody.Schedules {
+						names = append(names, s.OwnerName)
+					}
+
+					cursor = resBody.NextCursor
+					if cursor == "" {
+						break
+					}
+				}
+
+				Expect(names).To(Equal([]string{"Arya Stark", "Cersei Lannister", "Tyrion Lannister", "Tywin Lannister"}))
+				Expect(cursor).To(BeEmpty())
+			})
+
+			It("Should filter schedules by exact and prefix owner name", func() {
+				for _, name := range []string{"Tyrion Lannister", "Tywin Lannister", "Arya Stark"} {
+					store.CreateSchedule(Schedule{OwnerName: name})
+				}
+
+				for query, expected := range map[string]int{
+					"owner_name=Tyrion%20Lannister": 1,
+					"owner_name=Tyrion":             0,
+					"owner_name_prefix=Ty":          2,
+					"owner_name_prefix=ty":          0,
+				} {
+					recorder := httptest.NewRecorder()
+					handler := http.HandlerFunc(h.ListSchedulesHandler)
+
+					r, _ := http.NewRequest("GET", "/schedules?"+query, nil)
+
+					handler.ServeHTTP(recorder, r)
+
+					var resBody ScheduleListResponse
+					json.NewDecoder(recorder.Body).Decode(&resBody)
+					Expect(resBody.Total).To(Equal(expected), query)
+					Expect(resBody.Schedules).To(HaveLen(expected), query)
+				}
+			})
+
+			It("Should return a StatusBadRequest for invalid query parameters", func() {
+				cursor := ScheduleCursor{SortBy: SortByID, ID: 1}.Encode()
+
+				for _, query := range []string{
+					"sort=appointments",
+					"limit=0",
+					"limit=blamo",
+					"limit=1000",
+					"cursor=blamo",
+					"sort=owner_name&cursor=" + cursor,
+				} {
+					recorder := httptest.NewRecorder()
+					handler := http.HandlerFunc(h.ListSchedulesHandler)
+
+					r, _ := http.NewRequest("GET", "/schedules?"+query, nil)
+
+					handler.ServeHTTP(recorder, r)
+
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest), query)
+				}
+			})
+		})
+
 		Context("#ScheduleDetails", func() {
 			It("Should return schedule details for the given scheduleID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(ScheduleDetailsHandler)
+				handler := http.HandlerFunc(h.ScheduleDetailsHandler)
 
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-				}
-				ScheduleCollection[s.ID] = s
+				})
 
-				r, _ := http.NewRequest("GET", "/schedules/31", nil)
+				r, _ := http.NewRequest("GET", fmt.Sprintf("/schedules/%v", s.ID), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "31")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -119,9 +246,72 @@ var _ = Describe("Handlers", func() {
 				Expect(resBody.Appointments).To(Equal([]Appointment{}))
 			})
 
+			It("Should expand recurring appointments within the requested window", func() {
+				recorder := httptest.NewRecorder()
+				handler := http.HandlerFunc(h.ScheduleDetailsHandler)
+
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+					TimeZone:  "America/Denver",
+				})
+				rule, _ := ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=MO,WE")
+				start := time.Date(2019, 6, 3, 9, 0, 0, 0, s.Location())
+				store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  NewTimestamp(start),
+					EndTime:    NewTimestamp(start.Add(time.Hour)),
+					Recurrence: rule,
+				})
+
+				r, _ := http.NewRequest("GET", fmt.Sprintf("/schedules/%v?from=2019-06-09T00:00:00-06:00&to=2019-06-16T00:00:00-06:00", s.ID), nil)
+
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+				handler.ServeHTTP(recorder, r)
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				var resBody struct {
+					Appointments []map[string]interface{} `json:"appointments"`
+				}
+				json.NewDecoder(recorder.Body).Decode(&resBody)
+
+				Expect(resBody.Appointments).To(HaveLen(2))
+				Expect(resBody.Appointments[0]["start_time"]).To(Equal("2019-06-10T09:00:00-06:00"))
+				Expect(resBody.Appointments[0]["recurrence_id"]).To(Equal("2019-06-10T09:00:00-06:00"))
+				Expect(resBody.Appointments[1]["start_time"]).To(Equal("2019-06-12T09:00:00-06:00"))
+			})
+
+			It("Should return a StatusBadRequest for an invalid window", func() {
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+				})
+
+				for _, query := range []string{
+					"from=2019-06-09T00:00:00Z",
+					"from=blamo&to=2019-06-09T00:00:00Z",
+					"from=2019-06-09T00:00:00Z&to=2019-06-01T00:00:00Z",
+					"from=2019-06-09T00:00:00Z&to=2021-06-09T00:00:00Z",
+				} {
+					recorder := httptest.NewRecorder()
+					handler := http.HandlerFunc(h.ScheduleDetailsHandler)
+
+					r, _ := http.NewRequest("GET", fmt.Sprintf("/schedules/%v?%v", s.ID, query), nil)
+					rctx := chi.NewRouteContext()
+					rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+					r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+					handler.ServeHTTP(recorder, r)
+
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest), query)
+				}
+			})
+
 			It("Should return a StatusBadRequest for a non-numerical schedule ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(ScheduleDetailsHandler)
+				handler := http.HandlerFunc(h.ScheduleDetailsHandler)
 
 				r, _ := http.NewRequest("GET", "/schedules/blamo", nil)
 
@@ -136,18 +326,16 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound for a scheduleID that does not have an associated schedule", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(ScheduleDetailsHandler)
+				handler := http.HandlerFunc(h.ScheduleDetailsHandler)
 
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-				}
-				ScheduleCollection[s.ID] = s
+				})
 
-				r, _ := http.NewRequest("GET", "/schedules/32", nil)
+				r, _ := http.NewRequest("GET", fmt.Sprintf("/schedules/%v", s.ID+1), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "32")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID+1))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -159,18 +347,16 @@ var _ = Describe("Handlers", func() {
 		Context("#DeleteSchedule", func() {
 			It("Should return a 200 and the deleted entity upon success", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteScheduleHandler)
+				handler := http.HandlerFunc(h.DeleteScheduleHandler)
 
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-				}
-				ScheduleCollection[s.ID] = s
+				})
 
-				r, _ := http.NewRequest("DELETE", "/schedules/31", nil)
+				r, _ := http.NewRequest("DELETE", fmt.Sprintf("/schedules/%v", s.ID), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "31")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -187,14 +373,14 @@ var _ = Describe("Handlers", func() {
 				Expect(resBody.OwnerName).To(Equal(s.OwnerName))
 				Expect(resBody.Appointments).To(Equal([]Appointment{}))
 
-				if _, found := ScheduleCollection[s.ID]; found {
+				if _, err := store.GetSchedule(s.ID); err == nil {
 					Fail("Schedule should be deleted from storage")
 				}
 			})
 
 			It("Should return a StatusBadRequest for a non-numerical schedule ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteScheduleHandler)
+				handler := http.HandlerFunc(h.DeleteScheduleHandler)
 
 				r, _ := http.NewRequest("DELETE", "/schedules/blamo", nil)
 
@@ -209,18 +395,16 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound for a scheduleID that does not have an associated schedule", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteScheduleHandler)
+				handler := http.HandlerFunc(h.DeleteScheduleHandler)
 
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-				}
-				ScheduleCollection[s.ID] = s
+				})
 
-				r, _ := http.NewRequest("DELETE", "/schedules/32", nil)
+				r, _ := http.NewRequest("DELETE", fmt.Sprintf("/schedules/%v", s.ID+1), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "32")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID+1))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -234,24 +418,21 @@ var _ = Describe("Handlers", func() {
 		Context("#CreateAppointment", func() {
 			It("Should return a StatusCreated and the created entity upon successful creation of a appointment", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateAppointmentHandler)
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
 
-				s := Schedule{
-					ID:           12,
-					OwnerName:    "Tyrion Lannister",
-					Appointments: make(map[int]Appointment),
-				}
-				ScheduleCollection[s.ID] = s
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+				})
 
 				a := Appointment{
-					StartTime: 5,
-					EndTime:   9,
+					StartTime: UnixTimestamp(5),
+					EndTime:   UnixTimestamp(9),
 				}
 				reqBody, _ := json.Marshal(a)
 
-				r, _ := http.NewRequest("POST", "/schedules/12/appointments", bytes.NewReader(reqBody))
+				r, _ := http.NewRequest("POST", fmt.Sprintf("/schedules/%v/appointments", s.ID), bytes.NewReader(reqBody))
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "12")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -269,32 +450,60 @@ var _ = Describe("Handlers", func() {
 				Expect(resBody.ScheduleID).To(Equal(s.ID))
 				Expect(resBody.ID).To(Equal(1))
 
-				scheduleAppts := ScheduleCollection[s.ID].Appointments
+				scheduleAppts, _ := store.ListAppointments(s.ID)
 				Expect(len(scheduleAppts)).To(Equal(1))
-				Expect(scheduleAppts[resBody.ID]).To(Equal(resBody))
+				Expect(scheduleAppts[0]).To(Equal(resBody))
+			})
+
+			It("Should return RFC 3339 times in the schedule's time zone", func() {
+				recorder := httptest.NewRecorder()
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
+
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+					TimeZone:  "America/Denver",
+				})
+
+				reqBody := []byte(`{"start_time": "2019-06-03T15:00:00Z", "end_time": "2019-06-03T16:00:00Z"}`)
+				r, _ := http.NewRequest("POST", fmt.Sprintf("/schedules/%v/appointments", s.ID), bytes.NewReader(reqBody))
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+				handler.ServeHTTP(recorder, r)
+
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+				var resBody map[string]interface{}
+				json.NewDecoder(recorder.Body).Decode(&resBody)
+				Expect(resBody["start_time"]).To(Equal("2019-06-03T09:00:00-06:00"))
+				Expect(resBody["end_time"]).To(Equal("2019-06-03T10:00:00-06:00"))
 			})
 
 			It("Should increment the ID by one for each created appointment", func() {
-				AppointmentsCreatedCount = 7
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateAppointmentHandler)
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
 
-				s := Schedule{
-					ID:           12,
-					OwnerName:    "Tyrion Lannister",
-					Appointments: make(map[int]Appointment),
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+				})
+				for i := 1; i <= 7; i++ {
+					store.CreateAppointment(Appointment{
+						ScheduleID: s.ID,
+						StartTime:  UnixTimestamp(int64(i * 100)),
+						EndTime:    UnixTimestamp(int64(i*100 + 10)),
+					})
 				}
-				ScheduleCollection[s.ID] = s
 
 				a := Appointment{
-					StartTime: 5,
-					EndTime:   9,
+					StartTime: UnixTimestamp(5),
+					EndTime:   UnixTimestamp(9),
 				}
 				reqBody, _ := json.Marshal(a)
 
-				r, _ := http.NewRequest("POST", "/schedules/12/appointments", bytes.NewReader(reqBody))
+				r, _ := http.NewRequest("POST", fmt.Sprintf("/schedules/%v/appointments", s.ID), bytes.NewReader(reqBody))
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "12")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -311,13 +520,103 @@ var _ = Describe("Handlers", func() {
 				Expect(resBody.EndTime).To(Equal(a.EndTime))
 				Expect(resBody.ScheduleID).To(Equal(s.ID))
 				Expect(resBody.ID).To(Equal(8))
+			})
+
+			It("Should reject appointments that only conflict through the schedule's buffers", func() {
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName:     "Tyrion Lannister",
+					OverlapPolicy: OverlapPolicy{BufferBeforeMinutes: 60, BufferAfterMinutes: 60},
+				})
+				store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+					EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+				})
+
+				for _, tc := range []struct {
+					start, end string
+					code       int
+				}{
+					{"2019-06-03T12:00:00Z", "2019-06-03T13:00:00Z", http.StatusUnprocessableEntity},
+					{"2019-06-03T06:00:00Z", "2019-06-03T07:00:00Z", http.StatusUnprocessableEntity},
+					{"2019-06-03T12:00:01Z", "2019-06-03T13:00:00Z", http.StatusCreated},
+					{"2019-06-03T05:00:00Z", "2019-06-03T06:59:59Z", http.StatusCreated},
+				} {
+					reqBody := fmt.Sprintf(`{"start_time": %q, "end_time": %q}`, tc.start, tc.end)
+					recorder := serve(h.CreateAppointmentHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+					Expect(recorder.Code).To(Equal(tc.code), tc.start)
+				}
+			})
 
-				Expect(AppointmentsCreatedCount).To(Equal(8))
+			It("Should reject a recurrence whose UNTIL is before the start", func() {
+				s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+
+				for _, tc := range []struct {
+					until string
+					code  int
+				}{
+					{"20190603T085959Z", http.StatusUnprocessableEntity},
+					{"20190602", http.StatusUnprocessableEntity},
+					{"20190603T090000Z", http.StatusCreated},
+				} {
+					reqBody := fmt.Sprintf(`
+						{
+							"start_time": "2019-06-03T09:00:00Z",
+							"end_time": "2019-06-03T10:00:00Z",
+							"recurrence": "FREQ=DAILY;UNTIL=%v"
+						}
+					`, tc.until)
+					recorder := serve(h.CreateAppointmentHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+					Expect(recorder.Code).To(Equal(tc.code), tc.until)
+					if tc.code == http.StatusUnprocessableEntity {
+						Expect(recorder.Body.String()).To(ContainSubstring(RuleUntilAfterStart))
+					}
+				}
+			})
+
+			It("Should only book one of several simultaneous requests for overlapping times", func() {
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
+
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+				})
+
+				var wg sync.WaitGroup
+				codes := make([]int, 10)
+				for i := range codes {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						recorder := httptest.NewRecorder()
+
+						reqBody, _ := json.Marshal(Appointment{StartTime: UnixTimestamp(int64(5 + i)), EndTime: UnixTimestamp(20)})
+						r, _ := http.NewRequest("POST", fmt.Sprintf("/schedules/%v/appointments", s.ID), bytes.NewReader(reqBody))
+						rctx := chi.NewRouteContext()
+						rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+						r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+						handler.ServeHTTP(recorder, r)
+						codes[i] = recorder.Code
+					}(i)
+				}
+				wg.Wait()
+
+				Expect(codes).To(ContainElement(http.StatusCreated))
+				created := 0
+				for _, code := range codes {
+					if code == http.StatusCreated {
+						created++
+					}
+				}
+				Expect(created).To(Equal(1))
+
+				appointments, _ := store.ListAppointments(s.ID)
+				Expect(appointments).To(HaveLen(1))
 			})
 
 			It("Should return a StatusBadRequest for a non-numerical schedule ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateAppointmentHandler)
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
 
 				r, _ := http.NewRequest("POST", "/schedules/blamo/appointments", nil)
 
@@ -332,7 +631,7 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusBadRequest if the reqBody is invalid", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateAppointmentHandler)
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
 
 				reqBody := []byte(`{"start_time": true}`)
 
@@ -348,11 +647,11 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound for a scheduleID that does not have an associated schedule", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateAppointmentHandler)
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
 
 				a := Appointment{
-					StartTime: 5,
-					EndTime:   9,
+					StartTime: UnixTimestamp(5),
+					EndTime:   UnixTimestamp(9),
 				}
 				reqBody, _ := json.Marshal(a)
 
@@ -369,63 +668,189 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusUnprocessableEntity for invalid appointment times", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(CreateAppointmentHandler)
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
 
-				scheduledAppts := map[int]Appointment{
-					5: Appointment{
-						StartTime: 7,
-						EndTime:   15,
-					},
-				}
-				s := Schedule{
-					ID:           12,
-					OwnerName:    "Tyrion Lannister",
-					Appointments: scheduledAppts,
-				}
-				ScheduleCollection[s.ID] = s
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+				})
+				store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  UnixTimestamp(7),
+					EndTime:    UnixTimestamp(15),
+				})
 
 				a := Appointment{
-					StartTime: 5,
-					EndTime:   9,
+					StartTime: UnixTimestamp(5),
+					EndTime:   UnixTimestamp(9),
 				}
 				reqBody, _ := json.Marshal(a)
 
-				r, _ := http.NewRequest("POST", "/schedules/12/appointments", bytes.NewReader(reqBody))
+				r, _ := http.NewRequest("POST", fmt.Sprintf("/schedules/%v/appointments", s.ID), bytes.NewReader(reqBody))
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "12")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
 
 				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
 			})
+
+			It("Should explain the rejection as problem details listing the conflicting appointments", func() {
+				recorder := httptest.NewRecorder()
+				handler := http.HandlerFunc(h.CreateAppointmentHandler)
+
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+				})
+				existing, _ := store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+					EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+				})
+
+				reqBody := []byte(`{"start_time": "2019-06-03T09:30:00Z", "end_time": "2019-06-03T10:30:00Z"}`)
+				r, _ := http.NewRequest("POST", fmt.Sprintf("/schedules/%v/appointments", s.ID), bytes.NewReader(reqBody))
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+				handler.ServeHTTP(recorder, r)
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+
+				var problem Problem
+				json.NewDecoder(recorder.Body).Decode(&problem)
+				Expect(problem.Status).To(Equal(http.StatusUnprocessableEntity))
+				Expect(problem.Violations).To(HaveLen(1))
+				Expect(problem.Violations[0].Rule).To(Equal(RuleNoConflict))
+				Expect(problem.Violations[0].Conflicts).To(HaveLen(1))
+				Expect(problem.Violations[0].Conflicts[0].AppointmentID).To(Equal(existing.ID))
+				Expect(problem.Violations[0].Conflicts[0].StartTime.Format(time.RFC3339)).To(Equal("2019-06-03T09:00:00Z"))
+			})
+		})
+
+		Context("#ListAppointments", func() {
+			It("Should page through the occurrences within the window in start order", func() {
+				s, _ := store.CreateSchedule(Schedule{
+					OwnerName: "Tyrion Lannister",
+					TimeZone:  "America/Denver",
+				})
+				start := time.Date(2019, 6, 3, 9, 0, 0, 0, s.Location())
+				rule, _ := ParseRecurrenceRule("FREQ=DAILY")
+				store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  NewTimestamp(start),
+					EndTime:    NewTimestamp(start.Add(time.Hour)),
+					Recurrence: rule,
+				})
+				store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  NewTimestamp(start.AddDate(0, 0, 7).Add(3 * time.Hour)),
+					EndTime:    NewTimestamp(start.AddDate(0, 0, 7).Add(4 * time.Hour)),
+				})
+				store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  NewTimestamp(start.AddDate(0, 0, 14).Add(3 * time.Hour)),
+					EndTime:    NewTimestamp(start.AddDate(0, 0, 14).Add(4 * time.Hour)),
+				})
+
+				var starts []string
+				cursor := ""
+				for page := 0; page < 5; page++ {
+					recorder := httptest.NewRecorder()
+					handler := http.HandlerFunc(h.ListAppointmentsHandler)
+
+					r, _ := http.NewRequest("GET", "/?from=2019-06-09T00:00:00-06:00&to=2019-06-12T00:00:00-06:00&limit=2&cursor="+cursor, nil)
+					rctx := chi.NewRouteContext()
+					rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+					r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+					handler.ServeHTTP(recorder, r)
+
+					Expect(recorder.Code).To(Equal(http.StatusOK))
+
+					var resBody struct {
+						Appointments []map[string]interface{} `json:"appointments"`
+						NextCursor   string                   `json:"next_cursor"`
+					}
+					json.NewDecoder(recorder.Body).Decode(&resBody)
+					Expect(len(resBody.Appointments)).To(BeNumerically("<=", 2))
+					for _, a := range resBody.Appointments {
+						starts = append(starts, a["start_time"].(string))
+					}
+
+					cursor = resBody.NextCursor
+					if cursor == "" {
+						break
+					}
+				}
+
+				Expect(starts).To(Equal([]string{
+					"2019-06-09T09:00:00-06:00",
+					"2019-06-10T09:00:00-06:00",
+					"2019-06-10T12:00:00-06:00",
+					"2019-06-11T09:00:00-06:00",
+				}))
+			})
+
+			It("Should return a StatusBadRequest without a valid window, limit or cursor", func() {
+				s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+
+				for _, query := range []string{
+					"",
+					"from=2019-06-09T00:00:00Z",
+					"from=2019-06-09T00:00:00Z&to=2019-06-10T00:00:00Z&limit=0",
+					"from=2019-06-09T00:00:00Z&to=2019-06-10T00:00:00Z&cursor=blamo",
+				} {
+					recorder := httptest.NewRecorder()
+					handler := http.HandlerFunc(h.ListAppointmentsHandler)
+
+					r, _ := http.NewRequest("GET", "/?"+query, nil)
+					rctx := chi.NewRouteContext()
+					rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+					r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+					handler.ServeHTTP(recorder, r)
+
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest), query)
+				}
+			})
+
+			It("Should return a StatusNotFound for a missing schedule", func() {
+				recorder := httptest.NewRecorder()
+				handler := http.HandlerFunc(h.ListAppointmentsHandler)
+
+				r, _ := http.NewRequest("GET", "/?from=2019-06-09T00:00:00Z&to=2019-06-10T00:00:00Z", nil)
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("scheduleID", "32")
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+				handler.ServeHTTP(recorder, r)
+
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
 		})
 
 		Context("#AppointmentDetails", func() {
 			It("Should return appointment details for the given appointmentID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(AppointmentDetailsHandler)
+				handler := http.HandlerFunc(h.AppointmentDetailsHandler)
 
-				a := Appointment{
-					ID:         12,
-					ScheduleID: 29,
-					StartTime:  5,
-					EndTime:    90,
-				}
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-					Appointments: map[int]Appointment{
-						a.ID: a,
-					},
-				}
-				ScheduleCollection[s.ID] = s
+				})
+				a, _ := store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  UnixTimestamp(5),
+					EndTime:    UnixTimestamp(90),
+				})
 
-				r, _ := http.NewRequest("GET", "/schedules/31/appointments/12", nil)
+				r, _ := http.NewRequest("GET", fmt.Sprintf("/schedules/%v/appointments/%v", s.ID, a.ID), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("appointmentID", "12")
-				rctx.URLParams.Add("scheduleID", "31")
+				rctx.URLParams.Add("appointmentID", strconv.Itoa(a.ID))
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -443,7 +868,7 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusBadRequest for a non-numerical schedule ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(AppointmentDetailsHandler)
+				handler := http.HandlerFunc(h.AppointmentDetailsHandler)
 
 				r, _ := http.NewRequest("GET", "schedules/blamo/appointments/12", nil)
 
@@ -459,7 +884,7 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusBadRequest for a non-numerical appointment ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(AppointmentDetailsHandler)
+				handler := http.HandlerFunc(h.AppointmentDetailsHandler)
 
 				r, _ := http.NewRequest("GET", "schedules/13/appointments/blamo", nil)
 
@@ -475,7 +900,7 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound if the schedule is not found", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(AppointmentDetailsHandler)
+				handler := http.HandlerFunc(h.AppointmentDetailsHandler)
 
 				r, _ := http.NewRequest("GET", "/schedule/12/appointments/13", nil)
 
@@ -491,28 +916,22 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound if the appointment is not found on the provided schedule", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(AppointmentDetailsHandler)
+				handler := http.HandlerFunc(h.AppointmentDetailsHandler)
 
-				a := Appointment{
-					ID:         12,
-					ScheduleID: 29,
-					StartTime:  5,
-					EndTime:    90,
-				}
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-					Appointments: map[int]Appointment{
-						a.ID: a,
-					},
-				}
-				ScheduleCollection[s.ID] = s
+				})
+				a, _ := store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  UnixTimestamp(5),
+					EndTime:    UnixTimestamp(90),
+				})
 
-				r, _ := http.NewRequest("GET", "/schedule/31/appointments/12", nil)
+				r, _ := http.NewRequest("GET", fmt.Sprintf("/schedules/%v/appointments/%v", s.ID, a.ID+1), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "31")
-				rctx.URLParams.Add("appointmentID", "13")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				rctx.URLParams.Add("appointmentID", strconv.Itoa(a.ID+1))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -524,27 +943,21 @@ var _ = Describe("Handlers", func() {
 		Context("#DeleteAppointment", func() {
 			It("Should return a 200 and the deleted entity upon success", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteAppointmentHandler)
+				handler := http.HandlerFunc(h.DeleteAppointmentHandler)
 
-				a := Appointment{
-					ID:         12,
-					ScheduleID: 29,
-					StartTime:  5,
-					EndTime:    90,
-				}
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-					Appointments: map[int]Appointment{
-						a.ID: a,
-					},
-				}
-				ScheduleCollection[s.ID] = s
-				r, _ := http.NewRequest("DELETE", "/schedules/31/appointments/12", nil)
+				})
+				a, _ := store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  UnixTimestamp(5),
+					EndTime:    UnixTimestamp(90),
+				})
+				r, _ := http.NewRequest("DELETE", fmt.Sprintf("/schedules/%v/appointments/%v", s.ID, a.ID), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "31")
-				rctx.URLParams.Add("appointmentID", "12")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				rctx.URLParams.Add("appointmentID", strconv.Itoa(a.ID))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -559,18 +972,17 @@ var _ = Describe("Handlers", func() {
 
 				Expect(resBody).To(Equal(a))
 
-				if foundSchedule, found := ScheduleCollection[s.ID]; found {
-					if _, foundAppt := foundSchedule.Appointments[a.ID]; foundAppt {
-						Fail("Schedule should be deleted from storage")
-					}
-				} else {
+				if _, err := store.GetSchedule(s.ID); err != nil {
 					Fail("Should have found schedule")
 				}
+				if _, err := store.GetAppointment(s.ID, a.ID); err == nil {
+					Fail("Appointment should be deleted from storage")
+				}
 			})
 
 			It("Should return a StatusBadRequest for a non-numerical schedule ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteAppointmentHandler)
+				handler := http.HandlerFunc(h.DeleteAppointmentHandler)
 
 				r, _ := http.NewRequest("DELETE", "schedules/blamo/appointments/12", nil)
 
@@ -586,7 +998,7 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusBadRequest for a non-numerical appointment ID", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteAppointmentHandler)
+				handler := http.HandlerFunc(h.DeleteAppointmentHandler)
 
 				r, _ := http.NewRequest("DELETE", "schedules/13/appointments/blamo", nil)
 
@@ -602,7 +1014,7 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound if the schedule is not found", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteAppointmentHandler)
+				handler := http.HandlerFunc(h.DeleteAppointmentHandler)
 
 				r, _ := http.NewRequest("DELETE", "/schedule/12/appointments/13", nil)
 
@@ -618,28 +1030,22 @@ var _ = Describe("Handlers", func() {
 
 			It("Should return a StatusNotFound if the appointment is not found on the provided schedule", func() {
 				recorder := httptest.NewRecorder()
-				handler := http.HandlerFunc(DeleteAppointmentHandler)
+				handler := http.HandlerFunc(h.DeleteAppointmentHandler)
 
-				a := Appointment{
-					ID:         12,
-					ScheduleID: 29,
-					StartTime:  5,
-					EndTime:    90,
-				}
-				s := Schedule{
-					ID:        31,
+				s, _ := store.CreateSchedule(Schedule{
 					OwnerName: "Tyrion Lannister",
-					Appointments: map[int]Appointment{
-						a.ID: a,
-					},
-				}
-				ScheduleCollection[s.ID] = s
+				})
+				a, _ := store.CreateAppointment(Appointment{
+					ScheduleID: s.ID,
+					StartTime:  UnixTimestamp(5),
+					EndTime:    UnixTimestamp(90),
+				})
 
-				r, _ := http.NewRequest("DELETE", "/schedule/31/appointments/12", nil)
+				r, _ := http.NewRequest("DELETE", fmt.Sprintf("/schedules/%v/appointments/%v", s.ID, a.ID+1), nil)
 
 				rctx := chi.NewRouteContext()
-				rctx.URLParams.Add("scheduleID", "31")
-				rctx.URLParams.Add("appointmentID", "13")
+				rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+				rctx.URLParams.Add("appointmentID", strconv.Itoa(a.ID+1))
 				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
 
 				handler.ServeHTTP(recorder, r)
@@ -0,0 +1,65 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+// serve runs handlerFunc on a request as if routed by chi. params lists the
+// request's URL params as name, value pairs.
+func serve(handlerFunc http.HandlerFunc, method, target, reqBody string, params ...string) *httptest.ResponseRecorder {
+	return serveWithHeaders(handlerFunc, method, target, reqBody, nil, params...)
+}
+
+// serveWithHeaders is serve for a request carrying the given headers.
+func serveWithHeaders(handlerFunc http.HandlerFunc, method, target, reqBody string, headers map[string]string, params ...string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, target, bytes.NewReader([]byte(reqBody)))
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+
+	return serveRequest(handlerFunc, r, params...)
+}
+
+// serveChunked is serve for a request whose body has no declared length, as
+// when it is sent chunked.
+func serveChunked(handlerFunc http.HandlerFunc, method, target, reqBody string, params ...string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, target, bytes.NewReader([]byte(reqBody)))
+	r.ContentLength = -1
+	r.TransferEncoding = []string{"chunked"}
+
+	return serveRequest(handlerFunc, r, params...)
+}
+
+// serveRequest runs handlerFunc on r with the given URL params.
+func serveRequest(handlerFunc http.HandlerFunc, r *http.Request, params ...string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+
+	rctx := chi.NewRouteContext()
+	for i := 0; i < len(params); i += 2 {
+		rctx.URLParams.Add(params[i], params[i+1])
+	}
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	handlerFunc.ServeHTTP(recorder, r)
+	return recorder
+}
+
+// bookAppointment books start to start+duration on schedule s, failing the
+// test if store refuses it.
+func bookAppointment(store Store, s Schedule, start time.Time, duration time.Duration) {
+	_, err := store.CreateAppointment(Appointment{
+		ScheduleID: s.ID,
+		StartTime:  NewTimestamp(start),
+		EndTime:    NewTimestamp(start.Add(duration)),
+	})
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+}
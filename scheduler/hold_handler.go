@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+func (h *Handler) CreateHoldHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	var body struct {
+		StartTime  Timestamp `json:"start_time"`
+		EndTime    Timestamp `json:"end_time"`
+		TTLSeconds int       `json:"ttl_seconds"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		log.Println("CreateHoldHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	ttl := time.Duration(body.TTLSeconds) * time.Second
+	held := Hold{StartTime: body.StartTime, EndTime: body.EndTime}
+	hold, err := createHold(h.Store, held, ttl, scheduleID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("CreateHoldHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to create hold")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusCreated, hold)
+}
+
+func (h *Handler) HoldDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, holdID, ok := holdParams(w, r)
+	if !ok {
+		return
+	}
+
+	hold, err := getHold(h.Store, scheduleID, holdID)
+	if err != nil {
+		log.Println("HoldDetailsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to retrieve hold")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, hold)
+}
+
+func (h *Handler) DeleteHoldHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, holdID, ok := holdParams(w, r)
+	if !ok {
+		return
+	}
+
+	hold, err := releaseHold(h.Store, scheduleID, holdID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("DeleteHoldHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete hold")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, hold)
+}
+
+// BookHoldHandler converts a hold into an appointment. The optional body
+// gives the appointment's details and initial status; its times are the
+// hold's.
+func (h *Handler) BookHoldHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, holdID, ok := holdParams(w, r)
+	if !ok {
+		return
+	}
+
+	var a Appointment
+	err := json.NewDecoder(r.Body).Decode(&a)
+	if err != nil && err != io.EOF {
+		log.Println("BookHoldHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	a, err = bookHold(h.Store, scheduleID, holdID, a, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("BookHoldHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to book hold")
+		return
+	}
+
+	w.Header().Set("ETag", appointmentTag(a))
+	http_helpers.RespondWithJSON(w, http.StatusCreated, a)
+}
+
+// holdParams reads the schedule and hold URL params, responding with a 400
+// and returning false if either is invalid.
+func holdParams(w http.ResponseWriter, r *http.Request) (scheduleID, holdID int, ok bool) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	holdID, err = convertIDParam(r, "holdID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid hold ID")
+		return
+	}
+
+	return scheduleID, holdID, true
+}
@@ -0,0 +1,256 @@
+package scheduler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Hold Handlers", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+		s     Schedule
+	)
+
+	hold := func(reqBody string) (*httptest.ResponseRecorder, Hold) {
+		recorder := serve(h.CreateHoldHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+
+		var created Hold
+		json.NewDecoder(recorder.Body).Decode(&created)
+		return recorder, created
+	}
+
+	book := func(start, end string) *httptest.ResponseRecorder {
+		reqBody := fmt.Sprintf(`{"start_time": %q, "end_time": %q}`, start, end)
+		return serve(h.CreateAppointmentHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+	})
+
+	It("Should block the held time until the hold is released", func() {
+		before := time.Now()
+		recorder, held := hold(`
+			{
+				"start_time": "2030-06-03T09:00:00-06:00",
+				"end_time": "2030-06-03T10:00:00-06:00",
+				"ttl_seconds": 120
+			}
+		`)
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(held.ScheduleID).To(Equal(s.ID))
+		Expect(held.ExpiresAt.Sub(before)).To(BeNumerically("~", 2*time.Minute, 2*time.Second))
+
+		recorder = book("2030-06-03T09:30:00-06:00", "2030-06-03T10:30:00-06:00")
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+		var problem Problem
+		json.NewDecoder(recorder.Body).Decode(&problem)
+		Expect(problem.Violations).To(HaveLen(1))
+		Expect(problem.Violations[0].Rule).To(Equal(RuleNoHold))
+		Expect(problem.Violations[0].HoldIDs).To(Equal([]int{held.ID}))
+
+		recorder, _ = hold(`{"start_time": "2030-06-03T09:30:00-06:00", "end_time": "2030-06-03T10:30:00-06:00"}`)
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+		recorder = serve(h.HoldDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		recorder = serve(h.DeleteHoldHandler, "DELETE", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		recorder = serve(h.HoldDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+		recorder = book("2030-06-03T09:30:00-06:00", "2030-06-03T10:30:00-06:00")
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+	})
+
+	It("Should change nothing when If-Match does not list the schedule's ETag", func() {
+		reqBody := `{"start_time": "2030-06-03T09:00:00-06:00", "end_time": "2030-06-03T10:00:00-06:00"}`
+		stale := map[string]string{"If-Match": `"42"`}
+
+		recorder := serveWithHeaders(h.CreateHoldHandler, "POST", "/", reqBody, stale, "scheduleID", strconv.Itoa(s.ID))
+		Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+
+		_, held := hold(reqBody)
+		recorder = serveWithHeaders(h.DeleteHoldHandler, "DELETE", "/", "", stale,
+			"scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+		recorder = serveWithHeaders(h.BookHoldHandler, "POST", "/", "", stale,
+			"scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+
+		schedule, _ := store.GetSchedule(s.ID)
+		Expect(schedule.Appointments).To(BeEmpty())
+		Expect(schedule.Holds).To(HaveLen(1))
+
+		etag := serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID)).Header().Get("ETag")
+		recorder = serveWithHeaders(h.BookHoldHandler, "POST", "/", "", map[string]string{"If-Match": etag},
+			"scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+	})
+
+	It("Should not hold time that is already booked", func() {
+		Expect(book("2030-06-03T09:00:00-06:00", "2030-06-03T10:00:00-06:00").Code).To(Equal(http.StatusCreated))
+
+		recorder, _ := hold(`{"start_time": "2030-06-03T09:30:00-06:00", "end_time": "2030-06-03T10:30:00-06:00"}`)
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("Should ignore expired holds", func() {
+		expired, _ := store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2030, 6, 3, 15, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2030, 6, 3, 16, 0, 0, 0, time.UTC)),
+			ExpiresAt:  NewTimestamp(time.Now().Add(-time.Second)),
+		})
+
+		recorder := serve(h.HoldDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(expired.ID))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+		recorder = serve(h.BookHoldHandler, "POST", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(expired.ID))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+		Expect(book("2030-06-03T09:00:00-06:00", "2030-06-03T10:00:00-06:00").Code).To(Equal(http.StatusCreated))
+	})
+
+	It("Should keep an expired hold on the schedule until it is swept", func() {
+		store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2030, 6, 3, 15, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2030, 6, 3, 16, 0, 0, 0, time.UTC)),
+			ExpiresAt:  NewTimestamp(time.Now().Add(-time.Second)),
+		})
+
+		recorder := serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID))
+		etag := recorder.Header().Get("ETag")
+		var resBody ScheduleResponse
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		Expect(resBody.Holds).To(HaveLen(1))
+
+		store.DeleteExpiredHolds(time.Now())
+
+		recorder = serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID))
+		Expect(recorder.Header().Get("ETag")).NotTo(Equal(etag))
+		resBody = ScheduleResponse{}
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		Expect(resBody.Holds).To(BeEmpty())
+	})
+
+	It("Should convert a hold into an appointment", func() {
+		_, held := hold(`{"start_time": "2030-06-03T09:00:00-06:00", "end_time": "2030-06-03T10:00:00-06:00"}`)
+
+		recorder := serve(h.BookHoldHandler, "POST", "/", `{"title": "Small council", "status": "tentative"}`,
+			"scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+		var a Appointment
+		json.NewDecoder(recorder.Body).Decode(&a)
+		Expect(a.StartTime.Equal(held.StartTime.Time)).To(BeTrue())
+		Expect(a.EndTime.Equal(held.EndTime.Time)).To(BeTrue())
+		Expect(a.Title).To(Equal("Small council"))
+		Expect(a.Status).To(Equal(StatusTentative))
+
+		updated, _ := store.GetSchedule(s.ID)
+		Expect(updated.Holds).To(BeEmpty())
+		Expect(updated.Appointments).To(HaveKey(a.ID))
+
+		recorder = serve(h.BookHoldHandler, "POST", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should book a hold when a body of unknown length is empty", func() {
+		_, held := hold(`{"start_time": "2030-06-03T09:00:00-06:00", "end_time": "2030-06-03T10:00:00-06:00"}`)
+		params := []string{"scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID)}
+
+		recorder := serveChunked(h.BookHoldHandler, "POST", "/", "{", params...)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder = serveChunked(h.BookHoldHandler, "POST", "/", "", params...)
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		var a Appointment
+		json.NewDecoder(recorder.Body).Decode(&a)
+		Expect(a.Status).To(Equal(StatusConfirmed))
+	})
+
+	It("Should book a hold regardless of the lead time left", func() {
+		s.BookingPolicy = BookingPolicy{MinLeadTimeMinutes: 60}
+		store.UpdateSchedule(s)
+
+		start := time.Now().Add(2 * time.Hour).Truncate(time.Hour)
+		held, _ := store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start.Add(-90 * time.Minute)),
+			EndTime:    NewTimestamp(start),
+			ExpiresAt:  NewTimestamp(time.Now().Add(time.Minute)),
+		})
+
+		recorder := serve(h.BookHoldHandler, "POST", "/", "", "scheduleID", strconv.Itoa(s.ID), "holdID", strconv.Itoa(held.ID))
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+	})
+
+	It("Should show active holds on the schedule and as busy time", func() {
+		_, held := hold(`{"start_time": "2030-06-03T09:00:00-06:00", "end_time": "2030-06-03T10:00:00-06:00"}`)
+
+		recorder := serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID))
+		var resBody ScheduleResponse
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		Expect(resBody.Holds).To(HaveLen(1))
+		Expect(resBody.Holds[0].ID).To(Equal(held.ID))
+
+		recorder = serve(h.FreeBusyHandler, "GET",
+			fmt.Sprintf("/?schedule_ids=%v&from=2030-06-03T00:00:00-06:00&to=2030-06-04T00:00:00-06:00", s.ID), "")
+		var freeBusy FreeBusy
+		json.NewDecoder(recorder.Body).Decode(&freeBusy)
+		Expect(freeBusy.Schedules[0].Busy).To(HaveLen(1))
+		Expect(freeBusy.Schedules[0].Busy[0].Start.Equal(held.StartTime.Time)).To(BeTrue())
+	})
+
+	It("Should reject TTLs out of range", func() {
+		for _, ttl := range []int{-1, 3601} {
+			recorder, _ := hold(fmt.Sprintf(
+				`{"start_time": "2030-06-03T09:00:00-06:00", "end_time": "2030-06-03T10:00:00-06:00", "ttl_seconds": %v}`, ttl,
+			))
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		}
+	})
+
+	It("Should sweep expired holds in the background", func() {
+		store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2030, 6, 3, 15, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2030, 6, 3, 16, 0, 0, 0, time.UTC)),
+			ExpiresAt:  NewTimestamp(time.Now().Add(-time.Second)),
+		})
+		active, _ := store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2030, 6, 4, 15, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2030, 6, 4, 16, 0, 0, 0, time.UTC)),
+			ExpiresAt:  NewTimestamp(time.Now().Add(time.Hour)),
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go SweepHolds(ctx, store, 10*time.Millisecond)
+
+		Eventually(func() []Hold {
+			s, _ := store.GetSchedule(s.ID)
+			return s.Holds
+		}).Should(Equal([]Hold{active}))
+	})
+})
@@ -0,0 +1,227 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const (
+	defaultHoldTTL = 5 * time.Minute
+	maxHoldTTL     = time.Hour
+)
+
+// Hold reserves time on a schedule until ExpiresAt, typically while a client
+// collects the details needed to book it. Until then the time is busy to
+// everyone else; afterwards the hold is ignored and eventually swept away.
+type Hold struct {
+	ID         int       `json:"id"`
+	ScheduleID int       `json:"schedule_id"`
+	StartTime  Timestamp `json:"start_time"`
+	EndTime    Timestamp `json:"end_time"`
+	ExpiresAt  Timestamp `json:"expires_at"`
+}
+
+func (h Hold) interval() Interval {
+	return Interval{Start: h.StartTime, End: h.EndTime}
+}
+
+func (h Hold) active(now time.Time) bool {
+	return now.Before(h.ExpiresAt.Time)
+}
+
+// activeHolds returns the holds on s that have not expired by now.
+func (s Schedule) activeHolds(now time.Time) []Hold {
+	var active []Hold
+	for _, h := range s.Holds {
+		if h.active(now) {
+			active = append(active, h)
+		}
+	}
+
+	return active
+}
+
+// findHold returns the hold holdID on s, treating expired holds as gone.
+func (s Schedule) findHold(holdID int, now time.Time) (Hold, error) {
+	for _, h := range s.activeHolds(now) {
+		if h.ID == holdID {
+			return h, nil
+		}
+	}
+
+	return Hold{}, holdNotFound(holdID)
+}
+
+// holdsOverlapping returns the IDs of the active holds on s that conflict
+// with any of proposed under the schedule's overlap policy.
+func holdsOverlapping(s Schedule, proposed []Appointment, now time.Time) []int {
+	p := s.OverlapPolicy
+
+	var ids []int
+	for _, h := range s.activeHolds(now) {
+		blocked := p.blocked(h.interval())
+		for _, a := range proposed {
+			if p.conflicts(p.blocked(a.interval()), blocked) {
+				ids = append(ids, h.ID)
+				break
+			}
+		}
+	}
+
+	return ids
+}
+
+// createHold reserves the times of h on a schedule for ttl, or
+// defaultHoldTTL when ttl is zero. The times are validated like an
+// appointment's, so a hold cannot take time that is already booked or held.
+func createHold(store Store, h Hold, ttl time.Duration, scheduleID int, ifMatch string) (created Hold, err error) {
+	if ttl == 0 {
+		ttl = defaultHoldTTL
+	}
+	if ttl < time.Second || ttl > maxHoldTTL {
+		return h, http_helpers.HttpError{
+			Message:    fmt.Sprintf("ttl_seconds must be between 1 and %v", int(maxHoldTTL.Seconds())),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		a := Appointment{ScheduleID: scheduleID, StartTime: h.StartTime, EndTime: h.EndTime}
+		s, err := scheduleFor(tx, scheduleID, a)
+		if err != nil {
+			log.Println("createHold - ", err.Error())
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		a = appointmentIn(a, s.Location())
+		if err = ValidateAppointmentInput(s, a); err != nil {
+			return err
+		}
+
+		h.ScheduleID, h.StartTime, h.EndTime = s.ID, a.StartTime, a.EndTime
+		h.ExpiresAt = NewTimestamp(time.Now().Truncate(time.Second).Add(ttl).In(s.Location()))
+		created, err = tx.CreateHold(h)
+		return err
+	})
+	return
+}
+
+// bookHold turns a hold into an appointment at its times, with the details
+// and initial status of a. The booking policy's lead time and advance horizon
+// were met when the time was held and are not checked again.
+func bookHold(store Store, scheduleID, holdID int, a Appointment, ifMatch string) (created Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		s, err := scheduleFor(tx, scheduleID)
+		if err != nil {
+			log.Println("bookHold - ", err.Error())
+			return err
+		}
+
+		h, err := s.findHold(holdID, time.Now())
+		if err != nil {
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		a = Appointment{
+			ScheduleID:         s.ID,
+			StartTime:          h.StartTime,
+			EndTime:            h.EndTime,
+			AppointmentDetails: a.AppointmentDetails,
+			Status:             a.Status,
+		}
+		if s, err = scheduleFor(tx, scheduleID, a); err != nil {
+			return err
+		}
+		if err = checkDetails(a.AppointmentDetails); err != nil {
+			return err
+		}
+		if err = checkInitialStatus(&a); err != nil {
+			return err
+		}
+
+		s.Holds = removeHold(s.Holds, h.ID)
+		if err = ValidateAppointmentInput(s, a); err != nil {
+			if err = err.(ValidationError).without(RuleMinLeadTime, RuleMaxAdvance); err != nil {
+				return err
+			}
+		}
+
+		if _, err = tx.DeleteHold(s.ID, h.ID); err != nil {
+			return err
+		}
+
+		created, err = tx.CreateAppointment(a)
+		return err
+	})
+	return
+}
+
+func getHold(store Store, scheduleID, holdID int) (Hold, error) {
+	s, err := scheduleFor(store, scheduleID)
+	if err != nil {
+		return Hold{}, err
+	}
+
+	return s.findHold(holdID, time.Now())
+}
+
+// releaseHold gives up a hold before it expires.
+func releaseHold(store Store, scheduleID, holdID int, ifMatch string) (released Hold, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		s, err := scheduleFor(tx, scheduleID)
+		if err != nil {
+			return err
+		}
+		if _, err = s.findHold(holdID, time.Now()); err != nil {
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		released, err = tx.DeleteHold(scheduleID, holdID)
+		return err
+	})
+	return
+}
+
+func removeHold(holds []Hold, id int) []Hold {
+	var remaining []Hold
+	for _, h := range holds {
+		if h.ID != id {
+			remaining = append(remaining, h)
+		}
+	}
+
+	return remaining
+}
+
+// SweepHolds deletes expired holds from store every interval until ctx is
+// done. Expired holds are already ignored; sweeping keeps them from piling
+// up.
+func SweepHolds(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := store.DeleteExpiredHolds(now); err != nil {
+				log.Println("SweepHolds - ", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+// Defaults for NewHandler's IdempotencyCache.
+const (
+	DefaultIdempotencyWindow     = 24 * time.Hour
+	DefaultIdempotencyMaxEntries = 10000
+	maxIdempotencyKeyLength      = 255
+)
+
+// IdempotencyCache remembers the responses to requests carrying an
+// Idempotency-Key header for a window of time, so that retries are answered
+// with the original response instead of being processed again. It keeps at
+// most maxEntries keys, forgetting the oldest first, and lives in memory
+// only, whatever the store.
+type IdempotencyCache struct {
+	window     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds the entries oldest first.
+	order *list.List
+}
+
+type idempotencyEntry struct {
+	scope       string
+	fingerprint [sha256.Size]byte
+	created     time.Time
+	done        bool
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+func NewIdempotencyCache(window time.Duration, maxEntries int) *IdempotencyCache {
+	return &IdempotencyCache{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// begin claims scope for a new request with the given body fingerprint. It
+// returns the stored entry when the request is a retry whose response can be
+// replayed, and nil when the caller should process it and then call finish.
+func (c *IdempotencyCache) begin(scope string, fingerprint [sha256.Size]byte) (*idempotencyEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for oldest := c.order.Front(); oldest != nil; oldest = c.order.Front() {
+		if now.Sub(oldest.Value.(*idempotencyEntry).created) <= c.window {
+			break
+		}
+		c.remove(oldest)
+	}
+
+	if el, found := c.entries[scope]; found {
+		e := el.Value.(*idempotencyEntry)
+		switch {
+		case e.fingerprint != fingerprint:
+			return nil, http_helpers.HttpError{
+				Message:    "Idempotency-Key was already used with a different request body",
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		case !e.done:
+			return nil, http_helpers.HttpError{
+				Message:    "A request with this Idempotency-Key is still in progress",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return e, nil
+	}
+
+	for c.order.Len() >= c.maxEntries {
+		c.remove(c.order.Front())
+	}
+	c.entries[scope] = c.order.PushBack(&idempotencyEntry{scope: scope, fingerprint: fingerprint, created: now})
+	return nil, nil
+}
+
+func (c *IdempotencyCache) remove(el *list.Element) {
+	delete(c.entries, el.Value.(*idempotencyEntry).scope)
+	c.order.Remove(el)
+}
+
+// finish stores the response to the request that claimed scope. Server
+// errors and requests that never responded are not kept, leaving the key free
+// for a retry.
+func (c *IdempotencyCache) finish(scope string, status int, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.entries[scope]
+	if !found {
+		return
+	}
+	if status == 0 || status >= http.StatusInternalServerError {
+		c.remove(el)
+		return
+	}
+
+	e := el.Value.(*idempotencyEntry)
+	e.done, e.status, e.header, e.body = true, status, header, body
+}
+
+// Idempotent wraps a POST handler so that requests with an Idempotency-Key
+// header run at most once per key and body within the cache's window.
+func (h *Handler) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || h.Idempotency == nil {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http_helpers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key may not exceed %v characters", maxIdempotencyKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Println("Idempotent Err: ", err.Error())
+			http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := r.Method + " " + r.URL.Path + " " + key
+		stored, err := h.Idempotency.begin(scope, sha256.Sum256(body))
+		if err != nil {
+			respondWithServiceError(w, err, "Unable to process request")
+			return
+		}
+		if stored != nil {
+			for name, values := range stored.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			// Also runs when next panics, releasing the key.
+			h.Idempotency.finish(scope, rec.status, w.Header().Clone(), rec.body.Bytes())
+		}()
+		next(rec, r)
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
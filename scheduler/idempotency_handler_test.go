@@ -0,0 +1,134 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Idempotent Handler", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+	)
+
+	createSchedule := func(key, reqBody string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler := h.Idempotent(h.CreateScheduleHandler)
+
+		r, _ := http.NewRequest("POST", "/schedules", bytes.NewReader([]byte(reqBody)))
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	countSchedules := func() int {
+		_, total, _ := store.ListSchedules(ScheduleQuery{Limit: 10})
+		return total
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+	})
+
+	It("Should replay the first response to a retry with the same key and body", func() {
+		first := createSchedule("retry-me", `{"owner_name": "Tyrion Lannister"}`)
+		Expect(first.Code).To(Equal(http.StatusCreated))
+
+		retry := createSchedule("retry-me", `{"owner_name": "Tyrion Lannister"}`)
+
+		Expect(retry.Code).To(Equal(http.StatusCreated))
+		Expect(retry.Body.String()).To(Equal(first.Body.String()))
+		Expect(retry.Header().Get("Idempotent-Replayed")).To(Equal("true"))
+		Expect(countSchedules()).To(Equal(1))
+	})
+
+	It("Should process requests without a key or with another key every time", func() {
+		createSchedule("", `{"owner_name": "Tyrion Lannister"}`)
+		createSchedule("", `{"owner_name": "Tyrion Lannister"}`)
+		createSchedule("another", `{"owner_name": "Tyrion Lannister"}`)
+
+		Expect(countSchedules()).To(Equal(3))
+	})
+
+	It("Should return a StatusUnprocessableEntity when a key is reused with a different body", func() {
+		createSchedule("retry-me", `{"owner_name": "Tyrion Lannister"}`)
+
+		recorder := createSchedule("retry-me", `{"owner_name": "Arya Stark"}`)
+
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(countSchedules()).To(Equal(1))
+	})
+
+	It("Should process a request again once the window has passed", func() {
+		h.Idempotency = NewIdempotencyCache(time.Nanosecond, 10)
+
+		createSchedule("retry-me", `{"owner_name": "Tyrion Lannister"}`)
+		time.Sleep(time.Millisecond)
+		recorder := createSchedule("retry-me", `{"owner_name": "Tyrion Lannister"}`)
+
+		Expect(recorder.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+		Expect(countSchedules()).To(Equal(2))
+	})
+
+	It("Should forget the oldest keys beyond its maximum", func() {
+		h.Idempotency = NewIdempotencyCache(time.Hour, 2)
+
+		createSchedule("first", `{"owner_name": "Tyrion Lannister"}`)
+		createSchedule("second", `{"owner_name": "Tyrion Lannister"}`)
+		createSchedule("third", `{"owner_name": "Tyrion Lannister"}`)
+		Expect(createSchedule("third", `{"owner_name": "Tyrion Lannister"}`).Header().Get("Idempotent-Replayed")).To(Equal("true"))
+		Expect(countSchedules()).To(Equal(3))
+
+		recorder := createSchedule("first", `{"owner_name": "Tyrion Lannister"}`)
+
+		Expect(recorder.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+		Expect(countSchedules()).To(Equal(4))
+	})
+
+	It("Should keep keys for appointments apart per schedule and replay their ETag", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		createAppointment := func() *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/schedules/"+strconv.Itoa(s.ID)+"/appointments", bytes.NewReader([]byte(`
+				{"start_time": "2019-06-03T09:00:00Z", "end_time": "2019-06-03T10:00:00Z"}
+			`)))
+			r.Header.Set("Idempotency-Key", "book-it")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			h.Idempotent(h.CreateAppointmentHandler).ServeHTTP(recorder, r)
+			return recorder
+		}
+
+		first := createAppointment()
+		retry := createAppointment()
+
+		Expect(retry.Code).To(Equal(http.StatusCreated))
+		Expect(retry.Header().Get("ETag")).To(Equal(first.Header().Get("ETag")))
+
+		var a Appointment
+		json.NewDecoder(retry.Body).Decode(&a)
+		appointments, _ := store.ListAppointments(s.ID)
+		Expect(appointments).To(HaveLen(1))
+		Expect(a.ID).To(Equal(appointments[0].ID))
+
+		recorder := createSchedule("book-it", `{"owner_name": "Arya Stark"}`)
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+	})
+})
@@ -0,0 +1,271 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// endOfTime stands in for the end of a recurring series without COUNT or
+// UNTIL.
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// appointmentIndex orders the appointments of a schedule by start time (then
+// ID) in a treap whose nodes also record the latest end in their subtree, so
+// the appointments intersecting a window are found in O(log n + k).
+//
+// Recurring appointments are indexed over their whole series, from the first
+// start to the end of the last occurrence; open-ended series reach endOfTime
+// and are therefore visited by every search.
+//
+// The index is persistent: insert and remove copy the path they change and
+// leave the receiver untouched, so copies of a Schedule can share and extend
+// it independently. The zero value is an empty index.
+type appointmentIndex struct {
+	root *indexNode
+}
+
+type indexNode struct {
+	appointment Appointment
+	end         time.Time
+	maxEnd      time.Time
+	size        int
+	priority    uint64
+	left, right *indexNode
+}
+
+func newAppointmentIndex(appointments map[int]Appointment) appointmentIndex {
+	var idx appointmentIndex
+	for _, a := range appointments {
+		idx = idx.insert(a)
+	}
+
+	return idx
+}
+
+func (idx appointmentIndex) len() int {
+	return idx.root.count()
+}
+
+// insert returns an index that also holds a. The caller is responsible for
+// removing any earlier version of a first.
+func (idx appointmentIndex) insert(a Appointment) appointmentIndex {
+	n := &indexNode{appointment: a, end: a.seriesEnd(), size: 1, priority: indexPriority(a.ID)}
+	n.maxEnd = n.end
+
+	left, right := split(idx.root, a, false)
+	return appointmentIndex{root: merge(merge(left, n), right)}
+}
+
+// remove returns an index without the entry with a's ID and start time.
+func (idx appointmentIndex) remove(a Appointment) appointmentIndex {
+	left, rest := split(idx.root, a, false)
+	_, right := split(rest, a, true)
+	return appointmentIndex{root: merge(left, right)}
+}
+
+// each calls fn with every appointment in order until fn returns false.
+func (idx appointmentIndex) each(fn func(Appointment) bool) {
+	idx.root.each(fn)
+}
+
+// between calls fn, in order, with every appointment whose series intersects
+// [from, to) until fn returns false.
+func (idx appointmentIndex) between(from, to time.Time, fn func(Appointment) bool) {
+	idx.root.between(from, to, fn)
+}
+
+func (n *indexNode) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *indexNode) each(fn func(Appointment) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	return n.left.each(fn) && fn(n.appointment) && n.right.each(fn)
+}
+
+func (n *indexNode) between(from, to time.Time, fn func(Appointment) bool) bool {
+	if n == nil || !n.maxEnd.After(from) {
+		return true
+	}
+	if !n.left.between(from, to, fn) {
+		return false
+	}
+	// Everything to the right starts no earlier than n.
+	if !n.appointment.StartTime.Before(to) {
+		return true
+	}
+	if n.end.After(from) && !fn(n.appointment) {
+		return false
+	}
+
+	return n.right.between(from, to, fn)
+}
+
+// split divides the tree rooted at n into the entries ordered before a and
+// the rest. With inclusive set, a itself goes to the first part.
+func split(n *indexNode, a Appointment, inclusive bool) (*indexNode, *indexNode) {
+	if n == nil {
+		return nil, nil
+	}
+
+	c := *n
+	if indexLess(n.appointment, a) || (inclusive && n.appointment.ID == a.ID && n.appointment.StartTime.Equal(a.StartTime.Time)) {
+		left, right := split(n.right, a, inclusive)
+		c.right = left
+		c.update()
+		return &c, right
+	}
+
+	left, right := split(n.left, a, inclusive)
+	c.left = right
+	c.update()
+	return left, &c
+}
+
+// merge joins two trees where every entry of left is ordered before those
+// of right.
+func merge(left, right *indexNode) *indexNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	if left.priority > right.priority {
+		c := *left
+		c.right = merge(left.right, right)
+		c.update()
+		return &c
+	}
+
+	c := *right
+	c.left = merge(left, right.left)
+	c.update()
+	return &c
+}
+
+func (n *indexNode) update() {
+	n.size = 1 + n.left.count() + n.right.count()
+	n.maxEnd = n.end
+	for _, child := range []*indexNode{n.left, n.right} {
+		if child != nil && child.maxEnd.After(n.maxEnd) {
+			n.maxEnd = child.maxEnd
+		}
+	}
+}
+
+func indexLess(a, b Appointment) bool {
+	if !a.StartTime.Equal(b.StartTime.Time) {
+		return a.StartTime.Before(b.StartTime.Time)
+	}
+	return a.ID < b.ID
+}
+
+// indexPriority scrambles an appointment ID (splitmix64) into the heap
+// priority that keeps the treap balanced, so that the same appointments
+// always produce the same tree.
+func indexPriority(id int) uint64 {
+	z := uint64(id) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// seriesEnd is the end of a's last occurrence.
+func (a Appointment) seriesEnd() time.Time {
+	if a.Recurrence == nil {
+		return a.EndTime.Time
+	}
+
+	duration := a.EndTime.Sub(a.StartTime.Time)
+	switch {
+	case a.Recurrence.Count > 0:
+		return a.Recurrence.lastStart(a.StartTime.Time).Add(duration)
+	case !a.Recurrence.Until.IsZero():
+		return a.Recurrence.Until.Add(duration)
+	}
+
+	return endOfTime
+}
+
+// appointmentIndex returns the index of s's appointments. Stores keep it up
+// to date; a schedule assembled by hand has its index built here.
+func (s Schedule) appointmentIndex() appointmentIndex {
+	if s.index.len() != len(s.Appointments) {
+		return newAppointmentIndex(s.Appointments)
+	}
+
+	return s.index
+}
+
+// putAppointment adds a to s, replacing the appointment with the same ID.
+func (s *Schedule) putAppointment(a Appointment) {
+	idx := s.appointmentIndex()
+	if old, found := s.Appointments[a.ID]; found {
+		idx = idx.remove(old)
+	}
+
+	if s.Appointments == nil {
+		s.Appointments = make(map[int]Appointment)
+	}
+	s.Appointments[a.ID] = a
+	s.index = idx.insert(a)
+}
+
+// removeAppointment takes the appointment with the given ID off s.
+func (s *Schedule) removeAppointment(id int) {
+	old, found := s.Appointments[id]
+	if !found {
+		return
+	}
+
+	s.index = s.appointmentIndex().remove(old)
+	delete(s.Appointments, id)
+}
+
+// expandAppointments returns every occurrence of s's appointments that
+// intersects [from, to), ordered like sortAppointments.
+func expandAppointments(s Schedule, from, to time.Time) []Appointment {
+	occurrences := []Appointment{}
+	s.appointmentIndex().between(from, to, func(a Appointment) bool {
+		occurrences = append(occurrences, a.Occurrences(from, to)...)
+		return true
+	})
+
+	// Occurrences of different series interleave.
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartTime.Before(occurrences[j].StartTime.Time)
+	})
+
+	return occurrences
+}
+
+// busyAppointments is expandAppointments without cancelled appointments.
+func busyAppointments(s Schedule, from, to time.Time) []Appointment {
+	var busy []Appointment
+	for _, a := range expandAppointments(s, from, to) {
+		if a.busy() {
+			busy = append(busy, a)
+		}
+	}
+
+	return busy
+}
+
+// sortAppointments lists s's appointments by start time, then ID.
+func sortAppointments(s Schedule) []Appointment {
+	appointments := make([]Appointment, 0, len(s.Appointments))
+	s.appointmentIndex().each(func(a Appointment) bool {
+		appointments = append(appointments, a)
+		return true
+	})
+
+	return appointments
+}
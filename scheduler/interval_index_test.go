@@ -0,0 +1,119 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Appointment Index", func() {
+	var (
+		store *MemoryStore
+		s     Schedule
+		start time.Time
+	)
+
+	at := func(hours int, minutes time.Duration) Appointment {
+		t := start.Add(time.Duration(hours)*time.Hour + minutes*time.Minute)
+		return Appointment{ScheduleID: s.ID, StartTime: NewTimestamp(t), EndTime: NewTimestamp(t.Add(20 * time.Minute))}
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC"})
+		start = time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC)
+
+		for i := 0; i < 500; i++ {
+			store.CreateAppointment(at(i, 0))
+		}
+	})
+
+	It("Should find conflicts anywhere on a large schedule", func() {
+		s, _ = store.GetSchedule(s.ID)
+
+		for _, hours := range []int{0, 1, 250, 499} {
+			Expect(ValidateAppointmentInput(s, at(hours, 10))).To(HaveOccurred())
+			Expect(ValidateAppointmentInput(s, at(hours, 30))).NotTo(HaveOccurred())
+		}
+	})
+
+	It("Should keep up with appointments being changed, moved and deleted", func() {
+		other, _ := store.CreateSchedule(Schedule{OwnerName: "Jaime Lannister", TimeZone: "UTC"})
+		appointments, _ := store.ListAppointments(s.ID)
+
+		moved := at(100, 30)
+		moved.ID = appointments[10].ID
+		store.UpdateAppointment(moved)
+		store.DeleteAppointment(s.ID, appointments[20].ID)
+		gone := appointments[30]
+		gone.ScheduleID = other.ID
+		store.MoveAppointment(gone, s.ID)
+
+		s, _ = store.GetSchedule(s.ID)
+		Expect(ValidateAppointmentInput(s, at(10, 0))).NotTo(HaveOccurred())
+		Expect(ValidateAppointmentInput(s, at(100, 35))).To(HaveOccurred())
+		Expect(ValidateAppointmentInput(s, at(20, 0))).NotTo(HaveOccurred())
+		Expect(ValidateAppointmentInput(s, at(30, 0))).NotTo(HaveOccurred())
+
+		other, _ = store.GetSchedule(other.ID)
+		Expect(ValidateAppointmentInput(other, at(30, 0))).To(HaveOccurred())
+	})
+
+	It("Should check recurring series only where they have occurrences", func() {
+		bounded := at(-24*7, 30)
+		bounded.Recurrence, _ = ParseRecurrenceRule("FREQ=DAILY;COUNT=7")
+		open := at(-24*3, 40)
+		open.Recurrence, _ = ParseRecurrenceRule("FREQ=WEEKLY")
+		store.CreateAppointment(bounded)
+		store.CreateAppointment(open)
+
+		s, _ = store.GetSchedule(s.ID)
+		Expect(ValidateAppointmentInput(s, at(-24, 30))).To(HaveOccurred())
+		Expect(ValidateAppointmentInput(s, at(0, 30))).NotTo(HaveOccurred())
+		Expect(ValidateAppointmentInput(s, at(24*(4+7*20), 30))).To(HaveOccurred())
+	})
+
+	It("Should index a long series up to its last occurrence", func() {
+		for _, rule := range []string{"FREQ=DAILY;INTERVAL=2;BYDAY=MO,FR;COUNT=10000", "FREQ=MONTHLY;COUNT=10000"} {
+			first := time.Date(2019, 1, 31, 9, 0, 0, 0, time.UTC)
+			rrule, _ := ParseRecurrenceRule(rule)
+			created, _ := store.CreateAppointment(Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(first),
+				EndTime:    NewTimestamp(first.Add(time.Hour)),
+				Recurrence: rrule,
+			})
+
+			occurrences := created.Occurrences(first, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+			last := occurrences[len(occurrences)-1]
+			Expect(occurrences).To(HaveLen(10000))
+
+			s, _ = store.GetSchedule(s.ID)
+			clash := Appointment{ScheduleID: s.ID, StartTime: last.StartTime, EndTime: last.EndTime}
+			Expect(ValidateAppointmentInput(s, clash)).To(HaveOccurred(), rule)
+
+			store.DeleteAppointment(s.ID, created.ID)
+		}
+	})
+
+	It("Should list appointments by start time, then ID", func() {
+		tie := at(3, 0)
+		tie.EndTime = NewTimestamp(tie.EndTime.Add(time.Minute))
+		created, _ := store.CreateAppointment(tie)
+
+		s, _ = store.GetSchedule(s.ID)
+		body, _ := json.Marshal(s)
+
+		var resBody ScheduleResponse
+		json.Unmarshal(body, &resBody)
+		Expect(resBody.Appointments).To(HaveLen(501))
+		for i := 1; i < len(resBody.Appointments); i++ {
+			Expect(resBody.Appointments[i].StartTime.Before(resBody.Appointments[i-1].StartTime.Time)).To(BeFalse())
+		}
+		Expect(resBody.Appointments[4].ID).To(Equal(created.ID))
+	})
+})
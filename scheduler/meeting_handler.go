@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+func (h *Handler) CreateMeetingHandler(w http.ResponseWriter, r *http.Request) {
+	var m Meeting
+	err := json.NewDecoder(r.Body).Decode(&m)
+	if err != nil {
+		log.Println("CreateMeetingHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	m, err = createMeeting(h.Store, m, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("CreateMeetingHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to create meeting")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusCreated, m)
+}
+
+func (h *Handler) MeetingDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	meetingID, err := convertIDParam(r, "meetingID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid meeting ID")
+		return
+	}
+
+	m, err := h.Store.GetMeeting(meetingID)
+	if err != nil {
+		log.Println("MeetingDetailsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to retrieve meeting")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, m)
+}
+
+func (h *Handler) DeleteMeetingHandler(w http.ResponseWriter, r *http.Request) {
+	meetingID, err := convertIDParam(r, "meetingID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid meeting ID")
+		return
+	}
+
+	m, err := deleteMeeting(h.Store, meetingID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("DeleteMeetingHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to delete meeting")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, m)
+}
@@ -0,0 +1,327 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Meeting Handlers", func() {
+	var (
+		store  *MemoryStore
+		h      *Handler
+		tyrion Schedule
+		arya   Schedule
+	)
+
+	createMeeting := func(reqBody string) (*httptest.ResponseRecorder, Meeting) {
+		recorder := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.CreateMeetingHandler)
+
+		r, _ := http.NewRequest("POST", "/meetings", bytes.NewReader([]byte(reqBody)))
+
+		handler.ServeHTTP(recorder, r)
+
+		var resBody Meeting
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		return recorder, resBody
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		tyrion, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		arya, _ = store.CreateSchedule(Schedule{OwnerName: "Arya Stark", TimeZone: "UTC"})
+	})
+
+	Context("#CreateMeeting", func() {
+		It("Should book a copy on every attendee's schedule in its time zone", func() {
+			recorder, m := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID))
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(m.ID).To(Equal(1))
+			Expect(m.ScheduleIDs).To(Equal([]int{tyrion.ID, arya.ID}))
+			Expect(m.Appointments).To(HaveLen(2))
+
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.Appointments).To(HaveLen(1))
+			for _, a := range s.Appointments {
+				Expect(a.MeetingID).To(Equal(m.ID))
+				Expect(a.StartTime.Format(time.RFC3339)).To(Equal("2019-06-03T09:00:00-06:00"))
+			}
+
+			s, _ = store.GetSchedule(arya.ID)
+			Expect(s.Appointments).To(HaveLen(1))
+		})
+
+		It("Should book nothing if any attendee has a conflict", func() {
+			store.CreateAppointment(Appointment{
+				ScheduleID: arya.ID,
+				StartTime:  NewTimestamp(time.Date(2019, 6, 3, 15, 30, 0, 0, time.UTC)),
+				EndTime:    NewTimestamp(time.Date(2019, 6, 3, 16, 30, 0, 0, time.UTC)),
+			})
+
+			recorder, _ := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID))
+
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.Appointments).To(BeEmpty())
+		})
+
+		It("Should book nothing if any attendee's schedule is missing", func() {
+			recorder, _ := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, 32],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID))
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.Appointments).To(BeEmpty())
+		})
+
+		It("Should book nothing unless If-Match lists every attendee's schedule ETag", func() {
+			etag := func(s Schedule) string {
+				return serve(h.ScheduleDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID)).Header().Get("ETag")
+			}
+			reqBody := fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID)
+
+			// Both schedules are at the same version, but one's ETag is no good
+			// for the other.
+			for _, ifMatch := range []string{etag(tyrion), `"1"`} {
+				recorder := serveWithHeaders(h.CreateMeetingHandler, "POST", "/meetings", reqBody, map[string]string{"If-Match": ifMatch})
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed), ifMatch)
+			}
+			s, _ := store.GetSchedule(tyrion.ID)
+			Expect(s.Appointments).To(BeEmpty())
+
+			recorder := serveWithHeaders(h.CreateMeetingHandler, "POST", "/meetings", reqBody,
+				map[string]string{"If-Match": etag(tyrion) + ", " + etag(arya)})
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+		})
+
+		It("Should return a StatusUnprocessableEntity without attendees or with duplicates", func() {
+			for _, scheduleIDs := range []string{"[]", fmt.Sprintf("[%v, %v]", tyrion.ID, tyrion.ID)} {
+				recorder, _ := createMeeting(`
+					{
+						"schedule_ids": ` + scheduleIDs + `,
+						"start_time": "2019-06-03T15:00:00Z",
+						"end_time": "2019-06-03T16:00:00Z"
+					}
+				`)
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), scheduleIDs)
+			}
+		})
+
+		It("Should return a StatusBadRequest if the reqBody is invalid", func() {
+			recorder, _ := createMeeting(`{"schedule_ids": "blamo"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("#MeetingDetails and #DeleteMeeting", func() {
+		meetingRequest := func(method string, meetingID string) *http.Request {
+			r, _ := http.NewRequest(method, "/meetings/"+meetingID, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("meetingID", meetingID)
+			return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		}
+
+		It("Should return the meeting and then delete every copy", func() {
+			_, m := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z",
+					"recurrence": "FREQ=WEEKLY;COUNT=4"
+				}
+			`, tyrion.ID, arya.ID))
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(h.MeetingDetailsHandler).ServeHTTP(recorder, meetingRequest("GET", strconv.Itoa(m.ID)))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var fetched Meeting
+			json.NewDecoder(recorder.Body).Decode(&fetched)
+			Expect(fetched.ScheduleIDs).To(Equal([]int{tyrion.ID, arya.ID}))
+			Expect(fetched.Recurrence.String()).To(Equal("FREQ=WEEKLY;COUNT=4"))
+
+			recorder = httptest.NewRecorder()
+			http.HandlerFunc(h.DeleteMeetingHandler).ServeHTTP(recorder, meetingRequest("DELETE", strconv.Itoa(m.ID)))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			for _, id := range []int{tyrion.ID, arya.ID} {
+				s, _ := store.GetSchedule(id)
+				Expect(s.Appointments).To(BeEmpty())
+			}
+
+			recorder = httptest.NewRecorder()
+			http.HandlerFunc(h.MeetingDetailsHandler).ServeHTTP(recorder, meetingRequest("GET", strconv.Itoa(m.ID)))
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should still delete a meeting after an attendee's schedule is deleted", func() {
+			_, m := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID))
+			store.DeleteSchedule(arya.ID)
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(h.DeleteMeetingHandler).ServeHTTP(recorder, meetingRequest("DELETE", strconv.Itoa(m.ID)))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var deleted Meeting
+			json.NewDecoder(recorder.Body).Decode(&deleted)
+			Expect(deleted.ScheduleIDs).To(Equal([]int{tyrion.ID}))
+		})
+
+		It("Should delete nothing unless If-Match lists every copy's ETag", func() {
+			_, m := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID))
+			var etags []string
+			for _, a := range m.Appointments {
+				recorder := serve(h.AppointmentDetailsHandler, "GET", "/", "",
+					"scheduleID", strconv.Itoa(a.ScheduleID), "appointmentID", strconv.Itoa(a.ID))
+				etags = append(etags, recorder.Header().Get("ETag"))
+			}
+
+			for _, ifMatch := range []string{`"1"`, etags[0], etags[0] + ", " + etags[0]} {
+				recorder := serveWithHeaders(h.DeleteMeetingHandler, "DELETE", "/", "", map[string]string{"If-Match": ifMatch},
+					"meetingID", strconv.Itoa(m.ID))
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed), ifMatch)
+			}
+			s, _ := store.GetSchedule(arya.ID)
+			Expect(s.Appointments).To(HaveLen(1))
+
+			recorder := serveWithHeaders(h.DeleteMeetingHandler, "DELETE", "/", "", map[string]string{"If-Match": strings.Join(etags, ", ")},
+				"meetingID", strconv.Itoa(m.ID))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("Should delete a meeting whose copy moves while it waits", func() {
+			sansa, _ := store.CreateSchedule(Schedule{OwnerName: "Sansa Stark"})
+			_, m := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID))
+
+			done := make(chan *httptest.ResponseRecorder)
+			store.Atomically([]int{arya.ID, sansa.ID}, func(tx Store) error {
+				go func() {
+					done <- serve(h.DeleteMeetingHandler, "DELETE", "/", "", "meetingID", strconv.Itoa(m.ID))
+				}()
+				Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+
+				moved := m.Appointments[1]
+				moved.ScheduleID = sansa.ID
+				_, err := tx.MoveAppointment(moved, arya.ID)
+				return err
+			})
+
+			var recorder *httptest.ResponseRecorder
+			Eventually(done).Should(Receive(&recorder))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			s, _ := store.GetSchedule(sansa.ID)
+			Expect(s.Appointments).To(BeEmpty())
+		})
+
+		It("Should return a StatusBadRequest for a non-numerical meeting ID", func() {
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(h.MeetingDetailsHandler).ServeHTTP(recorder, meetingRequest("GET", "blamo"))
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("#UpdateAppointment", func() {
+		It("Should not change the times of one attendee's copy", func() {
+			_, m := createMeeting(fmt.Sprintf(`
+				{
+					"schedule_ids": [%v, %v],
+					"start_time": "2019-06-03T15:00:00Z",
+					"end_time": "2019-06-03T16:00:00Z"
+				}
+			`, tyrion.ID, arya.ID))
+			first := m.Appointments[0]
+			params := []string{"scheduleID", strconv.Itoa(first.ScheduleID), "appointmentID", strconv.Itoa(first.ID)}
+
+			for _, reqBody := range []string{
+				`{"start_time": "2019-06-03T10:00:00-06:00", "end_time": "2019-06-03T11:00:00-06:00"}`,
+				`{"recurrence": "FREQ=WEEKLY;COUNT=4"}`,
+				`{"exceptions": ["2019-06-03T09:00:00-06:00"]}`,
+			} {
+				recorder := serve(h.PatchAppointmentHandler, "PATCH", "/", reqBody, params...)
+				Expect(recorder.Code).To(Equal(http.StatusConflict), reqBody)
+				Expect(recorder.Body.String()).To(ContainSubstring(fmt.Sprintf("/meetings/%v", m.ID)))
+			}
+
+			recorder := serve(h.UpdateAppointmentHandler, "PUT", "/", `
+				{
+					"start_time": "2019-06-03T10:00:00-06:00",
+					"end_time": "2019-06-03T11:00:00-06:00"
+				}
+			`, params...)
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+
+			for _, a := range m.Appointments {
+				stored, _ := store.GetAppointment(a.ScheduleID, a.ID)
+				Expect(stored.StartTime.Equal(a.StartTime.Time)).To(BeTrue())
+				Expect(stored.Recurrence).To(BeNil())
+			}
+
+			recorder = serve(h.PatchAppointmentHandler, "PATCH", "/",
+				`{"start_time": "2019-06-03T15:00:00Z", "title": "Small council"}`, params...)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
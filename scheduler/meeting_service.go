@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const maxMeetingAttendees = 50
+
+// createMeeting books a copy of the meeting on every attendee's schedule, or
+// on none of them if any copy fails validation. ifMatch must list the ETag
+// of every attendee's schedule.
+func createMeeting(store Store, m Meeting, ifMatch string) (created Meeting, err error) {
+	if err = validateAttendees(m.ScheduleIDs); err != nil {
+		return m, err
+	}
+
+	err = store.Atomically(m.ScheduleIDs, func(tx Store) error {
+		var appointments []Appointment
+		for _, scheduleID := range m.ScheduleIDs {
+			s, err := scheduleFor(tx, scheduleID, Appointment{StartTime: m.StartTime, EndTime: m.EndTime, Recurrence: m.Recurrence})
+			if err != nil {
+				return err
+			}
+			if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+				return err
+			}
+
+			a := Appointment{
+				ScheduleID: s.ID,
+				StartTime:  m.StartTime.In(s.Location()),
+				EndTime:    m.EndTime.In(s.Location()),
+				Recurrence: m.Recurrence,
+			}
+			if err = ValidateAppointmentInput(s, a); err != nil {
+				invalid := err.(ValidationError)
+				invalid.Detail = fmt.Sprintf("Invalid appointment time for schedule %v", s.ID)
+				return invalid
+			}
+			appointments = append(appointments, a)
+		}
+
+		meeting, err := tx.CreateMeeting(m)
+		if err != nil {
+			return err
+		}
+
+		for i, a := range appointments {
+			a.MeetingID = meeting.ID
+			if appointments[i], err = tx.CreateAppointment(a); err != nil {
+				return err
+			}
+		}
+
+		created = newMeeting(meeting.ID, appointments)
+		return nil
+	})
+	return
+}
+
+// deleteMeeting removes a meeting from every attendee's schedule. ifMatch
+// must list the ETag of every copy of the meeting. The attendees are locked
+// as they were when it started, trying again if a copy moves to another
+// schedule in the meantime.
+func deleteMeeting(store Store, id int, ifMatch string) (deleted Meeting, err error) {
+	for {
+		m, err := store.GetMeeting(id)
+		if err != nil {
+			return m, err
+		}
+
+		moved := false
+		err = store.Atomically(m.ScheduleIDs, func(tx Store) error {
+			current, err := tx.GetMeeting(id)
+			if err != nil {
+				return err
+			}
+			if moved = !sameAttendees(current, m); moved {
+				return nil
+			}
+			for _, a := range current.Appointments {
+				if err = checkIfMatch(ifMatch, appointmentTag(a)); err != nil {
+					return err
+				}
+			}
+
+			deleted, err = tx.DeleteMeeting(id)
+			return err
+		})
+		if !moved {
+			return deleted, err
+		}
+	}
+}
+
+// sameAttendees reports whether meetings m and n have copies on the same
+// schedules.
+func sameAttendees(m, n Meeting) bool {
+	if len(m.ScheduleIDs) != len(n.ScheduleIDs) {
+		return false
+	}
+	for i, id := range m.ScheduleIDs {
+		if n.ScheduleIDs[i] != id {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validateAttendees(scheduleIDs []int) error {
+	if len(scheduleIDs) == 0 || len(scheduleIDs) > maxMeetingAttendees {
+		return http_helpers.HttpError{
+			Message:    fmt.Sprintf("A meeting needs between 1 and %v schedules", maxMeetingAttendees),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	seen := make(map[int]bool, len(scheduleIDs))
+	for _, id := range scheduleIDs {
+		if seen[id] {
+			return http_helpers.HttpError{
+				Message:    fmt.Sprintf("Schedule %v is listed more than once", id),
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,1024 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryStore keeps schedules in process memory. Its contents are lost when
+// the process exits.
+//
+// mu guards the maps themselves and is only held for the duration of a single
+// read or write. Writers additionally hold the lock for every schedule they
+// touch for the whole of an Atomically call, so a validate-then-insert cannot
+// interleave with another writer on the same schedule.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	schedules     map[int]Schedule
+	scheduleLocks map[int]*sync.Mutex
+
+	// meetings records the schedules each meeting was booked on, so its
+	// appointments can be found without scanning every schedule.
+	meetings map[int][]int
+
+	calendars map[int]Calendar
+
+	lastScheduleID    int64
+	lastAppointmentID int64
+	lastMeetingID     int64
+	lastCalendarID    int64
+	lastBlackoutID    int64
+	lastHoldID        int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		schedules:     make(map[int]Schedule),
+		scheduleLocks: make(map[int]*sync.Mutex),
+		meetings:      make(map[int][]int),
+		calendars:     make(map[int]Calendar),
+	}
+}
+
+func (m *MemoryStore) Atomically(scheduleIDs []int, fn func(tx Store) error) error {
+	locked := m.lockSchedules(scheduleIDs)
+	defer func() {
+		for _, lock := range locked {
+			lock.Unlock()
+		}
+	}()
+
+	tx := &memoryTx{store: m, locked: make(map[int]bool, len(scheduleIDs))}
+	for _, id := range scheduleIDs {
+		tx.locked[id] = true
+	}
+
+	return fn(tx)
+}
+
+func (m *MemoryStore) CreateSchedule(s Schedule) (Schedule, error) {
+	return m.createSchedule(s)
+}
+
+func (m *MemoryStore) GetSchedule(id int) (Schedule, error) {
+	return m.getSchedule(id)
+}
+
+func (m *MemoryStore) GetScheduleBetween(id int, from, to time.Time) (Schedule, error) {
+	return m.getScheduleBetween(id, from, to)
+}
+
+func (m *MemoryStore) UpdateSchedule(s Schedule) (updated Schedule, err error) {
+	err = m.Atomically([]int{s.ID}, func(tx Store) error {
+		updated, err = tx.UpdateSchedule(s)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) DeleteSchedule(id int) (deleted Schedule, err error) {
+	err = m.Atomically([]int{id}, func(tx Store) error {
+		deleted, err = tx.DeleteSchedule(id)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) ListSchedules(q ScheduleQuery) ([]Schedule, int, error) {
+	m.mu.RLock()
+	schedules := make([]Schedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		s.Appointments = nil
+		s.index = appointmentIndex{}
+		schedules = append(schedules, s)
+	}
+	m.mu.RUnlock()
+
+	page, total := pageSchedules(q, schedules)
+	return page, total, nil
+}
+
+func (m *MemoryStore) CreateAppointment(a Appointment) (created Appointment, err error) {
+	err = m.Atomically([]int{a.ScheduleID}, func(tx Store) error {
+		created, err = tx.CreateAppointment(a)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) GetAppointment(scheduleID, appointmentID int) (Appointment, error) {
+	return m.getAppointment(scheduleID, appointmentID)
+}
+
+func (m *MemoryStore) UpdateAppointment(a Appointment) (updated Appointment, err error) {
+	err = m.Atomically([]int{a.ScheduleID}, func(tx Store) error {
+		updated, err = tx.UpdateAppointment(a)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) MoveAppointment(a Appointment, fromScheduleID int) (moved Appointment, err error) {
+	err = m.Atomically([]int{fromScheduleID, a.ScheduleID}, func(tx Store) error {
+		moved, err = tx.MoveAppointment(a, fromScheduleID)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) DeleteAppointment(scheduleID, appointmentID int) (deleted Appointment, err error) {
+	err = m.Atomically([]int{scheduleID}, func(tx Store) error {
+		deleted, err = tx.DeleteAppointment(scheduleID, appointmentID)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) ListAppointments(scheduleID int) ([]Appointment, error) {
+	s, err := m.getSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortAppointments(s), nil
+}
+
+func (m *MemoryStore) ListAppointmentsBetween(scheduleID int, from, to time.Time) ([]Appointment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, found := m.schedules[scheduleID]
+	if !found {
+		return nil, scheduleNotFound(scheduleID)
+	}
+
+	between := []Appointment{}
+	s.appointmentIndex().between(from, to, func(a Appointment) bool {
+		between = append(between, a)
+		return true
+	})
+
+	return between, nil
+}
+
+func (m *MemoryStore) CreateMeeting(meeting Meeting) (Meeting, error) {
+	meeting.ID = int(atomic.AddInt64(&m.lastMeetingID, 1))
+
+	m.mu.Lock()
+	m.meetings[meeting.ID] = append([]int(nil), meeting.ScheduleIDs...)
+	m.mu.Unlock()
+
+	return meeting, nil
+}
+
+func (m *MemoryStore) GetMeeting(id int) (Meeting, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scheduleIDs, found := m.meetings[id]
+	if !found {
+		return Meeting{}, meetingNotFound(id)
+	}
+
+	return newMeeting(id, m.meetingAppointments(id, scheduleIDs)), nil
+}
+
+// DeleteMeeting locks the meeting's attendees as they were when it started,
+// trying again if a copy moves to another schedule in the meantime.
+func (m *MemoryStore) DeleteMeeting(id int) (deleted Meeting, err error) {
+	for {
+		m.mu.RLock()
+		scheduleIDs := append([]int(nil), m.meetings[id]...)
+		m.mu.RUnlock()
+
+		err = m.Atomically(scheduleIDs, func(tx Store) error {
+			deleted, err = tx.DeleteMeeting(id)
+			return err
+		})
+		if _, notLocked := err.(notLockedError); !notLocked {
+			return
+		}
+	}
+}
+
+func (m *MemoryStore) CreateCalendar(c Calendar) (Calendar, error) {
+	c.ID = int(atomic.AddInt64(&m.lastCalendarID, 1))
+	c.Blackouts = []Blackout{}
+
+	m.mu.Lock()
+	m.calendars[c.ID] = c
+	m.mu.Unlock()
+
+	return c, nil
+}
+
+func (m *MemoryStore) GetCalendar(id int) (Calendar, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, found := m.calendars[id]
+	if !found {
+		return c, calendarNotFound(id)
+	}
+
+	c.Blackouts = append([]Blackout{}, c.Blackouts...)
+	return c, nil
+}
+
+func (m *MemoryStore) DeleteCalendar(id int) (deleted Calendar, err error) {
+	err = m.atomicallyForCalendar(id, func(tx Store) error {
+		deleted, err = tx.DeleteCalendar(id)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) CreateBlackout(b Blackout) (created Blackout, err error) {
+	fn := func(tx Store) error {
+		created, err = tx.CreateBlackout(b)
+		return err
+	}
+
+	if b.ScheduleID == 0 {
+		err = m.atomicallyForCalendar(b.CalendarID, fn)
+	} else {
+		err = m.Atomically([]int{b.ScheduleID}, fn)
+	}
+	return
+}
+
+func (m *MemoryStore) DeleteBlackout(b Blackout) (deleted Blackout, err error) {
+	fn := func(tx Store) error {
+		deleted, err = tx.DeleteBlackout(b)
+		return err
+	}
+
+	if b.ScheduleID == 0 {
+		err = m.atomicallyForCalendar(b.CalendarID, fn)
+	} else {
+		err = m.Atomically([]int{b.ScheduleID}, fn)
+	}
+	return
+}
+
+func (m *MemoryStore) CreateHold(h Hold) (created Hold, err error) {
+	err = m.Atomically([]int{h.ScheduleID}, func(tx Store) error {
+		created, err = tx.CreateHold(h)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) DeleteHold(scheduleID, holdID int) (deleted Hold, err error) {
+	err = m.Atomically([]int{scheduleID}, func(tx Store) error {
+		deleted, err = tx.DeleteHold(scheduleID, holdID)
+		return err
+	})
+	return
+}
+
+func (m *MemoryStore) DeleteExpiredHolds(now time.Time) (deleted int, err error) {
+	m.mu.RLock()
+	var scheduleIDs []int
+	for id, s := range m.schedules {
+		for _, h := range s.Holds {
+			if !h.active(now) {
+				scheduleIDs = append(scheduleIDs, id)
+				break
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	err = m.Atomically(scheduleIDs, func(tx Store) error {
+		deleted, err = tx.DeleteExpiredHolds(now)
+		return err
+	})
+	return
+}
+
+// atomicallyForCalendar runs fn inside Atomically with every schedule
+// subscribed to calendar id locked, as changes to the calendar change them
+// too. Writes through tx check the subscribers again, so fn is retried if
+// they changed before the locks were taken.
+func (m *MemoryStore) atomicallyForCalendar(id int, fn func(tx Store) error) error {
+	for {
+		m.mu.RLock()
+		subscribers := m.subscribers(id)
+		m.mu.RUnlock()
+
+		err := m.Atomically(subscribers, fn)
+		if _, notLocked := err.(notLockedError); !notLocked {
+			return err
+		}
+	}
+}
+
+func (tx *memoryTx) createBlackout(b Blackout) (Blackout, error) {
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b.ScheduleID != 0 {
+		if err := tx.checkLocked(b.ScheduleID); err != nil {
+			return b, err
+		}
+		s, found := m.schedules[b.ScheduleID]
+		if !found {
+			return b, scheduleNotFound(b.ScheduleID)
+		}
+		b.ID = int(atomic.AddInt64(&m.lastBlackoutID, 1))
+		s.Blackouts = append(append([]Blackout(nil), s.Blackouts...), b)
+		m.schedules[s.ID] = s
+		m.touch(s.ID)
+		return b, nil
+	}
+
+	c, found := m.calendars[b.CalendarID]
+	if !found {
+		return b, calendarNotFound(b.CalendarID)
+	}
+	if err := tx.checkLocked(m.subscribers(c.ID)...); err != nil {
+		return b, err
+	}
+	b.ID = int(atomic.AddInt64(&m.lastBlackoutID, 1))
+	c.Blackouts = append(append([]Blackout(nil), c.Blackouts...), b)
+	m.calendars[c.ID] = c
+	m.touchSubscribers(c.ID)
+	return b, nil
+}
+
+func (tx *memoryTx) deleteBlackout(b Blackout) (Blackout, error) {
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	remove := func(blackouts []Blackout) ([]Blackout, Blackout, bool) {
+		for i, stored := range blackouts {
+			if stored.ID == b.ID {
+				remaining := append(append([]Blackout(nil), blackouts[:i]...), blackouts[i+1:]...)
+				return remaining, stored, true
+			}
+		}
+		return blackouts, b, false
+	}
+
+	if b.ScheduleID != 0 {
+		if err := tx.checkLocked(b.ScheduleID); err != nil {
+			return b, err
+		}
+		s, found := m.schedules[b.ScheduleID]
+		if !found {
+			return b, scheduleNotFound(b.ScheduleID)
+		}
+		var deleted Blackout
+		if s.Blackouts, deleted, found = remove(s.Blackouts); !found {
+			return b, blackoutNotFound(b.ID)
+		}
+		m.schedules[s.ID] = s
+		m.touch(s.ID)
+		return deleted, nil
+	}
+
+	c, found := m.calendars[b.CalendarID]
+	if !found {
+		return b, calendarNotFound(b.CalendarID)
+	}
+	if err := tx.checkLocked(m.subscribers(c.ID)...); err != nil {
+		return b, err
+	}
+	var deleted Blackout
+	if c.Blackouts, deleted, found = remove(c.Blackouts); !found {
+		return b, blackoutNotFound(b.ID)
+	}
+	m.calendars[c.ID] = c
+	m.touchSubscribers(c.ID)
+	return deleted, nil
+}
+
+// meetingAppointments finds the appointments of a meeting on the schedules it
+// was booked on. The caller must hold mu.
+func (m *MemoryStore) meetingAppointments(id int, scheduleIDs []int) []Appointment {
+	var appointments []Appointment
+	for _, scheduleID := range scheduleIDs {
+		for _, a := range m.schedules[scheduleID].Appointments {
+			if a.MeetingID == id {
+				appointments = append(appointments, a)
+			}
+		}
+	}
+
+	return appointments
+}
+
+// lockSchedules acquires the per-schedule locks in ascending ID order so that
+// concurrent multi-schedule writers cannot deadlock. Schedules that do not
+// exist are skipped; any write against them fails with a NotFoundError.
+func (m *MemoryStore) lockSchedules(scheduleIDs []int) []*sync.Mutex {
+	ids := make([]int, len(scheduleIDs))
+	copy(ids, scheduleIDs)
+	sort.Ints(ids)
+
+	var locks []*sync.Mutex
+	m.mu.Lock()
+	for i, id := range ids {
+		if i > 0 && ids[i-1] == id {
+			continue
+		}
+		if _, found := m.schedules[id]; !found {
+			continue
+		}
+		lock, found := m.scheduleLocks[id]
+		if !found {
+			lock = &sync.Mutex{}
+			m.scheduleLocks[id] = lock
+		}
+		locks = append(locks, lock)
+	}
+	m.mu.Unlock()
+
+	for _, lock := range locks {
+		lock.Lock()
+	}
+
+	return locks
+}
+
+func (m *MemoryStore) createSchedule(s Schedule) (Schedule, error) {
+	s.Appointments = make(map[int]Appointment)
+	s.index = appointmentIndex{}
+	s.CalendarIDs = append([]int(nil), s.CalendarIDs...)
+	s.Blackouts = nil
+	s.Version = 1
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkCalendars(s.CalendarIDs); err != nil {
+		return s, err
+	}
+	s.ID = int(atomic.AddInt64(&m.lastScheduleID, 1))
+	m.schedules[s.ID] = s
+
+	return m.withCalendars(s), nil
+}
+
+// checkCalendars rejects subscriptions to calendars deleted since they were
+// validated. The caller must hold mu.
+func (m *MemoryStore) checkCalendars(ids []int) error {
+	for _, id := range ids {
+		if _, found := m.calendars[id]; !found {
+			return calendarNotFound(id)
+		}
+	}
+
+	return nil
+}
+
+// withCalendars copies stored schedule s for handing out, adding the
+// blackouts of its calendars to its own. The caller must hold mu.
+func (m *MemoryStore) withCalendars(s Schedule) Schedule {
+	s = copySchedule(s)
+
+	blackouts := append([]Blackout(nil), s.Blackouts...)
+	for _, id := range s.CalendarIDs {
+		blackouts = append(blackouts, m.calendars[id].Blackouts...)
+	}
+	sort.SliceStable(blackouts, func(i, j int) bool {
+		if !blackouts[i].StartTime.Equal(blackouts[j].StartTime.Time) {
+			return blackouts[i].StartTime.Before(blackouts[j].StartTime.Time)
+		}
+		return blackouts[i].ID < blackouts[j].ID
+	})
+	s.Blackouts = blackouts
+	s.CalendarIDs = append([]int(nil), s.CalendarIDs...)
+
+	return s
+}
+
+// subscribers lists the schedules subscribed to calendar id. The caller must
+// hold mu.
+func (m *MemoryStore) subscribers(id int) []int {
+	var ids []int
+	for scheduleID, s := range m.schedules {
+		for _, calendarID := range s.CalendarIDs {
+			if calendarID == id {
+				ids = append(ids, scheduleID)
+				break
+			}
+		}
+	}
+
+	return ids
+}
+
+// touchSubscribers bumps the version of every schedule subscribed to
+// calendar id. The caller must hold mu.
+func (m *MemoryStore) touchSubscribers(id int) {
+	for _, scheduleID := range m.subscribers(id) {
+		m.touch(scheduleID)
+	}
+}
+
+// touch bumps the version of schedule id after a change to its appointments.
+// The caller must hold mu.
+func (m *MemoryStore) touch(id int) {
+	s := m.schedules[id]
+	s.Version++
+	m.schedules[id] = s
+}
+
+func (m *MemoryStore) getSchedule(id int) (Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, found := m.schedules[id]
+	if !found {
+		return s, scheduleNotFound(id)
+	}
+
+	return m.withCalendars(s), nil
+}
+
+// getScheduleBetween is getSchedule copying only the appointments the index
+// finds within [from, to).
+func (m *MemoryStore) getScheduleBetween(id int, from, to time.Time) (Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, found := m.schedules[id]
+	if !found {
+		return s, scheduleNotFound(id)
+	}
+
+	appointments := make(map[int]Appointment)
+	var idx appointmentIndex
+	s.appointmentIndex().between(from, to, func(a Appointment) bool {
+		appointments[a.ID] = a
+		idx = idx.insert(a)
+		return true
+	})
+	s.Appointments, s.index = appointments, idx
+
+	return m.withCalendars(s), nil
+}
+
+func (m *MemoryStore) getAppointment(scheduleID, appointmentID int) (Appointment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, found := m.schedules[scheduleID]
+	if !found {
+		return Appointment{}, scheduleNotFound(scheduleID)
+	}
+
+	a, found := s.Appointments[appointmentID]
+	if !found {
+		return a, appointmentNotFound(appointmentID)
+	}
+
+	return a, nil
+}
+
+// memoryTx is the view of a MemoryStore handed to Atomically callbacks. Its
+// writes are only permitted on schedules locked by the enclosing call.
+type memoryTx struct {
+	store  *MemoryStore
+	locked map[int]bool
+}
+
+func (tx *memoryTx) Atomically(scheduleIDs []int, fn func(tx Store) error) error {
+	if err := tx.checkLocked(scheduleIDs...); err != nil {
+		return err
+	}
+
+	return fn(tx)
+}
+
+func (tx *memoryTx) CreateSchedule(s Schedule) (Schedule, error) {
+	return tx.store.createSchedule(s)
+}
+
+func (tx *memoryTx) GetSchedule(id int) (Schedule, error) {
+	return tx.store.getSchedule(id)
+}
+
+func (tx *memoryTx) GetScheduleBetween(id int, from, to time.Time) (Schedule, error) {
+	return tx.store.getScheduleBetween(id, from, to)
+}
+
+func (tx *memoryTx) UpdateSchedule(s Schedule) (Schedule, error) {
+	if err := tx.checkLocked(s.ID); err != nil {
+		return s, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, found := m.schedules[s.ID]
+	if !found {
+		return s, scheduleNotFound(s.ID)
+	}
+	if err := m.checkCalendars(s.CalendarIDs); err != nil {
+		return s, err
+	}
+
+	stored.OwnerName = s.OwnerName
+	stored.OverlapPolicy = s.OverlapPolicy
+	stored.BookingPolicy = s.BookingPolicy
+	stored.WorkingHours = s.WorkingHours
+	stored.CalendarIDs = append([]int(nil), s.CalendarIDs...)
+	stored.Version++
+	if stored.TimeZone != s.TimeZone {
+		// Match the SQLite store, which reads appointments back in the
+		// schedule's current zone.
+		stored.TimeZone = s.TimeZone
+		loc := stored.Location()
+		for id, a := range stored.Appointments {
+			a = appointmentIn(a, loc)
+			a.Version++
+			stored.Appointments[id] = a
+		}
+		stored.index = newAppointmentIndex(stored.Appointments)
+
+		blackouts := make([]Blackout, len(stored.Blackouts))
+		for i, b := range stored.Blackouts {
+			b.StartTime, b.EndTime = b.StartTime.In(loc), b.EndTime.In(loc)
+			blackouts[i] = b
+		}
+		stored.Blackouts = blackouts
+
+		holds := make([]Hold, len(stored.Holds))
+		for i, h := range stored.Holds {
+			h.StartTime, h.EndTime, h.ExpiresAt = h.StartTime.In(loc), h.EndTime.In(loc), h.ExpiresAt.In(loc)
+			holds[i] = h
+		}
+		stored.Holds = holds
+	}
+	m.schedules[s.ID] = stored
+
+	return m.withCalendars(stored), nil
+}
+
+func (tx *memoryTx) DeleteSchedule(id int) (Schedule, error) {
+	if err := tx.checkLocked(id); err != nil {
+		return Schedule{}, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, found := m.schedules[id]
+	if !found {
+		return s, scheduleNotFound(id)
+	}
+
+	delete(m.schedules, id)
+	delete(m.scheduleLocks, id)
+	return m.withCalendars(s), nil
+}
+
+func (tx *memoryTx) ListSchedules(q ScheduleQuery) ([]Schedule, int, error) {
+	return tx.store.ListSchedules(q)
+}
+
+func (tx *memoryTx) CreateAppointment(a Appointment) (Appointment, error) {
+	if err := tx.checkLocked(a.ScheduleID); err != nil {
+		return a, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, found := m.schedules[a.ScheduleID]
+	if !found {
+		return a, scheduleNotFound(a.ScheduleID)
+	}
+
+	a.ID = int(atomic.AddInt64(&m.lastAppointmentID, 1))
+	a.Version = 1
+	if a.Status == "" {
+		a.Status = StatusConfirmed
+	}
+	s.putAppointment(a)
+	m.schedules[s.ID] = s
+	m.touch(s.ID)
+
+	return a, nil
+}
+
+func (tx *memoryTx) GetAppointment(scheduleID, appointmentID int) (Appointment, error) {
+	return tx.store.getAppointment(scheduleID, appointmentID)
+}
+
+func (tx *memoryTx) UpdateAppointment(a Appointment) (Appointment, error) {
+	if err := tx.checkLocked(a.ScheduleID); err != nil {
+		return a, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, found := m.schedules[a.ScheduleID]
+	if !found {
+		return a, scheduleNotFound(a.ScheduleID)
+	}
+	stored, found := s.Appointments[a.ID]
+	if !found {
+		return a, appointmentNotFound(a.ID)
+	}
+
+	a.Version = stored.Version + 1
+	s.putAppointment(a)
+	m.schedules[s.ID] = s
+	m.touch(s.ID)
+	return a, nil
+}
+
+func (tx *memoryTx) MoveAppointment(a Appointment, fromScheduleID int) (Appointment, error) {
+	if err := tx.checkLocked(fromScheduleID, a.ScheduleID); err != nil {
+		return a, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, found := m.schedules[fromScheduleID]
+	if !found {
+		return a, scheduleNotFound(fromScheduleID)
+	}
+	to, found := m.schedules[a.ScheduleID]
+	if !found {
+		return a, scheduleNotFound(a.ScheduleID)
+	}
+	stored, found := from.Appointments[a.ID]
+	if !found {
+		return a, appointmentNotFound(a.ID)
+	}
+
+	a.Version = stored.Version + 1
+	from.removeAppointment(a.ID)
+	to.putAppointment(a)
+	m.schedules[from.ID] = from
+	m.schedules[to.ID] = to
+	m.touch(from.ID)
+	m.touch(to.ID)
+
+	// The attendees are replaced rather than changed in place, as readers
+	// keep them after releasing mu.
+	if scheduleIDs, found := m.meetings[a.MeetingID]; found {
+		updated := make([]int, len(scheduleIDs))
+		for i, id := range scheduleIDs {
+			updated[i] = id
+			if id == fromScheduleID {
+				updated[i] = a.ScheduleID
+			}
+		}
+		m.meetings[a.MeetingID] = updated
+	}
+
+	return a, nil
+}
+
+func (tx *memoryTx) DeleteAppointment(scheduleID, appointmentID int) (Appointment, error) {
+	if err := tx.checkLocked(scheduleID); err != nil {
+		return Appointment{}, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, found := m.schedules[scheduleID]
+	if !found {
+		return Appointment{}, scheduleNotFound(scheduleID)
+	}
+
+	a, found := s.Appointments[appointmentID]
+	if !found {
+		return a, appointmentNotFound(appointmentID)
+	}
+
+	s.removeAppointment(appointmentID)
+	m.schedules[s.ID] = s
+	m.touch(s.ID)
+	return a, nil
+}
+
+func (tx *memoryTx) ListAppointments(scheduleID int) ([]Appointment, error) {
+	return tx.store.ListAppointments(scheduleID)
+}
+
+func (tx *memoryTx) ListAppointmentsBetween(scheduleID int, from, to time.Time) ([]Appointment, error) {
+	return tx.store.ListAppointmentsBetween(scheduleID, from, to)
+}
+
+func (tx *memoryTx) CreateMeeting(meeting Meeting) (Meeting, error) {
+	return tx.store.CreateMeeting(meeting)
+}
+
+func (tx *memoryTx) GetMeeting(id int) (Meeting, error) {
+	return tx.store.GetMeeting(id)
+}
+
+func (tx *memoryTx) DeleteMeeting(id int) (Meeting, error) {
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scheduleIDs, found := m.meetings[id]
+	if !found {
+		return Meeting{}, meetingNotFound(id)
+	}
+
+	// Attendees whose schedules have since been deleted hold no copies.
+	var existing []int
+	for _, scheduleID := range scheduleIDs {
+		if _, found := m.schedules[scheduleID]; found {
+			existing = append(existing, scheduleID)
+		}
+	}
+	if err := tx.checkLocked(existing...); err != nil {
+		return Meeting{}, err
+	}
+
+	appointments := m.meetingAppointments(id, existing)
+	for _, a := range appointments {
+		s := m.schedules[a.ScheduleID]
+		s.removeAppointment(a.ID)
+		m.schedules[s.ID] = s
+		m.touch(a.ScheduleID)
+	}
+	delete(m.meetings, id)
+
+	return newMeeting(id, appointments), nil
+}
+
+func (tx *memoryTx) CreateCalendar(c Calendar) (Calendar, error) {
+	return tx.store.CreateCalendar(c)
+}
+
+func (tx *memoryTx) GetCalendar(id int) (Calendar, error) {
+	return tx.store.GetCalendar(id)
+}
+
+// DeleteCalendar requires every subscriber of the calendar to be locked, as
+// do changes to a calendar's blackouts.
+func (tx *memoryTx) DeleteCalendar(id int) (Calendar, error) {
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, found := m.calendars[id]
+	if !found {
+		return c, calendarNotFound(id)
+	}
+	subscribers := m.subscribers(id)
+	if err := tx.checkLocked(subscribers...); err != nil {
+		return c, err
+	}
+
+	m.touchSubscribers(id)
+	for _, scheduleID := range subscribers {
+		s := m.schedules[scheduleID]
+		var calendarIDs []int
+		for _, calendarID := range s.CalendarIDs {
+			if calendarID != id {
+				calendarIDs = append(calendarIDs, calendarID)
+			}
+		}
+		s.CalendarIDs = calendarIDs
+		m.schedules[scheduleID] = s
+	}
+	delete(m.calendars, id)
+
+	return c, nil
+}
+
+func (tx *memoryTx) CreateBlackout(b Blackout) (Blackout, error) {
+	return tx.createBlackout(b)
+}
+
+func (tx *memoryTx) DeleteBlackout(b Blackout) (Blackout, error) {
+	return tx.deleteBlackout(b)
+}
+
+func (tx *memoryTx) CreateHold(h Hold) (Hold, error) {
+	if err := tx.checkLocked(h.ScheduleID); err != nil {
+		return h, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, found := m.schedules[h.ScheduleID]
+	if !found {
+		return h, scheduleNotFound(h.ScheduleID)
+	}
+
+	h.ID = int(atomic.AddInt64(&m.lastHoldID, 1))
+	s.Holds = append(append([]Hold(nil), s.Holds...), h)
+	sort.SliceStable(s.Holds, func(i, j int) bool {
+		return s.Holds[i].StartTime.Before(s.Holds[j].StartTime.Time)
+	})
+	m.schedules[s.ID] = s
+	m.touch(s.ID)
+	return h, nil
+}
+
+func (tx *memoryTx) DeleteHold(scheduleID, holdID int) (Hold, error) {
+	if err := tx.checkLocked(scheduleID); err != nil {
+		return Hold{}, err
+	}
+
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, found := m.schedules[scheduleID]
+	if !found {
+		return Hold{}, scheduleNotFound(scheduleID)
+	}
+
+	for _, h := range s.Holds {
+		if h.ID == holdID {
+			s.Holds = removeHold(s.Holds, holdID)
+			m.schedules[s.ID] = s
+			m.touch(s.ID)
+			return h, nil
+		}
+	}
+
+	return Hold{}, holdNotFound(holdID)
+}
+
+// DeleteExpiredHolds only sweeps the schedules locked by the transaction,
+// bumping the version of those it changes.
+func (tx *memoryTx) DeleteExpiredHolds(now time.Time) (int, error) {
+	m := tx.store
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for id := range tx.locked {
+		s, found := m.schedules[id]
+		if !found {
+			continue
+		}
+
+		var remaining []Hold
+		for _, h := range s.Holds {
+			if h.active(now) {
+				remaining = append(remaining, h)
+			}
+		}
+		if len(remaining) == len(s.Holds) {
+			continue
+		}
+
+		deleted += len(s.Holds) - len(remaining)
+		s.Holds = remaining
+		m.schedules[id] = s
+		m.touch(id)
+	}
+
+	return deleted, nil
+}
+
+func (tx *memoryTx) checkLocked(scheduleIDs ...int) error {
+	for _, id := range scheduleIDs {
+		if !tx.locked[id] {
+			return notLockedError(id)
+		}
+	}
+
+	return nil
+}
+
+// notLockedError reports a write to a schedule that the transaction has not
+// locked.
+type notLockedError int
+
+func (id notLockedError) Error() string {
+	return fmt.Sprintf("schedule %v is not locked by this transaction", int(id))
+}
+
+// copySchedule detaches the appointment map so callers cannot mutate stored
+// state without going through the store.
+func copySchedule(s Schedule) Schedule {
+	appointments := make(map[int]Appointment, len(s.Appointments))
+	for id, a := range s.Appointments {
+		appointments[id] = a
+	}
+	s.Appointments = appointments
+
+	return s
+}
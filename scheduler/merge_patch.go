@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+// mergePatch applies an RFC 7396 JSON merge patch to target. Both are values
+// as produced by decoding JSON into an interface{}.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+
+	return targetObject
+}
+
+// applyDocument updates the JSON representation of current with body and
+// decodes the result into updated. With merge set body is a JSON merge patch;
+// otherwise it replaces the whole representation (PUT). Members listed in
+// readOnly may be repeated in body but must keep their current values.
+func applyDocument(current interface{}, body []byte, merge bool, readOnly []string, updated interface{}) error {
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return invalidRequestBody()
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return invalidRequestBody()
+	}
+
+	currentDocument, err := jsonDocument(current)
+	if err != nil {
+		return err
+	}
+
+	// mergePatch modifies its target, so patch a second copy.
+	base, _ := jsonDocument(current)
+	if !merge {
+		base = make(map[string]interface{})
+		for _, key := range readOnly {
+			if value, found := currentDocument[key]; found {
+				base[key] = value
+			}
+		}
+	}
+
+	document := mergePatch(base, patch).(map[string]interface{})
+	for _, key := range readOnly {
+		if !reflect.DeepEqual(document[key], currentDocument[key]) {
+			return http_helpers.HttpError{
+				Message:    fmt.Sprintf("%v cannot be changed", key),
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		}
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, updated); err != nil {
+		return invalidRequestBody()
+	}
+
+	return nil
+}
+
+func jsonDocument(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	err = json.Unmarshal(data, &document)
+	return document, err
+}
+
+func invalidRequestBody() error {
+	return http_helpers.HttpError{
+		Message:    "Invalid Request Body",
+		StatusCode: http.StatusBadRequest,
+	}
+}
@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+type MoveAppointmentRequest struct {
+	ScheduleID int `json:"schedule_id"`
+}
+
+// MoveAppointmentHandler transfers an appointment to the schedule named by
+// schedule_id in the request body.
+func (h *Handler) MoveAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	appointmentID, err := convertIDParam(r, "appointmentID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	var req MoveAppointmentRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Println("MoveAppointmentHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	if req.ScheduleID == 0 {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "schedule_id is required")
+		return
+	}
+
+	a, err := moveAppointment(h.Store, scheduleID, appointmentID, req.ScheduleID, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("MoveAppointmentHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to move appointment")
+		return
+	}
+
+	w.Header().Set("ETag", appointmentTag(a))
+	http_helpers.RespondWithJSON(w, http.StatusOK, a)
+}
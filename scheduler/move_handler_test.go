@@ -0,0 +1,147 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Move Appointment Handler", func() {
+	var (
+		store  *MemoryStore
+		h      *Handler
+		tyrion Schedule
+		arya   Schedule
+		series Appointment
+		start  time.Time
+	)
+
+	move := func(scheduleID, appointmentID int, reqBody string) (*httptest.ResponseRecorder, Appointment) {
+		recorder := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.MoveAppointmentHandler)
+
+		r, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(reqBody)))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("scheduleID", strconv.Itoa(scheduleID))
+		rctx.URLParams.Add("appointmentID", strconv.Itoa(appointmentID))
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+		handler.ServeHTTP(recorder, r)
+
+		var resBody Appointment
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		return recorder, resBody
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		tyrion, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		arya, _ = store.CreateSchedule(Schedule{OwnerName: "Arya Stark", TimeZone: "UTC"})
+		start = time.Date(2019, 6, 3, 9, 0, 0, 0, tyrion.Location())
+
+		rule, _ := ParseRecurrenceRule("FREQ=DAILY;COUNT=3")
+		series, _ = store.CreateAppointment(Appointment{
+			ScheduleID: tyrion.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+			Recurrence: rule,
+		})
+	})
+
+	It("Should move a series with its modified occurrences into the destination's time zone", func() {
+		body, _ := json.Marshal(Appointment{
+			StartTime: NewTimestamp(start.AddDate(0, 0, 1).Add(2 * time.Hour)),
+			EndTime:   NewTimestamp(start.AddDate(0, 0, 1).Add(3 * time.Hour)),
+		})
+		recorder := httptest.NewRecorder()
+		r, _ := http.NewRequest("PUT", "/", bytes.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("scheduleID", strconv.Itoa(tyrion.ID))
+		rctx.URLParams.Add("appointmentID", strconv.Itoa(series.ID))
+		rctx.URLParams.Add("occurrence", start.AddDate(0, 0, 1).Format(time.RFC3339))
+		http.HandlerFunc(h.ModifyOccurrenceHandler).ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+		recorder, moved := move(tyrion.ID, series.ID, fmt.Sprintf(`{"schedule_id": %v}`, arya.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(moved.ID).To(Equal(series.ID))
+		Expect(moved.ScheduleID).To(Equal(arya.ID))
+		Expect(moved.StartTime.Format(time.RFC3339)).To(Equal("2019-06-03T15:00:00Z"))
+		Expect(moved.Exceptions).To(HaveLen(1))
+
+		s, _ := store.GetSchedule(tyrion.ID)
+		Expect(s.Appointments).To(BeEmpty())
+		s, _ = store.GetSchedule(arya.ID)
+		Expect(s.Appointments).To(HaveLen(2))
+		for _, a := range s.Appointments {
+			Expect(a.StartTime.Location()).To(Equal(time.UTC))
+		}
+	})
+
+	It("Should return a StatusConflict and move nothing when the destination is busy", func() {
+		store.CreateAppointment(Appointment{
+			ScheduleID: arya.ID,
+			StartTime:  NewTimestamp(start.AddDate(0, 0, 2).Add(30 * time.Minute)),
+			EndTime:    NewTimestamp(start.AddDate(0, 0, 2).Add(90 * time.Minute)),
+		})
+
+		recorder, _ := move(tyrion.ID, series.ID, fmt.Sprintf(`{"schedule_id": %v}`, arya.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+
+		_, err := store.GetAppointment(tyrion.ID, series.ID)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should return a StatusConflict when the destination's time is held", func() {
+		store.CreateHold(Hold{
+			ScheduleID: arya.ID,
+			StartTime:  NewTimestamp(start.AddDate(0, 0, 1).Add(30 * time.Minute)),
+			EndTime:    NewTimestamp(start.AddDate(0, 0, 1).Add(90 * time.Minute)),
+			ExpiresAt:  NewTimestamp(time.Now().Add(time.Minute)),
+		})
+
+		recorder, _ := move(tyrion.ID, series.ID, fmt.Sprintf(`{"schedule_id": %v}`, arya.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+
+		_, err := store.GetAppointment(tyrion.ID, series.ID)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should return a StatusNotFound for a missing destination or appointment", func() {
+		recorder, _ := move(tyrion.ID, series.ID, `{"schedule_id": 32}`)
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+		recorder, _ = move(tyrion.ID, 42, fmt.Sprintf(`{"schedule_id": %v}`, arya.ID))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should return a StatusUnprocessableEntity when moving to the same schedule", func() {
+		recorder, _ := move(tyrion.ID, series.ID, fmt.Sprintf(`{"schedule_id": %v}`, tyrion.ID))
+
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("Should return a StatusBadRequest without a destination", func() {
+		for _, body := range []string{`{}`, `blamo`} {
+			recorder, _ := move(tyrion.ID, series.ID, body)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest), body)
+		}
+	})
+})
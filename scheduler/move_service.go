@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+// moveAppointment transfers an appointment to another schedule, taking the
+// modified occurrences of a series along with it. Everything keeps its ID and
+// is validated against the destination's bookings in its time zone.
+func moveAppointment(store Store, scheduleID, appointmentID, toScheduleID int, ifMatch string) (moved Appointment, err error) {
+	if scheduleID == toScheduleID {
+		return moved, http_helpers.HttpError{
+			Message:    fmt.Sprintf("Appointment is already on schedule %v", toScheduleID),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	err = store.Atomically([]int{scheduleID, toScheduleID}, func(tx Store) error {
+		from, err := tx.GetSchedule(scheduleID)
+		if err != nil {
+			return err
+		}
+
+		a, found := from.Appointments[appointmentID]
+		if !found {
+			return appointmentNotFound(appointmentID)
+		}
+		if err = checkIfMatch(ifMatch, appointmentTag(a)); err != nil {
+			return err
+		}
+		if a.SeriesID != 0 {
+			return http_helpers.HttpError{
+				Message:    fmt.Sprintf("A modified occurrence moves with its series, appointment %v", a.SeriesID),
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		}
+
+		to, err := tx.GetSchedule(toScheduleID)
+		if err != nil {
+			return err
+		}
+
+		group := []Appointment{a}
+		for _, o := range sortAppointments(from) {
+			if o.SeriesID == a.ID {
+				group = append(group, o)
+			}
+		}
+
+		for i, g := range group {
+			if g.MeetingID != 0 && attendsMeeting(to, g.MeetingID) {
+				return http_helpers.HttpError{
+					Message:    fmt.Sprintf("Schedule %v already attends meeting %v", to.ID, g.MeetingID),
+					StatusCode: http.StatusConflict,
+				}
+			}
+
+			g.ScheduleID = to.ID
+			g = appointmentIn(g, to.Location())
+			if err = ValidateAppointmentInput(to, g); err != nil {
+				invalid := err.(ValidationError)
+				if invalid.hasOnly(RuleNoConflict, RuleNoHold) {
+					invalid.Detail = fmt.Sprintf("Schedule %v is busy at that time", to.ID)
+					invalid.Status = http.StatusConflict
+				}
+				return invalid
+			}
+
+			// Later members of the group are checked against the earlier ones.
+			to.putAppointment(g)
+			group[i] = g
+		}
+
+		for _, g := range group {
+			if _, err = tx.MoveAppointment(g, scheduleID); err != nil {
+				return err
+			}
+		}
+
+		moved = group[0]
+		return nil
+	})
+	return
+}
+
+func attendsMeeting(s Schedule, meetingID int) bool {
+	for _, a := range s.Appointments {
+		if a.MeetingID == meetingID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+	"github.com/go-chi/chi"
+)
+
+type SplitSeriesResponse struct {
+	Original  Appointment `json:"original"`
+	Following Appointment `json:"following"`
+}
+
+func (h *Handler) SkipOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, appointmentID, occurrence, ok := occurrenceParams(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := skipOccurrence(h.Store, scheduleID, appointmentID, occurrence, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("SkipOccurrenceHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to skip occurrence")
+		return
+	}
+
+	w.Header().Set("ETag", appointmentTag(a))
+	http_helpers.RespondWithJSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) ModifyOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, appointmentID, occurrence, ok := occurrenceParams(w, r)
+	if !ok {
+		return
+	}
+
+	var changes Appointment
+	err := json.NewDecoder(r.Body).Decode(&changes)
+	if err != nil {
+		log.Println("ModifyOccurrenceHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	a, err := modifyOccurrence(h.Store, scheduleID, appointmentID, occurrence, changes, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("ModifyOccurrenceHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to modify occurrence")
+		return
+	}
+
+	w.Header().Set("ETag", appointmentTag(a))
+	http_helpers.RespondWithJSON(w, http.StatusCreated, a)
+}
+
+func (h *Handler) SplitSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, appointmentID, occurrence, ok := occurrenceParams(w, r)
+	if !ok {
+		return
+	}
+
+	// An empty body splits the series without changing the following part.
+	var changes Appointment
+	err := json.NewDecoder(r.Body).Decode(&changes)
+	if err != nil && err != io.EOF {
+		log.Println("SplitSeriesHandler Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	original, following, err := splitSeries(h.Store, scheduleID, appointmentID, occurrence, changes, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("SplitSeriesHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to split series")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusCreated, SplitSeriesResponse{Original: original, Following: following})
+}
+
+// occurrenceParams reads the schedule, appointment and occurrence URL params,
+// responding with a 400 and returning false if any is invalid.
+func occurrenceParams(w http.ResponseWriter, r *http.Request) (scheduleID, appointmentID int, occurrence time.Time, ok bool) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	appointmentID, err = convertIDParam(r, "appointmentID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	ts, err := ParseTimestamp(chi.URLParam(r, "occurrence"))
+	if err != nil || ts.IsZero() {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid occurrence")
+		return
+	}
+
+	return scheduleID, appointmentID, ts.Time, true
+}
@@ -0,0 +1,323 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Occurrence Handlers", func() {
+	var (
+		store  *MemoryStore
+		h      *Handler
+		s      Schedule
+		series Appointment
+		start  time.Time
+	)
+
+	occurrenceRequest := func(method string, body []byte, occurrence string) *http.Request {
+		r, _ := http.NewRequest(method, "/", bytes.NewReader(body))
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("scheduleID", strconv.Itoa(s.ID))
+		rctx.URLParams.Add("appointmentID", strconv.Itoa(series.ID))
+		rctx.URLParams.Add("occurrence", occurrence)
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	window := func(from, to time.Time) []Appointment {
+		s, _ := store.GetSchedule(s.ID)
+		var occurrences []Appointment
+		for _, a := range s.Appointments {
+			occurrences = append(occurrences, a.Occurrences(from, to)...)
+		}
+		return occurrences
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{
+			OwnerName: "Tyrion Lannister",
+			TimeZone:  "America/Denver",
+		})
+		start = time.Date(2019, 6, 3, 9, 0, 0, 0, s.Location())
+		rule, _ := ParseRecurrenceRule("FREQ=DAILY;COUNT=5")
+		series, _ = store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+			Recurrence: rule,
+		})
+	})
+
+	Context("#SkipOccurrence", func() {
+		It("Should add the occurrence to the series exceptions", func() {
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("DELETE", nil, "2019-06-04T09:00:00-06:00")
+
+			http.HandlerFunc(h.SkipOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resBody map[string]interface{}
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody["exceptions"]).To(Equal([]interface{}{"2019-06-04T09:00:00-06:00"}))
+
+			occurrences := window(start, start.AddDate(0, 0, 5))
+			Expect(occurrences).To(HaveLen(4))
+			for _, o := range occurrences {
+				Expect(o.StartTime.Day()).NotTo(Equal(4))
+			}
+		})
+
+		It("Should accept the occurrence as Unix seconds", func() {
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("DELETE", nil, strconv.FormatInt(start.AddDate(0, 0, 1).Unix(), 10))
+
+			http.HandlerFunc(h.SkipOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("Should return a StatusNotFound for a time that is not an occurrence", func() {
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("DELETE", nil, "2019-06-04T10:00:00-06:00")
+
+			http.HandlerFunc(h.SkipOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should return a StatusUnprocessableEntity for a non-recurring appointment", func() {
+			series, _ = store.CreateAppointment(Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(start.AddDate(0, 1, 0)),
+				EndTime:    NewTimestamp(start.AddDate(0, 1, 0).Add(time.Hour)),
+			})
+
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("DELETE", nil, start.AddDate(0, 1, 0).Format(time.RFC3339))
+
+			http.HandlerFunc(h.SkipOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		It("Should return a StatusBadRequest for an invalid occurrence", func() {
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("DELETE", nil, "blamo")
+
+			http.HandlerFunc(h.SkipOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("#ModifyOccurrence", func() {
+		It("Should replace the occurrence with a linked standalone appointment", func() {
+			recorder := httptest.NewRecorder()
+			reqBody := []byte(`
+				{
+					"start_time": "2019-06-04T13:00:00-06:00",
+					"end_time": "2019-06-04T14:00:00-06:00"
+				}
+			`)
+			r := occurrenceRequest("PUT", reqBody, "2019-06-04T09:00:00-06:00")
+
+			http.HandlerFunc(h.ModifyOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+			var resBody map[string]interface{}
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody["start_time"]).To(Equal("2019-06-04T13:00:00-06:00"))
+			Expect(resBody["series_id"]).To(BeEquivalentTo(series.ID))
+			Expect(resBody["recurrence_id"]).To(Equal("2019-06-04T09:00:00-06:00"))
+			Expect(resBody).NotTo(HaveKey("recurrence"))
+
+			day := window(start.AddDate(0, 0, 1), start.AddDate(0, 0, 2))
+			Expect(day).To(HaveLen(1))
+			Expect(day[0].StartTime.Hour()).To(Equal(13))
+		})
+
+		It("Should allow the replacement to overlap the occurrence it replaces", func() {
+			recorder := httptest.NewRecorder()
+			reqBody := []byte(`
+				{
+					"start_time": "2019-06-04T09:30:00-06:00",
+					"end_time": "2019-06-04T10:30:00-06:00"
+				}
+			`)
+			r := occurrenceRequest("PUT", reqBody, "2019-06-04T09:00:00-06:00")
+
+			http.HandlerFunc(h.ModifyOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+		})
+
+		It("Should return a StatusUnprocessableEntity and leave the series alone when the replacement conflicts", func() {
+			recorder := httptest.NewRecorder()
+			reqBody := []byte(`
+				{
+					"start_time": "2019-06-05T09:30:00-06:00",
+					"end_time": "2019-06-05T10:30:00-06:00"
+				}
+			`)
+			r := occurrenceRequest("PUT", reqBody, "2019-06-04T09:00:00-06:00")
+
+			http.HandlerFunc(h.ModifyOccurrenceHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+			stored, _ := store.GetAppointment(s.ID, series.ID)
+			Expect(stored.Exceptions).To(BeEmpty())
+		})
+	})
+
+	Context("#SplitSeries", func() {
+		It("Should end the series before the occurrence and continue it in a new one", func() {
+			recorder := httptest.NewRecorder()
+			reqBody := []byte(`
+				{
+					"start_time": "2019-06-05T14:00:00-06:00",
+					"end_time": "2019-06-05T15:00:00-06:00"
+				}
+			`)
+			r := occurrenceRequest("POST", reqBody, "2019-06-05T09:00:00-06:00")
+
+			http.HandlerFunc(h.SplitSeriesHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+			var resBody SplitSeriesResponse
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.Original.ID).To(Equal(series.ID))
+			Expect(resBody.Original.Recurrence.String()).To(Equal("FREQ=DAILY;UNTIL=20190605T145959Z"))
+			Expect(resBody.Following.Recurrence.String()).To(Equal("FREQ=DAILY;COUNT=3"))
+
+			occurrences := window(start, start.AddDate(0, 0, 10))
+			Expect(occurrences).To(HaveLen(5))
+			var hours []int
+			for _, o := range occurrences {
+				hours = append(hours, o.StartTime.In(s.Location()).Hour())
+			}
+			Expect(hours).To(ConsistOf(9, 9, 14, 14, 14))
+		})
+
+		It("Should keep the occurrence's other time when only one is changed", func() {
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("POST", []byte(`{"start_time": "2019-06-05T14:00:00-06:00"}`), "2019-06-05T09:00:00-06:00")
+
+			http.HandlerFunc(h.SplitSeriesHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			var resBody SplitSeriesResponse
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.Following.StartTime.Format(time.RFC3339)).To(Equal("2019-06-05T14:00:00-06:00"))
+			Expect(resBody.Following.EndTime.Format(time.RFC3339)).To(Equal("2019-06-05T15:00:00-06:00"))
+
+			recorder = serve(h.SplitSeriesHandler, "POST", "/", `{"end_time": "2019-06-06T14:30:00-06:00"}`, "scheduleID", strconv.Itoa(s.ID),
+				"appointmentID", strconv.Itoa(resBody.Following.ID), "occurrence", "2019-06-06T14:00:00-06:00")
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.Following.StartTime.Format(time.RFC3339)).To(Equal("2019-06-06T14:00:00-06:00"))
+			Expect(resBody.Following.EndTime.Format(time.RFC3339)).To(Equal("2019-06-06T14:30:00-06:00"))
+		})
+
+		It("Should split without changes when a body of unknown length is empty", func() {
+			params := []string{"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(series.ID), "occurrence", "2019-06-05T09:00:00-06:00"}
+
+			recorder := serveChunked(h.SplitSeriesHandler, "POST", "/", "{", params...)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+			recorder = serveChunked(h.SplitSeriesHandler, "POST", "/", "", params...)
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			var resBody SplitSeriesResponse
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.Following.StartTime.Format(time.RFC3339)).To(Equal("2019-06-05T09:00:00-06:00"))
+			Expect(resBody.Following.Recurrence.String()).To(Equal("FREQ=DAILY;COUNT=3"))
+		})
+
+		It("Should carry later exceptions and replaced occurrences over to the new series", func() {
+			store.Atomically([]int{s.ID}, func(tx Store) error {
+				series.Exceptions = []Timestamp{
+					NewTimestamp(start.AddDate(0, 0, 1)),
+					NewTimestamp(start.AddDate(0, 0, 3)),
+				}
+				tx.UpdateAppointment(series)
+				return nil
+			})
+			recurrenceID := NewTimestamp(start.AddDate(0, 0, 3))
+			override, _ := store.CreateAppointment(Appointment{
+				ScheduleID:   s.ID,
+				StartTime:    NewTimestamp(start.AddDate(0, 0, 3).Add(4 * time.Hour)),
+				EndTime:      NewTimestamp(start.AddDate(0, 0, 3).Add(5 * time.Hour)),
+				SeriesID:     series.ID,
+				RecurrenceID: &recurrenceID,
+			})
+
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("POST", nil, "2019-06-05T09:00:00-06:00")
+
+			http.HandlerFunc(h.SplitSeriesHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+			var resBody SplitSeriesResponse
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.Original.Exceptions).To(HaveLen(1))
+			Expect(resBody.Following.Exceptions).To(HaveLen(1))
+			Expect(resBody.Following.Exceptions[0].Equal(start.AddDate(0, 0, 3))).To(BeTrue())
+
+			stored, _ := store.GetAppointment(s.ID, override.ID)
+			Expect(stored.SeriesID).To(Equal(resBody.Following.ID))
+		})
+
+		It("Should return a StatusUnprocessableEntity when splitting at the first occurrence", func() {
+			recorder := httptest.NewRecorder()
+			r := occurrenceRequest("POST", nil, "2019-06-03T09:00:00-06:00")
+
+			http.HandlerFunc(h.SplitSeriesHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		It("Should return a StatusUnprocessableEntity when the new series conflicts", func() {
+			store.CreateAppointment(Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(start.AddDate(0, 0, 6).Add(5 * time.Hour)),
+				EndTime:    NewTimestamp(start.AddDate(0, 0, 6).Add(6 * time.Hour)),
+			})
+
+			recorder := httptest.NewRecorder()
+			reqBody := []byte(`
+				{
+					"start_time": "2019-06-05T14:00:00-06:00",
+					"end_time": "2019-06-05T15:00:00-06:00",
+					"recurrence": "FREQ=DAILY;COUNT=10"
+				}
+			`)
+			r := occurrenceRequest("POST", reqBody, "2019-06-05T09:00:00-06:00")
+
+			http.HandlerFunc(h.SplitSeriesHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+			stored, _ := store.GetAppointment(s.ID, series.ID)
+			Expect(stored.Recurrence.String()).To(Equal("FREQ=DAILY;COUNT=5"))
+		})
+	})
+})
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+// skipOccurrence removes a single occurrence from a recurring series by
+// adding it to the series' exceptions.
+func skipOccurrence(store Store, scheduleID, appointmentID int, occurrence time.Time, ifMatch string) (updated Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		series, occ, err := findOccurrence(tx, scheduleID, appointmentID, occurrence, ifMatch)
+		if err != nil {
+			return err
+		}
+
+		series.Exceptions = append(series.Exceptions, occ.StartTime)
+
+		updated, err = tx.UpdateAppointment(series)
+		return err
+	})
+	return
+}
+
+// modifyOccurrence replaces a single occurrence of a series with a standalone
+// appointment at new times. The occurrence becomes an exception of the series
+// and the replacement records it through SeriesID and RecurrenceID. The
+// replacement keeps the series' details except those set on changes.
+func modifyOccurrence(store Store, scheduleID, appointmentID int, occurrence time.Time, changes Appointment, ifMatch string) (created Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		series, occ, err := findOccurrence(tx, scheduleID, appointmentID, occurrence, ifMatch)
+		if err != nil {
+			return err
+		}
+
+		s, err := scheduleFor(tx, scheduleID, changes)
+		if err != nil {
+			return err
+		}
+
+		series.Exceptions = append(series.Exceptions, occ.StartTime)
+		s.putAppointment(series)
+
+		replacement := Appointment{
+			ScheduleID:   scheduleID,
+			StartTime:    changes.StartTime.In(s.Location()),
+			EndTime:      changes.EndTime.In(s.Location()),
+			SeriesID:     series.ID,
+			RecurrenceID: occ.RecurrenceID,
+			Status:       series.Status,
+		}
+		replacement.AppointmentDetails = series.AppointmentDetails.merge(changes.AppointmentDetails)
+		if err = checkDetails(replacement.AppointmentDetails); err != nil {
+			return err
+		}
+		if err = ValidateAppointmentInput(s, replacement); err != nil {
+			return err
+		}
+
+		if _, err = tx.UpdateAppointment(series); err != nil {
+			return err
+		}
+
+		created, err = tx.CreateAppointment(replacement)
+		return err
+	})
+	return
+}
+
+// splitSeries ends a series just before the given occurrence and starts a new
+// series there ("this and following"). Unset fields of changes keep the values
+// of the split occurrence and the original rule, and a new start alone keeps
+// the occurrence's duration; exceptions and replaced occurrences at or after
+// the split follow the new series, shifted by however far its start moved.
+func splitSeries(store Store, scheduleID, appointmentID int, occurrence time.Time, changes Appointment, ifMatch string) (original, following Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		series, occ, err := findOccurrence(tx, scheduleID, appointmentID, occurrence, ifMatch)
+		if err != nil {
+			return err
+		}
+		if occ.StartTime.Equal(series.StartTime.Time) {
+			return http_helpers.HttpError{
+				Message:    "Cannot split a series at its first occurrence",
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		}
+
+		s, err := tx.GetSchedule(scheduleID)
+		if err != nil {
+			return err
+		}
+
+		following = Appointment{
+			ScheduleID: scheduleID,
+			StartTime:  occ.StartTime,
+			EndTime:    occ.EndTime,
+			Recurrence: changes.Recurrence,
+			Status:     series.Status,
+		}
+		following.AppointmentDetails = series.AppointmentDetails.merge(changes.AppointmentDetails)
+		if err = checkDetails(following.AppointmentDetails); err != nil {
+			return err
+		}
+		if !changes.StartTime.IsZero() {
+			following.StartTime = changes.StartTime.In(s.Location())
+			following.EndTime = NewTimestamp(following.StartTime.Add(occ.EndTime.Sub(occ.StartTime.Time)))
+		}
+		if !changes.EndTime.IsZero() {
+			following.EndTime = changes.EndTime.In(s.Location())
+		}
+		shift := following.StartTime.Sub(occ.StartTime.Time)
+
+		before := series.Recurrence.countBefore(series.StartTime.Time, occ.StartTime.Time)
+		if following.Recurrence == nil {
+			rule := *series.Recurrence
+			if rule.Count > 0 {
+				rule.Count -= before
+			}
+			following.Recurrence = &rule
+		}
+
+		originalRule := *series.Recurrence
+		originalRule.Count = 0
+		originalRule.Until = occ.StartTime.Add(-time.Second)
+
+		original = series
+		original.Recurrence = &originalRule
+		original.Exceptions = nil
+		for _, ts := range series.Exceptions {
+			if ts.Before(occ.StartTime.Time) {
+				original.Exceptions = append(original.Exceptions, ts)
+			} else {
+				following.Exceptions = append(following.Exceptions, shiftTimestamp(ts, shift))
+			}
+		}
+
+		s.putAppointment(original)
+		if err = ValidateAppointmentInput(s, following); err != nil {
+			return err
+		}
+
+		if original, err = tx.UpdateAppointment(original); err != nil {
+			return err
+		}
+		if following, err = tx.CreateAppointment(following); err != nil {
+			return err
+		}
+
+		for _, a := range s.Appointments {
+			if a.SeriesID != series.ID || a.RecurrenceID == nil || a.RecurrenceID.Before(occ.StartTime.Time) {
+				continue
+			}
+			recurrenceID := shiftTimestamp(*a.RecurrenceID, shift)
+			a.SeriesID = following.ID
+			a.RecurrenceID = &recurrenceID
+			if _, err = tx.UpdateAppointment(a); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	return
+}
+
+// findOccurrence loads a recurring appointment, checking it against ifMatch,
+// and its occurrence originally starting at occurrence.
+func findOccurrence(tx Store, scheduleID, appointmentID int, occurrence time.Time, ifMatch string) (series, occ Appointment, err error) {
+	series, err = tx.GetAppointment(scheduleID, appointmentID)
+	if err != nil {
+		log.Println("findOccurrence - ", err.Error())
+		return
+	}
+	if err = checkIfMatch(ifMatch, appointmentTag(series)); err != nil {
+		return
+	}
+
+	if series.Recurrence == nil {
+		err = http_helpers.HttpError{
+			Message:    "Appointment is not recurring",
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+		return
+	}
+
+	occ, found := series.occurrenceAt(occurrence)
+	if !found {
+		err = http_helpers.NotFoundError{
+			Message:    "no occurrence of appointment " + occurrence.Format(time.RFC3339),
+			EntityType: "Occurrence",
+		}
+	}
+	return
+}
+
+func shiftTimestamp(ts Timestamp, by time.Duration) Timestamp {
+	ts.Time = ts.Time.Add(by)
+	return ts
+}
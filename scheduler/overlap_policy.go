@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const (
+	ClosedIntervals   = "closed"
+	HalfOpenIntervals = "half_open"
+
+	maxBufferMinutes = 24 * 60
+
+	// maxReach is the largest reach any overlap policy can have.
+	maxReach = 2 * maxBufferMinutes * time.Minute
+)
+
+// OverlapPolicy decides when appointments on a schedule conflict. With closed
+// intervals (the default) appointments sharing a boundary conflict; with
+// half-open intervals, [start, end), they can be booked back to back.
+//
+// Buffers extend the time each appointment blocks before its start and after
+// its end, and blocked times may not overlap: two neighbouring appointments
+// need the first one's BufferAfterMinutes plus the second one's
+// BufferBeforeMinutes between them.
+type OverlapPolicy struct {
+	Intervals           string `json:"intervals"`
+	BufferBeforeMinutes int    `json:"buffer_before_minutes"`
+	BufferAfterMinutes  int    `json:"buffer_after_minutes"`
+}
+
+func (p OverlapPolicy) halfOpen() bool {
+	return p.Intervals == HalfOpenIntervals
+}
+
+func (p OverlapPolicy) bufferBefore() time.Duration {
+	return time.Duration(p.BufferBeforeMinutes) * time.Minute
+}
+
+func (p OverlapPolicy) bufferAfter() time.Duration {
+	return time.Duration(p.BufferAfterMinutes) * time.Minute
+}
+
+// reach is how far apart two appointments can be and still conflict.
+func (p OverlapPolicy) reach() time.Duration {
+	return p.bufferBefore() + p.bufferAfter()
+}
+
+// blocked returns the time an appointment spanning i keeps busy.
+func (p OverlapPolicy) blocked(i Interval) Interval {
+	return Interval{
+		Start: NewTimestamp(i.Start.Add(-p.bufferBefore())),
+		End:   NewTimestamp(i.End.Add(p.bufferAfter())),
+	}
+}
+
+// conflicts reports whether two blocked intervals overlap.
+func (p OverlapPolicy) conflicts(i, j Interval) bool {
+	if p.halfOpen() {
+		return i.Start.Before(j.End.Time) && j.Start.Before(i.End.Time)
+	}
+
+	return i.overlaps(j)
+}
+
+// freeFrom returns the earliest time another blocked interval may start after
+// busy. Times have second precision, so with closed intervals that is one
+// second after busy ends.
+func (p OverlapPolicy) freeFrom(busy Interval) time.Time {
+	if p.halfOpen() {
+		return busy.End.Time
+	}
+
+	return busy.End.Add(time.Second)
+}
+
+// freeUntil returns the latest time another blocked interval may end before
+// busy.
+func (p OverlapPolicy) freeUntil(busy Interval) time.Time {
+	if p.halfOpen() {
+		return busy.Start.Time
+	}
+
+	return busy.Start.Add(-time.Second)
+}
+
+// checkOverlapPolicy defaults empty interval semantics and rejects unknown
+// ones or out of range buffers.
+func checkOverlapPolicy(s *Schedule) error {
+	p := &s.OverlapPolicy
+	switch p.Intervals {
+	case "":
+		p.Intervals = ClosedIntervals
+	case ClosedIntervals, HalfOpenIntervals:
+	default:
+		return http_helpers.HttpError{
+			Message:    "Invalid overlap_policy intervals",
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	for _, minutes := range []int{p.BufferBeforeMinutes, p.BufferAfterMinutes} {
+		if minutes < 0 || minutes > maxBufferMinutes {
+			return http_helpers.HttpError{
+				Message:    "Invalid overlap_policy buffer",
+				StatusCode: http.StatusUnprocessableEntity,
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,440 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurrenceHorizon bounds how far ahead an open-ended series is expanded
+// when checking it for conflicts.
+const recurrenceHorizon = 366 * 24 * time.Hour
+
+const maxIdleRecurrencePeriods = 1000
+
+// maxRecurrenceCount bounds COUNT, keeping every bounded series cheap to walk.
+const maxRecurrenceCount = 10000
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RecurrenceRule is the subset of an RFC 5545 RRULE supported for repeating
+// appointments: FREQ, INTERVAL, BYDAY (DAILY and WEEKLY only), COUNT and
+// UNTIL. It is exchanged in JSON as the RRULE string, e.g.
+// "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+//
+// As in RFC 5545 the appointment's own start is always the first occurrence
+// and counts towards COUNT. Occurrences keep the wall-clock time of the first
+// one in the schedule's time zone.
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    time.Time
+}
+
+func ParseRecurrenceRule(rule string) (*RecurrenceRule, error) {
+	r := &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(strings.TrimPrefix(rule, "RRULE:"), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = value
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && (r.Count < 1 || r.Count > maxRecurrenceCount) {
+				return nil, fmt.Errorf("COUNT must be between 1 and %v", maxRecurrenceCount)
+			}
+		case "UNTIL":
+			r.Until, err = parseRRULEUntil(value)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, found := rruleWeekdays[day]
+				if !found {
+					return nil, fmt.Errorf("invalid BYDAY value %q", day)
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v value %q", key, value)
+		}
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(r.ByDay, func(i, j int) bool {
+		return weekdayOffset(r.ByDay[i]) < weekdayOffset(r.ByDay[j])
+	})
+
+	return r, nil
+}
+
+func (r RecurrenceRule) validate() error {
+	switch r.Freq {
+	case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+	case "":
+		return fmt.Errorf("RRULE requires FREQ")
+	default:
+		return fmt.Errorf("unsupported FREQ %q", r.Freq)
+	}
+
+	if r.Interval < 1 {
+		return fmt.Errorf("INTERVAL must be at least 1")
+	}
+	if r.Count < 0 {
+		return fmt.Errorf("COUNT must be at least 1")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("COUNT and UNTIL cannot both be set")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqDaily && r.Freq != FreqWeekly {
+		return fmt.Errorf("BYDAY is only supported with FREQ=DAILY or FREQ=WEEKLY")
+	}
+
+	return nil
+}
+
+// parseRRULEUntil accepts the UTC date-time form (20190630T170000Z) and the
+// date form (20190630); a bare date includes that whole day.
+func parseRRULEUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return t, err
+	}
+
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+func (r RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, weekday := range r.ByDay {
+			days = append(days, strings.ToUpper(weekday.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (r RecurrenceRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *RecurrenceRule) UnmarshalJSON(data []byte) error {
+	var rule string
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+
+	parsed, err := ParseRecurrenceRule(rule)
+	if err != nil {
+		return err
+	}
+
+	*r = *parsed
+	return nil
+}
+
+// eachStart calls fn with every occurrence start of a series beginning at
+// dtstart, in chronological order, until fn returns false or the series ends.
+func (r RecurrenceRule) eachStart(dtstart time.Time, fn func(time.Time) bool) {
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		emitted++
+		return fn(t) && (r.Count == 0 || emitted < r.Count)
+	}
+
+	if !emit(dtstart) {
+		return
+	}
+
+	year, month, day := dtstart.Date()
+	hour, min, sec := dtstart.Clock()
+	loc := dtstart.Location()
+
+	// WEEKLY candidates are counted from the Monday of the first week.
+	weekStart := day - weekdayOffset(dtstart.Weekday())
+
+	// Guards against rules such as FREQ=DAILY;INTERVAL=7;BYDAY=TU starting on a
+	// Monday, which can never produce another occurrence.
+	idle := 0
+	for period := 0; idle < maxIdleRecurrencePeriods; period++ {
+		step := period * r.Interval
+
+		var candidates []time.Time
+		switch r.Freq {
+		case FreqDaily:
+			candidates = []time.Time{time.Date(year, month, day+step, hour, min, sec, 0, loc)}
+		case FreqWeekly:
+			if len(r.ByDay) == 0 {
+				candidates = []time.Time{time.Date(year, month, day+7*step, hour, min, sec, 0, loc)}
+			}
+			for _, weekday := range r.ByDay {
+				candidates = append(candidates, time.Date(year, month, weekStart+7*step+weekdayOffset(weekday), hour, min, sec, 0, loc))
+			}
+		case FreqMonthly:
+			// Months without the start day are skipped, as in RFC 5545.
+			t := time.Date(year, month+time.Month(step), day, hour, min, sec, 0, loc)
+			if t.Day() == day {
+				candidates = []time.Time{t}
+			}
+		case FreqYearly:
+			t := time.Date(year+step, month, day, hour, min, sec, 0, loc)
+			if t.Day() == day {
+				candidates = []time.Time{t}
+			}
+		}
+
+		idle++
+		for _, t := range candidates {
+			if !t.After(dtstart) || !r.matchesByDay(t) {
+				continue
+			}
+			idle = 0
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+func (r RecurrenceRule) matchesByDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+
+	for _, weekday := range r.ByDay {
+		if t.Weekday() == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// weekdayOffset numbers days from Monday, the RFC 5545 default week start.
+func weekdayOffset(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+// lastStart returns the start of the last occurrence of a COUNT series
+// beginning at dtstart. Which periods produce occurrences repeats in a fixed
+// cycle, so whole cycles are skipped rather than walking the series.
+func (r RecurrenceRule) lastStart(dtstart time.Time) time.Time {
+	remaining := r.Count - 1
+	if remaining <= 0 {
+		return dtstart
+	}
+
+	year, month, day := dtstart.Date()
+	hour, min, sec := dtstart.Clock()
+	loc := dtstart.Location()
+
+	if r.Freq == FreqWeekly && len(r.ByDay) > 0 {
+		// The first week only has the days after dtstart; later weeks have
+		// every BYDAY day.
+		weekStart := day - weekdayOffset(dtstart.Weekday())
+		var first []int
+		for _, weekday := range r.ByDay {
+			if weekdayOffset(weekday) > weekdayOffset(dtstart.Weekday()) {
+				first = append(first, weekdayOffset(weekday))
+			}
+		}
+		if remaining <= len(first) {
+			return time.Date(year, month, weekStart+first[remaining-1], hour, min, sec, 0, loc)
+		}
+
+		remaining -= len(first)
+		week := 1 + (remaining-1)/len(r.ByDay)
+		offset := weekdayOffset(r.ByDay[(remaining-1)%len(r.ByDay)])
+		return time.Date(year, month, weekStart+7*week*r.Interval+offset, hour, min, sec, 0, loc)
+	}
+
+	// period returns the candidate of the given period and whether it is an
+	// occurrence, as in eachStart.
+	var period func(p int) (time.Time, bool)
+	cycle := 1
+	switch r.Freq {
+	case FreqDaily:
+		period = func(p int) (time.Time, bool) {
+			t := time.Date(year, month, day+p*r.Interval, hour, min, sec, 0, loc)
+			return t, r.matchesByDay(t)
+		}
+		if len(r.ByDay) > 0 {
+			cycle = 7
+		}
+	case FreqWeekly:
+		period = func(p int) (time.Time, bool) {
+			return time.Date(year, month, day+7*p*r.Interval, hour, min, sec, 0, loc), true
+		}
+	case FreqMonthly:
+		period = func(p int) (time.Time, bool) {
+			t := time.Date(year, month+time.Month(p*r.Interval), day, hour, min, sec, 0, loc)
+			return t, t.Day() == day
+		}
+		// Month lengths repeat every year, or every 400 years for the 29th.
+		switch {
+		case day == 29:
+			cycle = 4800 / gcd(r.Interval, 4800)
+		case day > 29:
+			cycle = 12 / gcd(r.Interval, 12)
+		}
+	case FreqYearly:
+		period = func(p int) (time.Time, bool) {
+			t := time.Date(year+p*r.Interval, month, day, hour, min, sec, 0, loc)
+			return t, t.Day() == day
+		}
+		if month == time.February && day == 29 {
+			cycle = 400 / gcd(r.Interval, 400)
+		}
+	}
+
+	perCycle := 0
+	for p := 1; p <= cycle; p++ {
+		if _, ok := period(p); ok {
+			perCycle++
+		}
+	}
+	if perCycle == 0 {
+		return dtstart
+	}
+
+	skipped := (remaining - 1) / perCycle
+	remaining -= skipped * perCycle
+	for p := skipped*cycle + 1; ; p++ {
+		if t, ok := period(p); ok {
+			if remaining--; remaining == 0 {
+				return t
+			}
+		}
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// countBefore returns how many occurrences of a series beginning at dtstart
+// start before t, ignoring exceptions as COUNT does.
+func (r RecurrenceRule) countBefore(dtstart, t time.Time) int {
+	count := 0
+	r.eachStart(dtstart, func(start time.Time) bool {
+		if !start.Before(t) {
+			return false
+		}
+		count++
+		return true
+	})
+
+	return count
+}
+
+// Occurrences expands a into the occurrences that intersect [from, to),
+// leaving out its exceptions. An appointment without a recurrence rule is its
+// own single occurrence.
+// Expanded occurrences keep the series ID and carry their original start as
+// RecurrenceID.
+func (a Appointment) Occurrences(from, to time.Time) []Appointment {
+	if a.Recurrence == nil {
+		if a.StartTime.Before(to) && a.EndTime.After(from) {
+			return []Appointment{a}
+		}
+		return nil
+	}
+
+	duration := a.EndTime.Sub(a.StartTime.Time)
+
+	excluded := make(map[int64]bool, len(a.Exceptions))
+	for _, ts := range a.Exceptions {
+		excluded[ts.Unix()] = true
+	}
+
+	var occurrences []Appointment
+	a.Recurrence.eachStart(a.StartTime.Time, func(start time.Time) bool {
+		if !start.Before(to) {
+			return false
+		}
+
+		end := start.Add(duration)
+		if end.After(from) && !excluded[start.Unix()] {
+			occurrence := a
+			occurrence.StartTime = Timestamp{Time: start, Legacy: a.StartTime.Legacy}
+			occurrence.EndTime = Timestamp{Time: end, Legacy: a.EndTime.Legacy}
+			recurrenceID := occurrence.StartTime
+			occurrence.RecurrenceID = &recurrenceID
+			occurrences = append(occurrences, occurrence)
+		}
+
+		return true
+	})
+
+	return occurrences
+}
+
+// occurrenceAt finds the occurrence of a that originally starts at start.
+func (a Appointment) occurrenceAt(start time.Time) (Appointment, bool) {
+	if a.Recurrence == nil {
+		return a, false
+	}
+
+	for _, o := range a.Occurrences(start, start.Add(time.Second)) {
+		if o.StartTime.Equal(start) {
+			return o, true
+		}
+	}
+
+	return a, false
+}
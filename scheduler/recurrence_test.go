@@ -0,0 +1,189 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Recurrence", func() {
+	var denver *time.Location
+
+	BeforeEach(func() {
+		denver, _ = time.LoadLocation("America/Denver")
+	})
+
+	starts := func(occurrences []Appointment) []string {
+		formatted := []string{}
+		for _, o := range occurrences {
+			formatted = append(formatted, o.StartTime.Format("2006-01-02 15:04 MST"))
+		}
+		return formatted
+	}
+
+	series := func(rule string, start time.Time, duration time.Duration) Appointment {
+		r, err := ParseRecurrenceRule(rule)
+		Expect(err).NotTo(HaveOccurred())
+		return Appointment{
+			ID:         7,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(duration)),
+			Recurrence: r,
+		}
+	}
+
+	Context("#ParseRecurrenceRule", func() {
+		It("Should parse supported parts and write them back out", func() {
+			r, err := ParseRecurrenceRule("FREQ=WEEKLY;INTERVAL=2;BYDAY=WE,MO;COUNT=10")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Freq).To(Equal(FreqWeekly))
+			Expect(r.Interval).To(Equal(2))
+			Expect(r.ByDay).To(Equal([]time.Weekday{time.Monday, time.Wednesday}))
+			Expect(r.Count).To(Equal(10))
+			Expect(r.String()).To(Equal("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10"))
+		})
+
+		It("Should parse UNTIL in date-time and date forms", func() {
+			r, err := ParseRecurrenceRule("RRULE:FREQ=DAILY;UNTIL=20190630T170000Z")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Until).To(Equal(time.Date(2019, 6, 30, 17, 0, 0, 0, time.UTC)))
+
+			r, err = ParseRecurrenceRule("FREQ=DAILY;UNTIL=20190630")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Until).To(Equal(time.Date(2019, 6, 30, 23, 59, 59, 0, time.UTC)))
+		})
+
+		It("Should reject invalid rules", func() {
+			for _, rule := range []string{
+				"",
+				"INTERVAL=2",
+				"FREQ=HOURLY",
+				"FREQ=DAILY;INTERVAL=0",
+				"FREQ=DAILY;COUNT=0",
+				"FREQ=DAILY;COUNT=10001",
+				"FREQ=DAILY;COUNT=3;UNTIL=20190630",
+				"FREQ=WEEKLY;BYDAY=XX",
+				"FREQ=MONTHLY;BYDAY=MO",
+				"FREQ=DAILY;BYMONTH=1",
+			} {
+				_, err := ParseRecurrenceRule(rule)
+				Expect(err).To(HaveOccurred(), rule)
+			}
+		})
+
+		It("Should be exchanged in JSON as the RRULE string", func() {
+			var a Appointment
+			err := json.Unmarshal([]byte(`{"start_time": 5, "end_time": 9, "recurrence": "FREQ=DAILY;COUNT=3"}`), &a)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.Recurrence.Count).To(Equal(3))
+
+			out, _ := json.Marshal(a)
+			Expect(string(out)).To(ContainSubstring(`"recurrence":"FREQ=DAILY;COUNT=3"`))
+
+			err = json.Unmarshal([]byte(`{"start_time": 5, "end_time": 9, "recurrence": "FREQ=SOMETIMES"}`), &a)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("#Occurrences", func() {
+		It("Should return a non-recurring appointment only when it intersects the window", func() {
+			start := time.Date(2019, 6, 3, 9, 0, 0, 0, denver)
+			a := Appointment{StartTime: NewTimestamp(start), EndTime: NewTimestamp(start.Add(time.Hour))}
+
+			Expect(a.Occurrences(start.Add(-time.Hour), start.Add(time.Minute))).To(Equal([]Appointment{a}))
+			Expect(a.Occurrences(start.Add(time.Hour), start.Add(2*time.Hour))).To(BeEmpty())
+		})
+
+		It("Should expand weekly rules on the requested days", func() {
+			// Monday 3 June 2019.
+			a := series("FREQ=WEEKLY;BYDAY=MO,TH;COUNT=5", time.Date(2019, 6, 3, 9, 0, 0, 0, denver), time.Hour)
+
+			occurrences := a.Occurrences(time.Date(2019, 6, 1, 0, 0, 0, 0, denver), time.Date(2019, 7, 1, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{
+				"2019-06-03 09:00 MDT",
+				"2019-06-06 09:00 MDT",
+				"2019-06-10 09:00 MDT",
+				"2019-06-13 09:00 MDT",
+				"2019-06-17 09:00 MDT",
+			}))
+			Expect(occurrences[1].ID).To(Equal(7))
+			Expect(occurrences[1].EndTime.Sub(occurrences[1].StartTime.Time)).To(Equal(time.Hour))
+			Expect(occurrences[1].RecurrenceID.Equal(occurrences[1].StartTime.Time)).To(BeTrue())
+		})
+
+		It("Should honor INTERVAL for weekly rules", func() {
+			a := series("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO;COUNT=3", time.Date(2019, 6, 3, 9, 0, 0, 0, denver), time.Hour)
+
+			occurrences := a.Occurrences(time.Date(2019, 6, 1, 0, 0, 0, 0, denver), time.Date(2019, 8, 1, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{
+				"2019-06-03 09:00 MDT",
+				"2019-06-17 09:00 MDT",
+				"2019-07-01 09:00 MDT",
+			}))
+		})
+
+		It("Should filter daily rules by BYDAY and stop at UNTIL", func() {
+			// Friday 7 June 2019; the weekend is skipped.
+			a := series("FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20190611T235959Z", time.Date(2019, 6, 7, 9, 0, 0, 0, denver), 15*time.Minute)
+
+			occurrences := a.Occurrences(time.Date(2019, 6, 1, 0, 0, 0, 0, denver), time.Date(2019, 7, 1, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{
+				"2019-06-07 09:00 MDT",
+				"2019-06-10 09:00 MDT",
+				"2019-06-11 09:00 MDT",
+			}))
+		})
+
+		It("Should keep the wall-clock time across daylight saving changes", func() {
+			a := series("FREQ=DAILY;COUNT=3", time.Date(2019, 3, 9, 9, 0, 0, 0, denver), time.Hour)
+
+			occurrences := a.Occurrences(time.Date(2019, 3, 1, 0, 0, 0, 0, denver), time.Date(2019, 4, 1, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{
+				"2019-03-09 09:00 MST",
+				"2019-03-10 09:00 MDT",
+				"2019-03-11 09:00 MDT",
+			}))
+		})
+
+		It("Should skip months without the start day for monthly rules", func() {
+			a := series("FREQ=MONTHLY;COUNT=3", time.Date(2019, 1, 31, 9, 0, 0, 0, denver), time.Hour)
+
+			occurrences := a.Occurrences(time.Date(2019, 1, 1, 0, 0, 0, 0, denver), time.Date(2020, 1, 1, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{
+				"2019-01-31 09:00 MST",
+				"2019-03-31 09:00 MDT",
+				"2019-05-31 09:00 MDT",
+			}))
+		})
+
+		It("Should only return occurrences inside the window for open-ended rules", func() {
+			a := series("FREQ=DAILY", time.Date(2019, 6, 1, 9, 0, 0, 0, denver), time.Hour)
+
+			occurrences := a.Occurrences(time.Date(2020, 6, 1, 0, 0, 0, 0, denver), time.Date(2020, 6, 3, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{
+				"2020-06-01 09:00 MDT",
+				"2020-06-02 09:00 MDT",
+			}))
+		})
+
+		It("Should terminate for rules that can never repeat", func() {
+			// Monday start with a seven day step only ever lands on Mondays.
+			a := series("FREQ=DAILY;INTERVAL=7;BYDAY=TU", time.Date(2019, 6, 3, 9, 0, 0, 0, denver), time.Hour)
+
+			occurrences := a.Occurrences(time.Date(2019, 6, 1, 0, 0, 0, 0, denver), time.Date(2020, 6, 1, 0, 0, 0, 0, denver))
+
+			Expect(starts(occurrences)).To(Equal([]string{"2019-06-03 09:00 MDT"}))
+		})
+	})
+})
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	SortByID        = "id"
+	SortByOwnerName = "owner_name"
+
+	defaultScheduleLimit = 50
+	maxScheduleLimit     = 200
+)
+
+// ScheduleQuery selects a page of schedules for Store.ListSchedules.
+type ScheduleQuery struct {
+	// OwnerName matches exactly and OwnerNamePrefix matches the start of the
+	// owner name; both are case sensitive. Empty values match everything.
+	OwnerName       string
+	OwnerNamePrefix string
+
+	SortBy     string
+	Descending bool
+
+	// After resumes listing just past the given position. Pages are keyed
+	// on the sort field and ID rather than an offset, so schedules created
+	// or deleted between requests do not shift later pages.
+	After *ScheduleCursor
+	Limit int
+}
+
+// ScheduleCursor is the position of the last schedule of a page.
+type ScheduleCursor struct {
+	SortBy     string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	OwnerName  string `json:"o,omitempty"`
+	ID         int    `json:"i"`
+}
+
+func newScheduleCursor(q ScheduleQuery, last Schedule) *ScheduleCursor {
+	c := &ScheduleCursor{SortBy: q.SortBy, Descending: q.Descending, ID: last.ID}
+	if q.SortBy == SortByOwnerName {
+		c.OwnerName = last.OwnerName
+	}
+
+	return c
+}
+
+// Encode renders the cursor as the opaque token handed to clients.
+func (c ScheduleCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeScheduleCursor(token string) (*ScheduleCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	var c ScheduleCursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	return &c, nil
+}
+
+func (q ScheduleQuery) matches(s Schedule) bool {
+	if q.OwnerName != "" && s.OwnerName != q.OwnerName {
+		return false
+	}
+
+	return strings.HasPrefix(s.OwnerName, q.OwnerNamePrefix)
+}
+
+// less orders schedules by the query's sort field, breaking ties by ID.
+func (q ScheduleQuery) less(a, b Schedule) bool {
+	if q.Descending {
+		a, b = b, a
+	}
+
+	if q.SortBy == SortByOwnerName && a.OwnerName != b.OwnerName {
+		return a.OwnerName < b.OwnerName
+	}
+
+	return a.ID < b.ID
+}
+
+func (q ScheduleQuery) afterCursor(s Schedule) bool {
+	if q.After == nil {
+		return true
+	}
+
+	return q.less(Schedule{ID: q.After.ID, OwnerName: q.After.OwnerName}, s)
+}
+
+// pageSchedules applies q to an unordered set of schedules, returning the
+// requested page and the number of schedules matching the filters.
+func pageSchedules(q ScheduleQuery, schedules []Schedule) ([]Schedule, int) {
+	var matching []Schedule
+	for _, s := range schedules {
+		if q.matches(s) {
+			matching = append(matching, s)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return q.less(matching[i], matching[j])
+	})
+
+	page := []Schedule{}
+	for _, s := range matching {
+		if len(page) == q.Limit {
+			break
+		}
+		if q.afterCursor(s) {
+			page = append(page, s)
+		}
+	}
+
+	return page, len(matching)
+}
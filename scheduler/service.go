@@ -1,49 +1,259 @@
 package scheduler
 
 import (
+	"fmt"
 	"log"
 	"net/http"
-	"sort"
+	"time"
 
 	"github.com/ckaminer/go-utils/http_helpers"
 )
 
-func createAppointment(a Appointment, scheduleID int) (Appointment, error) {
-	var s Schedule
-	s, found := ScheduleCollection[scheduleID]
-	if !found {
-		log.Println("ScheduleDetailsService - no schedule found for ID: ", scheduleID)
-		return a, http_helpers.HttpError{
-			StatusCode: http.StatusNotFound,
-			Message:    "Schedule not found",
+func createSchedule(store Store, s Schedule) (Schedule, error) {
+	if err := checkTimeZone(&s); err != nil {
+		return s, err
+	}
+	if err := checkOverlapPolicy(&s); err != nil {
+		return s, err
+	}
+	if err := checkBookingPolicy(&s); err != nil {
+		return s, err
+	}
+	if err := checkWorkingHours(&s); err != nil {
+		return s, err
+	}
+	if err := checkCalendarIDs(store, &s); err != nil {
+		return s, err
+	}
+	s.Blackouts = nil
+
+	return store.CreateSchedule(s)
+}
+
+// updateSchedule applies body to a schedule's representation, as a JSON merge
+// patch when merge is set and as a replacement otherwise. Only owner_name,
+// time_zone, overlap_policy, booking_policy, working_hours and calendar_ids
+// can be changed this way, and a new overlap policy must not make existing
+// appointments conflict. Existing appointments breaking a new booking policy
+// or outside new working hours are kept.
+func updateSchedule(store Store, id int, body []byte, merge bool, ifMatch string) (updated Schedule, err error) {
+	err = store.Atomically([]int{id}, func(tx Store) error {
+		s, err := tx.GetSchedule(id)
+		if err != nil {
+			log.Println("updateSchedule - ", err.Error())
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		var changes struct {
+			OwnerName     string        `json:"owner_name"`
+			TimeZone      string        `json:"time_zone"`
+			OverlapPolicy OverlapPolicy `json:"overlap_policy"`
+			BookingPolicy BookingPolicy `json:"booking_policy"`
+			WorkingHours  *WorkingHours `json:"working_hours"`
+			CalendarIDs   []int         `json:"calendar_ids"`
+		}
+		readOnly := []string{"id", "appointments", "blackouts", "holds"}
+		if err = applyDocument(s, body, merge, readOnly, &changes); err != nil {
+			return err
+		}
+
+		previous := s.OverlapPolicy
+		s.OwnerName, s.TimeZone, s.OverlapPolicy = changes.OwnerName, changes.TimeZone, changes.OverlapPolicy
+		s.BookingPolicy, s.WorkingHours, s.CalendarIDs = changes.BookingPolicy, changes.WorkingHours, changes.CalendarIDs
+		if err = checkTimeZone(&s); err != nil {
+			return err
+		}
+		if err = checkOverlapPolicy(&s); err != nil {
+			return err
+		}
+		if err = checkBookingPolicy(&s); err != nil {
+			return err
+		}
+		if err = checkWorkingHours(&s); err != nil {
+			return err
+		}
+		if err = checkCalendarIDs(tx, &s); err != nil {
+			return err
+		}
+		if s.OverlapPolicy != previous {
+			if err = checkExistingAppointments(s); err != nil {
+				return err
+			}
+		}
+
+		updated, err = tx.UpdateSchedule(s)
+		return err
+	})
+	return
+}
+
+// checkExistingAppointments checks that no appointment on s conflicts with
+// the others, as after a change to the schedule's overlap policy. Working
+// hours, blackouts, holds and the booking policy are not enforced on
+// appointments already booked.
+func checkExistingAppointments(s Schedule) error {
+	ignored := append([]string{RuleWithinWorkingHours, RuleNoBlackout, RuleNoHold}, bookingPolicyRules...)
+	for _, a := range sortAppointments(s) {
+		if !a.busy() {
+			continue
+		}
+		s.removeAppointment(a.ID)
+		err := ValidateAppointmentInput(s, a)
+		s.putAppointment(a)
+
+		if err == nil {
+			continue
+		}
+		if err = err.(ValidationError).without(ignored...); err != nil {
+			invalid := err.(ValidationError)
+			invalid.Detail = fmt.Sprintf("Appointment %v would conflict under the new overlap policy", a.ID)
+			invalid.Status = http.StatusConflict
+			return invalid
 		}
 	}
 
-	validAppt := ValidateAppointmentInput(s, a)
-	if !validAppt {
-		return a, http_helpers.HttpError{
-			Message:    "Invalid appointment time",
+	return nil
+}
+
+func deleteSchedule(store Store, id int, ifMatch string) (deleted Schedule, err error) {
+	err = store.Atomically([]int{id}, func(tx Store) error {
+		s, err := tx.GetSchedule(id)
+		if err != nil {
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		deleted, err = tx.DeleteSchedule(id)
+		return err
+	})
+	return
+}
+
+// checkTimeZone defaults an empty time zone and rejects unknown ones.
+func checkTimeZone(s *Schedule) error {
+	if s.TimeZone == "" {
+		s.TimeZone = defaultTimeZone
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return http_helpers.HttpError{
+			Message:    "Invalid time zone",
 			StatusCode: http.StatusUnprocessableEntity,
 		}
 	}
 
-	a.ScheduleID = s.ID
+	return nil
+}
+
+// createAppointment validates and inserts the appointment while holding the
+// schedule's write lock, so concurrent requests cannot double-book a slot.
+func createAppointment(store Store, a Appointment, scheduleID int, ifMatch string) (created Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		s, err := scheduleFor(tx, scheduleID, a)
+		if err != nil {
+			log.Println("createAppointment - ", err.Error())
+			return err
+		}
+		if err = checkIfMatch(ifMatch, scheduleTag(s)); err != nil {
+			return err
+		}
+
+		if err = checkDetails(a.AppointmentDetails); err != nil {
+			return err
+		}
+		if err = checkInitialStatus(&a); err != nil {
+			return err
+		}
+		a.ScheduleID = s.ID
+		a = appointmentIn(a, s.Location())
 
-	a.ID = AppointmentsCreatedCount + 1
-	s.Appointments[a.ID] = a
-	AppointmentsCreatedCount++
+		if err = ValidateAppointmentInput(s, a); err != nil {
+			return err
+		}
 
-	return a, nil
+		created, err = tx.CreateAppointment(a)
+		return err
+	})
+	return
 }
 
-func ValidateAppointmentInput(s Schedule, a Appointment) bool {
-	if a.StartTime >= a.EndTime || a.StartTime == 0 {
+// updateAppointment applies body to an appointment's representation like
+// updateSchedule does for schedules. New times are validated against the rest
+// of the schedule, leaving out the appointment's own current times. The
+// booking policy only applies to times that change: lead time and the advance
+// horizon to a new start, the other limits to a new start or end. The times of
+// a meeting's copy cannot change, as that would leave its attendees apart.
+func updateAppointment(store Store, scheduleID, appointmentID int, body []byte, merge bool, ifMatch string) (updated Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		current, err := tx.GetAppointment(scheduleID, appointmentID)
+		if err != nil {
+			log.Println("updateAppointment - ", err.Error())
+			return err
+		}
+		if err = checkIfMatch(ifMatch, appointmentTag(current)); err != nil {
+			return err
+		}
+
+		var a Appointment
+		readOnly := []string{"id", "schedule_id", "series_id", "recurrence_id", "meeting_id", "status", "status_changes"}
+		if err = applyDocument(current, body, merge, readOnly, &a); err != nil {
+			return err
+		}
+		if err = checkDetails(a.AppointmentDetails); err != nil {
+			return err
+		}
+
+		s, err := scheduleFor(tx, scheduleID, a)
+		if err != nil {
+			return err
+		}
+		a = appointmentIn(a, s.Location())
+		if current.MeetingID != 0 && !sameTimes(a, current) {
+			return http_helpers.HttpError{
+				Message: fmt.Sprintf("Appointment %v is a copy of meeting %v; delete and re-create the meeting at /meetings/%v to change its times",
+					current.ID, current.MeetingID, current.MeetingID),
+				StatusCode: http.StatusConflict,
+			}
+		}
+		s.removeAppointment(appointmentID)
+		if err = ValidateAppointmentInput(s, a); err != nil {
+			var ignored []string
+			if a.StartTime.Equal(current.StartTime.Time) {
+				ignored = append(ignored, RuleMinLeadTime, RuleMaxAdvance)
+				if a.EndTime.Equal(current.EndTime.Time) {
+					ignored = bookingPolicyRules
+				}
+			}
+			if err = err.(ValidationError).without(ignored...); err != nil {
+				return err
+			}
+		}
+
+		updated, err = tx.UpdateAppointment(a)
+		return err
+	})
+	return
+}
+
+// sameTimes reports whether a and b have the same start, end, recurrence and
+// exceptions.
+func sameTimes(a, b Appointment) bool {
+	if !a.StartTime.Equal(b.StartTime.Time) || !a.EndTime.Equal(b.EndTime.Time) {
 		return false
 	}
-
-	for _, scheduledAppt := range s.Appointments {
-		outsideRange := a.StartTime > scheduledAppt.EndTime || a.EndTime < scheduledAppt.StartTime
-		if !outsideRange {
+	if (a.Recurrence == nil) != (b.Recurrence == nil) ||
+		a.Recurrence != nil && a.Recurrence.String() != b.Recurrence.String() {
+		return false
+	}
+	if len(a.Exceptions) != len(b.Exceptions) {
+		return false
+	}
+	for i := range a.Exceptions {
+		if !a.Exceptions[i].Equal(b.Exceptions[i].Time) {
 			return false
 		}
 	}
@@ -51,17 +261,176 @@ func ValidateAppointmentInput(s Schedule, a Appointment) bool {
 	return true
 }
 
-func sortAppointments(s Schedule) (appointments []Appointment) {
-	if len(s.Appointments) == 0 {
-		appointments = []Appointment{}
+func deleteAppointment(store Store, scheduleID, appointmentID int, ifMatch string) (deleted Appointment, err error) {
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		a, err := tx.GetAppointment(scheduleID, appointmentID)
+		if err != nil {
+			return err
+		}
+		if err = checkIfMatch(ifMatch, appointmentTag(a)); err != nil {
+			return err
+		}
+
+		deleted, err = tx.DeleteAppointment(scheduleID, appointmentID)
+		return err
+	})
+	return
+}
+
+// appointmentIn normalizes every time on a into loc.
+func appointmentIn(a Appointment, loc *time.Location) Appointment {
+	a.StartTime = a.StartTime.In(loc)
+	a.EndTime = a.EndTime.In(loc)
+
+	exceptions := make([]Timestamp, len(a.Exceptions))
+	for i, ts := range a.Exceptions {
+		exceptions[i] = ts.In(loc)
+	}
+	if len(exceptions) > 0 {
+		a.Exceptions = exceptions
+	}
+
+	if a.RecurrenceID != nil {
+		recurrenceID := a.RecurrenceID.In(loc)
+		a.RecurrenceID = &recurrenceID
+	}
+
+	return a
+}
+
+// ValidateAppointmentInput checks a proposed appointment against the rest of
+// schedule s. It returns a ValidationError listing every rule it breaks, or
+// nil.
+func ValidateAppointmentInput(s Schedule, a Appointment) error {
+	var violations []Violation
+	if a.StartTime.IsZero() {
+		violations = append(violations, Violation{
+			Rule: RuleStartRequired, Field: "start_time", Message: "start_time is required",
+		})
+	}
+	if !a.StartTime.Before(a.EndTime.Time) {
+		violations = append(violations, Violation{
+			Rule: RuleEndAfterStart, Field: "end_time", Message: "end_time must be after start_time",
+		})
 	}
-	for _, appt := range s.Appointments {
-		appointments = append(appointments, appt)
+	if a.StartTime.Legacy != a.EndTime.Legacy {
+		violations = append(violations, Violation{
+			Rule: RuleSameTimeFormat, Field: "end_time", Message: "start_time and end_time must use the same format",
+		})
+	}
+	if a.Recurrence != nil && !a.Recurrence.Until.IsZero() && a.Recurrence.Until.Before(a.StartTime.Time) {
+		violations = append(violations, Violation{
+			Rule: RuleUntilAfterStart, Field: "recurrence", Message: "UNTIL must not be before start_time",
+		})
+	}
+	if len(violations) > 0 {
+		return ValidationError{Violations: violations}
 	}
 
-	sort.SliceStable(appointments, func(i, j int) bool {
-		return appointments[i].StartTime < appointments[j].StartTime
-	})
+	p := s.OverlapPolicy
+	from, to := checkWindow(a)
 
-	return
+	proposed := a.Occurrences(from, to)
+	for i := 1; i < len(proposed); i++ {
+		if p.conflicts(p.blocked(proposed[i-1].interval()), p.blocked(proposed[i].interval())) {
+			violations = append(violations, Violation{
+				Rule: RuleNoSelfOverlap, Field: "recurrence", Message: "Occurrences of the series overlap one another",
+			})
+			break
+		}
+	}
+
+	if s.WorkingHours != nil {
+		working := s.workingTime(from, to)
+		for _, o := range proposed {
+			if !within(working, o.interval()) {
+				violations = append(violations, Violation{
+					Rule: RuleWithinWorkingHours, Field: "start_time", Message: outsideWorkingHours(a, o),
+				})
+				break
+			}
+		}
+	}
+
+	if s.BookingPolicy != (BookingPolicy{}) {
+		violations = append(violations, s.BookingPolicy.violations(a.interval(), s.Location(), time.Now())...)
+	}
+
+	if ids := blackoutsOverlapping(s, proposed, from, to); len(ids) > 0 {
+		violations = append(violations, Violation{
+			Rule: RuleNoBlackout, Field: "start_time", Message: "Falls within a blackout period", BlackoutIDs: ids,
+		})
+	}
+
+	if a.busy() {
+		// Bookings whose buffers reach the window can conflict too.
+		existing := busyAppointments(s, from.Add(-p.reach()), to.Add(p.reach()))
+		if conflicts := conflictingAppointments(p, proposed, existing); len(conflicts) > 0 {
+			violations = append(violations, conflictViolation(conflicts))
+		}
+		if ids := holdsOverlapping(s, proposed, time.Now()); len(ids) > 0 {
+			violations = append(violations, Violation{
+				Rule: RuleNoHold, Field: "start_time", Message: "Overlaps a hold on the schedule", HoldIDs: ids,
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// checkWindow returns the time ValidateAppointmentInput checks a over. A
+// recurring appointment is checked over recurrenceHorizon from its first
+// occurrence. The window is widened by a nanosecond on each side so that
+// bookings merely touching it are expanded too.
+func checkWindow(a Appointment) (from, to time.Time) {
+	from, to = a.StartTime.Time, a.EndTime.Time
+	if a.Recurrence != nil {
+		to = from.Add(recurrenceHorizon)
+	}
+
+	return from.Add(-time.Nanosecond), to.Add(time.Nanosecond)
+}
+
+// scheduleFor loads as much of a schedule as ValidateAppointmentInput needs
+// to check the given appointments: those within reach of their windows under
+// the schedule's overlap policy. Without appointments it loads none.
+func scheduleFor(tx Store, scheduleID int, appointments ...Appointment) (Schedule, error) {
+	s, err := tx.GetScheduleBetween(scheduleID, time.Time{}, time.Time{})
+	if err != nil || len(appointments) == 0 {
+		return s, err
+	}
+
+	var from, to time.Time
+	for i, a := range appointments {
+		f, t := checkWindow(a)
+		if i == 0 || f.Before(from) {
+			from = f
+		}
+		if i == 0 || t.After(to) {
+			to = t
+		}
+	}
+	reach := s.OverlapPolicy.reach()
+
+	return tx.GetScheduleBetween(scheduleID, from.Add(-reach), to.Add(reach))
+}
+
+// conflictingAppointments returns the members of existing that conflict with
+// any of proposed under policy p.
+func conflictingAppointments(p OverlapPolicy, proposed, existing []Appointment) []Appointment {
+	var conflicts []Appointment
+	for _, e := range existing {
+		blocked := p.blocked(e.interval())
+		for _, a := range proposed {
+			if p.conflicts(p.blocked(a.interval()), blocked) {
+				conflicts = append(conflicts, e)
+				break
+			}
+		}
+	}
+
+	return conflicts
 }
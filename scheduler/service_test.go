@@ -1,6 +1,8 @@
 package scheduler_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -9,58 +11,58 @@ import (
 
 var _ = Describe("Service", func() {
 	Context("#ValidateAppointmentInput", func() {
-		It("Should return true if the end time is greater than the start time and there are no existing appts", func() {
+		It("Should accept the appointment if the end time is greater than the start time and there are no existing appts", func() {
 			a := Appointment{
-				StartTime: 9,
-				EndTime:   10,
+				StartTime: UnixTimestamp(9),
+				EndTime:   UnixTimestamp(10),
 			}
 
-			valid := ValidateAppointmentInput(Schedule{}, a)
+			err := ValidateAppointmentInput(Schedule{}, a)
 
-			Expect(valid).To(BeTrue())
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("Should return false if the end time is less than or equal to the start time", func() {
+		It("Should reject the appointment if the end time is less than or equal to the start time", func() {
 			a := Appointment{
-				StartTime: 9,
-				EndTime:   5,
+				StartTime: UnixTimestamp(9),
+				EndTime:   UnixTimestamp(5),
 			}
 
-			valid := ValidateAppointmentInput(Schedule{}, a)
+			err := ValidateAppointmentInput(Schedule{}, a)
 
-			Expect(valid).To(BeFalse())
+			Expect(err).To(HaveOccurred())
 
 			a = Appointment{
-				StartTime: 5,
-				EndTime:   5,
+				StartTime: UnixTimestamp(5),
+				EndTime:   UnixTimestamp(5),
 			}
 
-			valid = ValidateAppointmentInput(Schedule{}, a)
+			err = ValidateAppointmentInput(Schedule{}, a)
 
-			Expect(valid).To(BeFalse())
+			Expect(err).To(HaveOccurred())
 		})
 
-		It("Should return false if the start time is 0", func() {
+		It("Should reject the appointment if the start time is 0", func() {
 			a := Appointment{
-				StartTime: 0,
-				EndTime:   10,
+				StartTime: UnixTimestamp(0),
+				EndTime:   UnixTimestamp(10),
 			}
 
-			valid := ValidateAppointmentInput(Schedule{}, a)
+			err := ValidateAppointmentInput(Schedule{}, a)
 
-			Expect(valid).To(BeFalse())
+			Expect(err).To(HaveOccurred())
 		})
 
-		Context("Should return true if there are no overlaps with existing appointments", func() {
+		Context("Should accept the appointment if there are no overlaps with existing appointments", func() {
 			It("Beginning of schedule", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 					2: Appointment{
-						StartTime: 11,
-						EndTime:   13,
+						StartTime: UnixTimestamp(11),
+						EndTime:   UnixTimestamp(13),
 					},
 				}
 
@@ -69,24 +71,24 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 1,
-					EndTime:   3,
+					StartTime: UnixTimestamp(1),
+					EndTime:   UnixTimestamp(3),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a)
 
-				Expect(valid).To(BeTrue())
+				Expect(err).NotTo(HaveOccurred())
 			})
 
 			It("End of schedule", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 					2: Appointment{
-						StartTime: 11,
-						EndTime:   13,
+						StartTime: UnixTimestamp(11),
+						EndTime:   UnixTimestamp(13),
 					},
 				}
 
@@ -95,24 +97,24 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 14,
-					EndTime:   18,
+					StartTime: UnixTimestamp(14),
+					EndTime:   UnixTimestamp(18),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a)
 
-				Expect(valid).To(BeTrue())
+				Expect(err).NotTo(HaveOccurred())
 			})
 
 			It("Sandwiched bewteen two appointments", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 					2: Appointment{
-						StartTime: 11,
-						EndTime:   13,
+						StartTime: UnixTimestamp(11),
+						EndTime:   UnixTimestamp(13),
 					},
 				}
 
@@ -121,22 +123,22 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 9,
-					EndTime:   10,
+					StartTime: UnixTimestamp(9),
+					EndTime:   UnixTimestamp(10),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a)
 
-				Expect(valid).To(BeTrue())
+				Expect(err).NotTo(HaveOccurred())
 			})
 		})
 
-		Context("Should return false if there is any overlap with existing appointments on the given schedule", func() {
+		Context("Should reject the appointment if there is any overlap with existing appointments on the given schedule", func() {
 			It("EndTime equal to existing StartTime", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 				}
 				s := Schedule{
@@ -144,20 +146,20 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 1,
-					EndTime:   4,
+					StartTime: UnixTimestamp(1),
+					EndTime:   UnixTimestamp(4),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a)
 
-				Expect(valid).To(BeFalse())
+				Expect(err).To(HaveOccurred())
 			})
 
 			It("EndTime in range of existing appointment", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 				}
 				s := Schedule{
@@ -165,20 +167,20 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 1,
-					EndTime:   5,
+					StartTime: UnixTimestamp(1),
+					EndTime:   UnixTimestamp(5),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a)
 
-				Expect(valid).To(BeFalse())
+				Expect(err).To(HaveOccurred())
 			})
 
 			It("StartTime equal to existing EndTime", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 				}
 				s := Schedule{
@@ -186,20 +188,20 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 8,
-					EndTime:   10,
+					StartTime: UnixTimestamp(8),
+					EndTime:   UnixTimestamp(10),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a)
 
-				Expect(valid).To(BeFalse())
+				Expect(err).To(HaveOccurred())
 			})
 
 			It("StartTime in range of existing appointment", func() {
 				scheduledAppointments := map[int]Appointment{
 					1: Appointment{
-						StartTime: 4,
-						EndTime:   8,
+						StartTime: UnixTimestamp(4),
+						EndTime:   UnixTimestamp(8),
 					},
 				}
 				s := Schedule{
@@ -207,13 +209,418 @@ var _ = Describe("Service", func() {
 				}
 
 				a := Appointment{
-					StartTime: 6,
-					EndTime:   10,
+					StartTime: UnixTimestamp(6),
+					EndTime:   UnixTimestamp(10),
+				}
+
+				err := ValidateAppointmentInput(s, a)
+
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("With RFC 3339 timestamps", func() {
+			var s Schedule
+
+			BeforeEach(func() {
+				s = Schedule{
+					Appointments: map[int]Appointment{
+						1: Appointment{
+							StartTime: NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+							EndTime:   NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+						},
+					},
+				}
+			})
+
+			It("Should compare instants across time zones", func() {
+				denver, _ := time.LoadLocation("America/Denver")
+
+				// 09:30 UTC expressed in Denver time overlaps the existing booking.
+				a := Appointment{
+					StartTime: NewTimestamp(time.Date(2019, 6, 3, 3, 30, 0, 0, denver)),
+					EndTime:   NewTimestamp(time.Date(2019, 6, 3, 4, 30, 0, 0, denver)),
+				}
+				Expect(ValidateAppointmentInput(s, a)).To(HaveOccurred())
+
+				a = Appointment{
+					StartTime: NewTimestamp(time.Date(2019, 6, 3, 5, 0, 0, 0, denver)),
+					EndTime:   NewTimestamp(time.Date(2019, 6, 3, 6, 0, 0, 0, denver)),
+				}
+				Expect(ValidateAppointmentInput(s, a)).NotTo(HaveOccurred())
+			})
+
+			It("Should reject the appointment when start and end mix RFC 3339 and integer formats", func() {
+				a := Appointment{
+					StartTime: NewTimestamp(time.Date(2019, 6, 3, 12, 0, 0, 0, time.UTC)),
+					EndTime:   UnixTimestamp(time.Date(2019, 6, 3, 13, 0, 0, 0, time.UTC).Unix()),
+				}
+
+				Expect(ValidateAppointmentInput(s, a)).To(HaveOccurred())
+			})
+		})
+
+		Context("With recurring appointments", func() {
+			var denver *time.Location
+
+			BeforeEach(func() {
+				denver, _ = time.LoadLocation("America/Denver")
+			})
+
+			weekly := func(start time.Time, rule string) Appointment {
+				r, _ := ParseRecurrenceRule(rule)
+				return Appointment{
+					StartTime:  NewTimestamp(start),
+					EndTime:    NewTimestamp(start.Add(time.Hour)),
+					Recurrence: r,
+				}
+			}
+
+			It("Should reject the appointment if a later occurrence of a new series overlaps an existing appointment", func() {
+				conflictStart := time.Date(2019, 8, 5, 9, 30, 0, 0, denver)
+				s := Schedule{
+					Appointments: map[int]Appointment{
+						1: Appointment{
+							StartTime: NewTimestamp(conflictStart),
+							EndTime:   NewTimestamp(conflictStart.Add(time.Hour)),
+						},
+					},
+				}
+
+				a := weekly(time.Date(2019, 6, 3, 9, 0, 0, 0, denver), "FREQ=WEEKLY;BYDAY=MO")
+
+				Expect(ValidateAppointmentInput(s, a)).To(HaveOccurred())
+			})
+
+			It("Should reject the appointment if a new appointment lands on an occurrence of an existing series", func() {
+				s := Schedule{
+					Appointments: map[int]Appointment{
+						1: weekly(time.Date(2019, 6, 3, 9, 0, 0, 0, denver), "FREQ=WEEKLY;BYDAY=MO"),
+					},
+				}
+
+				start := time.Date(2019, 9, 2, 9, 15, 0, 0, denver)
+				a := Appointment{
+					StartTime: NewTimestamp(start),
+					EndTime:   NewTimestamp(start.Add(time.Hour)),
+				}
+
+				Expect(ValidateAppointmentInput(s, a)).To(HaveOccurred())
+			})
+
+			It("Should accept the appointment once an existing series has ended", func() {
+				s := Schedule{
+					Appointments: map[int]Appointment{
+						1: weekly(time.Date(2019, 6, 3, 9, 0, 0, 0, denver), "FREQ=WEEKLY;BYDAY=MO;COUNT=4"),
+					},
+				}
+
+				start := time.Date(2019, 9, 2, 9, 15, 0, 0, denver)
+				a := Appointment{
+					StartTime: NewTimestamp(start),
+					EndTime:   NewTimestamp(start.Add(time.Hour)),
+				}
+
+				Expect(ValidateAppointmentInput(s, a)).NotTo(HaveOccurred())
+			})
+
+			It("Should only check occurrences within the recurrence horizon", func() {
+				farStart := time.Date(2021, 6, 7, 9, 0, 0, 0, denver)
+				s := Schedule{
+					Appointments: map[int]Appointment{
+						1: Appointment{
+							StartTime: NewTimestamp(farStart),
+							EndTime:   NewTimestamp(farStart.Add(time.Hour)),
+						},
+					},
+				}
+
+				a := weekly(time.Date(2019, 6, 3, 9, 0, 0, 0, denver), "FREQ=WEEKLY;BYDAY=MO")
+
+				Expect(ValidateAppointmentInput(s, a)).NotTo(HaveOccurred())
+			})
+
+			It("Should reject the appointment for a series whose occurrences overlap each other", func() {
+				start := time.Date(2019, 6, 3, 9, 0, 0, 0, denver)
+				r, _ := ParseRecurrenceRule("FREQ=DAILY;COUNT=3")
+				a := Appointment{
+					StartTime:  NewTimestamp(start),
+					EndTime:    NewTimestamp(start.Add(25 * time.Hour)),
+					Recurrence: r,
+				}
+
+				Expect(ValidateAppointmentInput(Schedule{}, a)).To(HaveOccurred())
+			})
+		})
+
+		Context("With working hours", func() {
+			var s Schedule
+
+			nineToFive := []TimeRange{{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 17}}}
+
+			// 2019-06-03 is a Monday.
+			at := func(day, hour, hours int) Appointment {
+				start := time.Date(2019, 6, day, hour, 0, 0, 0, s.Location())
+				return Appointment{
+					StartTime: NewTimestamp(start),
+					EndTime:   NewTimestamp(start.Add(time.Duration(hours) * time.Hour)),
+				}
+			}
+
+			outside := func(a Appointment) []Violation {
+				err := ValidateAppointmentInput(s, a)
+				if err == nil {
+					return nil
+				}
+				return err.(ValidationError).Violations
+			}
+
+			BeforeEach(func() {
+				s = Schedule{TimeZone: "America/Denver", WorkingHours: &WorkingHours{
+					Weekly: map[string][]TimeRange{"monday": nineToFive, "tuesday": nineToFive, "friday": nineToFive},
+				}}
+			})
+
+			It("Should only accept appointments entirely within working hours", func() {
+				Expect(outside(at(3, 9, 8))).To(BeEmpty())
+				Expect(outside(at(3, 8, 2))).To(HaveLen(1))
+				Expect(outside(at(3, 16, 2))).To(HaveLen(1))
+				Expect(outside(at(5, 10, 1))).To(Equal([]Violation{{
+					Rule: RuleWithinWorkingHours, Field: "start_time", Message: "Appointment is outside working hours",
+				}}))
+			})
+
+			It("Should apply date overrides instead of the weekly hours", func() {
+				s.WorkingHours.Overrides = []DateOverride{
+					{Date: "2019-06-03", Ranges: []TimeRange{}},
+					{Date: "2019-06-08", Ranges: []TimeRange{{Start: TimeOfDay{Hour: 10}, End: TimeOfDay{Hour: 12}}}},
+				}
+
+				Expect(outside(at(3, 10, 1))).To(HaveLen(1))
+				Expect(outside(at(4, 10, 1))).To(BeEmpty())
+				Expect(outside(at(8, 10, 2))).To(BeEmpty())
+				Expect(outside(at(8, 11, 2))).To(HaveLen(1))
+			})
+
+			It("Should join ranges meeting at midnight", func() {
+				s.WorkingHours.Weekly["monday"] = []TimeRange{{Start: TimeOfDay{Hour: 20}, End: TimeOfDay{Hour: 24}}}
+				s.WorkingHours.Weekly["tuesday"] = []TimeRange{{Start: TimeOfDay{}, End: TimeOfDay{Hour: 4}}}
+
+				Expect(outside(at(3, 22, 4))).To(BeEmpty())
+				Expect(outside(at(3, 22, 7))).To(HaveLen(1))
+			})
+
+			It("Should read the hours in their own time zone", func() {
+				s.WorkingHours.TimeZone = "UTC"
+
+				Expect(outside(at(3, 3, 8))).To(BeEmpty())
+				Expect(outside(at(3, 9, 8))).To(HaveLen(1))
+			})
+
+			It("Should report the first occurrence of a series outside working hours", func() {
+				a := at(3, 9, 1)
+				a.Recurrence, _ = ParseRecurrenceRule("FREQ=DAILY;COUNT=3")
+
+				Expect(outside(a)).To(Equal([]Violation{{
+					Rule:    RuleWithinWorkingHours,
+					Field:   "start_time",
+					Message: "Occurrence starting 2019-06-05T09:00:00-06:00 is outside working hours",
+				}}))
+
+				a.Recurrence, _ = ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=MO,TU,FR")
+				Expect(outside(a)).To(BeEmpty())
+			})
+		})
+
+		Context("With blackouts", func() {
+			var s Schedule
+
+			at := func(day, hour, hours int) Appointment {
+				start := time.Date(2019, 6, day, hour, 0, 0, 0, time.UTC)
+				return Appointment{
+					StartTime: NewTimestamp(start),
+					EndTime:   NewTimestamp(start.Add(time.Duration(hours) * time.Hour)),
+				}
+			}
+
+			BeforeEach(func() {
+				vacation := at(10, 0, 24*5)
+				lunch := at(3, 12, 1)
+				lunch.Recurrence, _ = ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=MO")
+				s = Schedule{TimeZone: "UTC", Blackouts: []Blackout{
+					{ID: 1, StartTime: lunch.StartTime, EndTime: lunch.EndTime, Recurrence: lunch.Recurrence},
+					{ID: 2, StartTime: vacation.StartTime, EndTime: vacation.EndTime, Reason: "Vacation"},
+				}}
+			})
+
+			It("Should reject appointments overlapping a blackout and name it", func() {
+				err := ValidateAppointmentInput(s, at(12, 9, 1))
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.(ValidationError).Violations).To(Equal([]Violation{{
+					Rule: RuleNoBlackout, Field: "start_time", Message: "Falls within a blackout period", BlackoutIDs: []int{2},
+				}}))
+			})
+
+			It("Should reject any occurrence of a recurring blackout but allow touching one", func() {
+				Expect(ValidateAppointmentInput(s, at(24, 11, 2))).To(HaveOccurred())
+				Expect(ValidateAppointmentInput(s, at(24, 11, 1))).NotTo(HaveOccurred())
+				Expect(ValidateAppointmentInput(s, at(24, 13, 1))).NotTo(HaveOccurred())
+				Expect(ValidateAppointmentInput(s, at(25, 12, 1))).NotTo(HaveOccurred())
+			})
+
+			It("Should check every occurrence of a proposed series", func() {
+				a := at(5, 9, 1)
+				a.Recurrence, _ = ParseRecurrenceRule("FREQ=DAILY;COUNT=10")
+
+				err := ValidateAppointmentInput(s, a)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(ValidationError).Violations[0].BlackoutIDs).To(Equal([]int{2}))
+			})
+		})
+
+		Context("With a booking policy", func() {
+			var s Schedule
+
+			rules := func(start time.Time, minutes int) []string {
+				err := ValidateAppointmentInput(s, Appointment{
+					StartTime: NewTimestamp(start),
+					EndTime:   NewTimestamp(start.Add(time.Duration(minutes) * time.Minute)),
+				})
+				if err == nil {
+					return nil
+				}
+
+				var broken []string
+				for _, v := range err.(ValidationError).Violations {
+					broken = append(broken, v.Rule)
+				}
+				return broken
+			}
+
+			BeforeEach(func() {
+				s = Schedule{TimeZone: "Asia/Kolkata", BookingPolicy: BookingPolicy{
+					MinDurationMinutes: 15, MaxDurationMinutes: 120, GranularityMinutes: 15,
+				}}
+			})
+
+			It("Should limit the duration of appointments", func() {
+				start := time.Date(2019, 6, 3, 9, 0, 0, 0, s.Location())
+
+				Expect(rules(start, 15)).To(BeEmpty())
+				Expect(rules(start, 120)).To(BeEmpty())
+				Expect(rules(start, 0)).To(ContainElement(RuleEndAfterStart))
+				Expect(rules(start, 135)).To(Equal([]string{RuleMaxDuration}))
+
+				s.BookingPolicy.GranularityMinutes = 0
+				Expect(rules(start, 10)).To(Equal([]string{RuleMinDuration}))
+			})
+
+			It("Should align starts and ends in the schedule's time zone", func() {
+				// Kolkata is 5:30 ahead of UTC.
+				Expect(rules(time.Date(2019, 6, 3, 3, 30, 0, 0, time.UTC), 45)).To(BeEmpty())
+				Expect(rules(time.Date(2019, 6, 3, 3, 0, 0, 0, time.UTC), 45)).To(BeEmpty())
+				Expect(rules(time.Date(2019, 6, 3, 3, 40, 0, 0, time.UTC), 45)).To(Equal([]string{RuleGranularity}))
+				Expect(rules(time.Date(2019, 6, 3, 3, 30, 0, 0, time.UTC), 50)).To(Equal([]string{RuleGranularity}))
+			})
+
+			It("Should keep starts between the minimum lead time and the advance horizon", func() {
+				s.BookingPolicy = BookingPolicy{MinLeadTimeMinutes: 120, MaxAdvanceDays: 30}
+				now := time.Now()
+
+				Expect(rules(now.Add(3*time.Hour), 30)).To(BeEmpty())
+				Expect(rules(now.Add(29*24*time.Hour), 30)).To(BeEmpty())
+				Expect(rules(now.Add(time.Hour), 30)).To(Equal([]string{RuleMinLeadTime}))
+				Expect(rules(now.Add(-time.Hour), 30)).To(Equal([]string{RuleMinLeadTime}))
+				Expect(rules(now.Add(31*24*time.Hour), 30)).To(Equal([]string{RuleMaxAdvance}))
+			})
+		})
+
+		Context("With an overlap policy", func() {
+			var (
+				s     Schedule
+				start time.Time
+			)
+
+			BeforeEach(func() {
+				start = time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+				s = Schedule{Appointments: map[int]Appointment{
+					1: {ID: 1, StartTime: NewTimestamp(start), EndTime: NewTimestamp(start.Add(time.Hour))},
+				}}
+			})
+
+			backToBack := Appointment{
+				StartTime: NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+				EndTime:   NewTimestamp(time.Date(2019, 6, 3, 11, 0, 0, 0, time.UTC)),
+			}
+
+			It("Should accept back-to-back appointments with half-open intervals", func() {
+				s.OverlapPolicy = OverlapPolicy{Intervals: HalfOpenIntervals}
+
+				Expect(ValidateAppointmentInput(s, backToBack)).NotTo(HaveOccurred())
+
+				backToBack.StartTime = NewTimestamp(start.Add(59 * time.Minute))
+				Expect(ValidateAppointmentInput(s, backToBack)).To(HaveOccurred())
+			})
+
+			It("Should reject appointments within the buffers of existing ones", func() {
+				s.OverlapPolicy = OverlapPolicy{Intervals: HalfOpenIntervals, BufferBeforeMinutes: 10, BufferAfterMinutes: 5}
+				later := backToBack
+				later.StartTime = NewTimestamp(start.Add(74 * time.Minute))
+				Expect(ValidateAppointmentInput(s, later)).To(HaveOccurred())
+
+				later.StartTime = NewTimestamp(start.Add(75 * time.Minute))
+				Expect(ValidateAppointmentInput(s, later)).NotTo(HaveOccurred())
+
+				earlier := Appointment{
+					StartTime: NewTimestamp(start.Add(-2 * time.Hour)),
+					EndTime:   NewTimestamp(start.Add(-14 * time.Minute)),
+				}
+				Expect(ValidateAppointmentInput(s, earlier)).To(HaveOccurred())
+
+				earlier.EndTime = NewTimestamp(start.Add(-15 * time.Minute))
+				Expect(ValidateAppointmentInput(s, earlier)).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("Violations", func() {
+			It("Should list each broken rule with its field", func() {
+				a := Appointment{
+					StartTime: UnixTimestamp(0),
+					EndTime:   NewTimestamp(time.Time{}),
+				}
+
+				err := ValidateAppointmentInput(Schedule{}, a)
+
+				Expect(err).To(BeAssignableToTypeOf(ValidationError{}))
+				var rules, fields []string
+				for _, v := range err.(ValidationError).Violations {
+					rules, fields = append(rules, v.Rule), append(fields, v.Field)
+				}
+				Expect(rules).To(Equal([]string{RuleStartRequired, RuleEndAfterStart, RuleSameTimeFormat}))
+				Expect(fields).To(Equal([]string{"start_time", "end_time", "end_time"}))
+			})
+
+			It("Should identify the conflicting occurrences of a series", func() {
+				start := time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+				r, _ := ParseRecurrenceRule("FREQ=DAILY;COUNT=5")
+				s := Schedule{Appointments: map[int]Appointment{
+					7: {ID: 7, StartTime: NewTimestamp(start), EndTime: NewTimestamp(start.Add(time.Hour)), Recurrence: r},
+				}}
+				a := Appointment{
+					StartTime: NewTimestamp(start.AddDate(0, 0, 2).Add(30 * time.Minute)),
+					EndTime:   NewTimestamp(start.AddDate(0, 0, 2).Add(90 * time.Minute)),
 				}
 
-				valid := ValidateAppointmentInput(s, a)
+				err := ValidateAppointmentInput(s, a).(ValidationError)
 
-				Expect(valid).To(BeFalse())
+				Expect(err.Violations).To(HaveLen(1))
+				v := err.Violations[0]
+				Expect(v.Rule).To(Equal(RuleNoConflict))
+				Expect(v.Conflicts).To(HaveLen(1))
+				Expect(v.Conflicts[0].AppointmentID).To(Equal(7))
+				Expect(v.Conflicts[0].RecurrenceID.Equal(start.AddDate(0, 0, 2))).To(BeTrue())
+				Expect(v.Conflicts[0].EndTime.Equal(start.AddDate(0, 0, 2).Add(time.Hour))).To(BeTrue())
 			})
 		})
 	})
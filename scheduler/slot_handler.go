@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+type SlotsResponse struct {
+	Slots []Interval `json:"slots"`
+}
+
+// FindSlotsHandler searches a schedule for the earliest free slots. See
+// parseSlotQuery for the supported query parameters.
+func (h *Handler) FindSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	q, err := parseSlotQuery(r, 1)
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slots, err := findSlots(h.Store, scheduleID, q)
+	if err != nil {
+		log.Println("FindSlotsHandler - ", err.Error())
+		respondWithServiceError(w, err, "Unable to find slots")
+		return
+	}
+
+	http_helpers.RespondWithJSON(w, http.StatusOK, SlotsResponse{Slots: slots})
+}
+
+// parseSlotQuery reads the required duration (a Go duration such as "30m")
+// and from/to window, and the optional earliest_start and latest_end (HH:MM),
+// granularity (a duration of whole minutes) and count query parameters.
+func parseSlotQuery(r *http.Request, defaultCount int) (q SlotQuery, err error) {
+	params := r.URL.Query()
+
+	q.Duration, err = time.ParseDuration(params.Get("duration"))
+	if err != nil || q.Duration < time.Second || q.Duration%time.Second != 0 {
+		return q, errors.New("duration must be a positive number of whole seconds, such as 30m")
+	}
+
+	from, to, windowed, err := parseWindow(r)
+	if err != nil {
+		return q, err
+	}
+	if !windowed {
+		return q, errors.New("Both from and to are required")
+	}
+	q.From, q.To = from, to
+
+	if value := params.Get("earliest_start"); value != "" {
+		earliest, err := ParseTimeOfDay(value)
+		if err != nil {
+			return q, errors.New("Invalid earliest_start")
+		}
+		q.EarliestStart = &earliest
+	}
+	if value := params.Get("latest_end"); value != "" {
+		latest, err := ParseTimeOfDay(value)
+		if err != nil {
+			return q, errors.New("Invalid latest_end")
+		}
+		q.LatestEnd = &latest
+	}
+	if q.EarliestStart != nil && q.LatestEnd != nil && q.LatestEnd.minutes() <= q.EarliestStart.minutes() {
+		return q, errors.New("latest_end must be after earliest_start")
+	}
+
+	if value := params.Get("granularity"); value != "" {
+		q.Granularity, err = time.ParseDuration(value)
+		if err != nil || q.Granularity < time.Minute || q.Granularity > 24*time.Hour || q.Granularity%time.Minute != 0 {
+			return q, errors.New("granularity must be a whole number of minutes up to 24h")
+		}
+	}
+
+	q.Count = defaultCount
+	if value := params.Get("count"); value != "" {
+		q.Count, err = strconv.Atoi(value)
+		if err != nil || q.Count < 1 || q.Count > maxSlotCount {
+			return q, fmt.Errorf("count must be between 1 and %v", maxSlotCount)
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,217 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Slot Handler", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+		s     Schedule
+		day   time.Time
+	)
+
+	type slot struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+
+	search := func(params string) (*httptest.ResponseRecorder, []slot) {
+		recorder := serve(h.FindSlotsHandler, "GET", "/?"+params, "", "scheduleID", strconv.Itoa(s.ID))
+
+		var resBody struct {
+			Slots []slot `json:"slots"`
+		}
+		json.NewDecoder(recorder.Body).Decode(&resBody)
+		return recorder, resBody.Slots
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		day = time.Date(2019, 6, 3, 0, 0, 0, 0, s.Location())
+	})
+
+	It("Should return the earliest aligned slot after the window start", func() {
+		recorder, slots := search("duration=30m&from=2019-06-03T09:05:00-06:00&to=2019-06-04T00:00:00-06:00")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-03T09:15:00-06:00", End: "2019-06-03T09:45:00-06:00"},
+		}))
+	})
+
+	It("Should skip busy time, including slots that would touch an appointment", func() {
+		bookAppointment(store, s, day.Add(9*time.Hour), time.Hour)
+		bookAppointment(store, s, day.Add(10*time.Hour+45*time.Minute), time.Hour)
+
+		recorder, slots := search("duration=30m&from=2019-06-03T09:00:00-06:00&to=2019-06-04T00:00:00-06:00&count=3")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-03T12:00:00-06:00", End: "2019-06-03T12:30:00-06:00"},
+			{Start: "2019-06-03T12:15:00-06:00", End: "2019-06-03T12:45:00-06:00"},
+			{Start: "2019-06-03T12:30:00-06:00", End: "2019-06-03T13:00:00-06:00"},
+		}))
+	})
+
+	It("Should keep slots within the daily earliest start and latest end", func() {
+		bookAppointment(store, s, day.Add(9*time.Hour), 7*time.Hour)
+
+		recorder, slots := search("duration=1h&from=2019-06-03T00:00:00-06:00&to=2019-06-05T00:00:00-06:00&earliest_start=09:00&latest_end=17:00&granularity=30m&count=3")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-04T09:00:00-06:00", End: "2019-06-04T10:00:00-06:00"},
+			{Start: "2019-06-04T09:30:00-06:00", End: "2019-06-04T10:30:00-06:00"},
+			{Start: "2019-06-04T10:00:00-06:00", End: "2019-06-04T11:00:00-06:00"},
+		}))
+	})
+
+	It("Should skip the occurrences of recurring appointments", func() {
+		rule, _ := ParseRecurrenceRule("FREQ=DAILY")
+		store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(day.AddDate(0, 0, -7).Add(9 * time.Hour)),
+			EndTime:    NewTimestamp(day.AddDate(0, 0, -7).Add(12 * time.Hour)),
+			Recurrence: rule,
+		})
+
+		recorder, slots := search("duration=1h&from=2019-06-03T08:00:00-06:00&to=2019-06-04T00:00:00-06:00&granularity=1h")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-03T13:00:00-06:00", End: "2019-06-03T14:00:00-06:00"},
+		}))
+	})
+
+	It("Should return fewer slots when the window runs out", func() {
+		recorder, slots := search("duration=1h&from=2019-06-03T09:00:00-06:00&to=2019-06-03T11:00:00-06:00&granularity=1h&count=5")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(HaveLen(2))
+	})
+
+	It("Should offer back-to-back slots under half-open intervals and keep clear of buffers", func() {
+		bookAppointment(store, s, day.Add(9*time.Hour), time.Hour)
+
+		s.OverlapPolicy = OverlapPolicy{Intervals: HalfOpenIntervals}
+		store.UpdateSchedule(s)
+		_, slots := search("duration=30m&from=2019-06-03T09:00:00-06:00&to=2019-06-04T00:00:00-06:00")
+		Expect(slots).To(Equal([]slot{{Start: "2019-06-03T10:00:00-06:00", End: "2019-06-03T10:30:00-06:00"}}))
+
+		s.OverlapPolicy.BufferAfterMinutes = 10
+		s.OverlapPolicy.BufferBeforeMinutes = 5
+		store.UpdateSchedule(s)
+		_, slots = search("duration=30m&from=2019-06-03T09:00:00-06:00&to=2019-06-04T00:00:00-06:00")
+		Expect(slots).To(Equal([]slot{{Start: "2019-06-03T10:15:00-06:00", End: "2019-06-03T10:45:00-06:00"}}))
+	})
+
+	It("Should only offer slots within working hours", func() {
+		s.WorkingHours = &WorkingHours{Weekly: map[string][]TimeRange{
+			"tuesday": {{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 10}}, {Start: TimeOfDay{Hour: 13}, End: TimeOfDay{Hour: 17}}},
+		}}
+		store.UpdateSchedule(s)
+		bookAppointment(store, s, day.AddDate(0, 0, 1).Add(9*time.Hour+15*time.Minute), 30*time.Minute)
+
+		_, slots := search("duration=45m&count=2&granularity=30m&from=2019-06-03T00:00:00-06:00&to=2019-06-10T00:00:00-06:00")
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-04T13:00:00-06:00", End: "2019-06-04T13:45:00-06:00"},
+			{Start: "2019-06-04T13:30:00-06:00", End: "2019-06-04T14:15:00-06:00"},
+		}))
+	})
+
+	It("Should skip blackouts, including those of subscribed calendars", func() {
+		c, _ := store.CreateCalendar(Calendar{Name: "Westeros", TimeZone: "America/Denver"})
+		s.CalendarIDs = []int{c.ID}
+		store.UpdateSchedule(s)
+		store.CreateBlackout(Blackout{
+			CalendarID: c.ID, StartTime: NewTimestamp(day.Add(9 * time.Hour)), EndTime: NewTimestamp(day.Add(12 * time.Hour)),
+		})
+		store.CreateBlackout(Blackout{
+			ScheduleID: s.ID, StartTime: NewTimestamp(day.Add(12*time.Hour + 30*time.Minute)), EndTime: NewTimestamp(day.Add(13 * time.Hour)),
+		})
+
+		_, slots := search("duration=30m&count=3&granularity=30m&from=2019-06-03T09:00:00-06:00&to=2019-06-04T00:00:00-06:00")
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-03T12:00:00-06:00", End: "2019-06-03T12:30:00-06:00"},
+			{Start: "2019-06-03T13:00:00-06:00", End: "2019-06-03T13:30:00-06:00"},
+			{Start: "2019-06-03T13:30:00-06:00", End: "2019-06-03T14:00:00-06:00"},
+		}))
+	})
+
+	It("Should keep slots to the schedule's booking granularity and durations", func() {
+		s.BookingPolicy = BookingPolicy{GranularityMinutes: 20, MaxDurationMinutes: 60}
+		store.UpdateSchedule(s)
+
+		recorder, slots := search("duration=40m&count=2&from=2019-06-03T09:05:00-06:00&to=2019-06-04T00:00:00-06:00")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(slots).To(Equal([]slot{
+			{Start: "2019-06-03T09:20:00-06:00", End: "2019-06-03T10:00:00-06:00"},
+			{Start: "2019-06-03T09:40:00-06:00", End: "2019-06-03T10:20:00-06:00"},
+		}))
+
+		_, slots = search("duration=40m&granularity=30m&from=2019-06-03T09:05:00-06:00&to=2019-06-04T00:00:00-06:00")
+		Expect(slots).To(Equal([]slot{{Start: "2019-06-03T10:00:00-06:00", End: "2019-06-03T10:40:00-06:00"}}))
+
+		recorder, _ = search("duration=90m&from=2019-06-03T09:05:00-06:00&to=2019-06-04T00:00:00-06:00")
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("Should only offer slots between the minimum lead time and the advance horizon", func() {
+		s.BookingPolicy = BookingPolicy{MinLeadTimeMinutes: 24 * 60, MaxAdvanceDays: 2}
+		store.UpdateSchedule(s)
+		now := time.Now()
+
+		window := fmt.Sprintf("&from=%v&to=%v", now.UTC().Format(time.RFC3339), now.AddDate(0, 0, 10).UTC().Format(time.RFC3339))
+		_, slots := search("duration=1h&granularity=6h&count=50" + window)
+
+		Expect(slots).To(HaveLen(4))
+		for _, sl := range slots {
+			start, _ := time.Parse(time.RFC3339, sl.Start)
+			Expect(start.Before(now.Add(24 * time.Hour))).To(BeFalse())
+			Expect(start.After(now.Add(48 * time.Hour))).To(BeFalse())
+		}
+	})
+
+	It("Should return a StatusNotFound for a missing schedule", func() {
+		s.ID = 32
+
+		recorder, _ := search("duration=1h&from=2019-06-03T09:00:00-06:00&to=2019-06-03T11:00:00-06:00")
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should return a StatusBadRequest for invalid parameters", func() {
+		window := "&from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z"
+		for _, params := range []string{
+			"from=2019-06-03T00:00:00Z&to=2019-06-04T00:00:00Z",
+			"duration=blamo" + window,
+			"duration=-30m" + window,
+			"duration=30m",
+			"duration=30m&earliest_start=9am" + window,
+			"duration=30m&latest_end=25:00" + window,
+			"duration=30m&earliest_start=17:00&latest_end=09:00" + window,
+			"duration=30m&granularity=30s" + window,
+			"duration=30m&count=0" + window,
+			"duration=30m&count=51" + window,
+		} {
+			recorder, _ := search(params)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest), params)
+		}
+	})
+})
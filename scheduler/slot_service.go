@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const (
+	defaultSlotGranularity = 15 * time.Minute
+	maxSlotCount           = 50
+)
+
+// TimeOfDay is a wall-clock time in a schedule's time zone. 24:00 stands for
+// the end of the day.
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+func ParseTimeOfDay(value string) (TimeOfDay, error) {
+	var t TimeOfDay
+	if _, err := fmt.Sscanf(value, "%2d:%2d", &t.Hour, &t.Minute); err != nil || len(value) != 5 {
+		return t, fmt.Errorf("invalid time of day %q: expected HH:MM", value)
+	}
+	if t.Hour < 0 || t.Minute < 0 || t.Minute > 59 || t.Hour > 24 || (t.Hour == 24 && t.Minute > 0) {
+		return t, fmt.Errorf("invalid time of day %q: expected HH:MM", value)
+	}
+
+	return t, nil
+}
+
+// On returns the time of day on the calendar day of t, in t's location.
+func (t TimeOfDay) On(day time.Time) time.Time {
+	year, month, date := day.Date()
+	return time.Date(year, month, date, t.Hour, t.Minute, 0, 0, day.Location())
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := ParseTimeOfDay(value)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t TimeOfDay) minutes() int {
+	return t.Hour*60 + t.Minute
+}
+
+// SlotQuery describes a search for free time on a schedule.
+type SlotQuery struct {
+	Duration time.Duration
+	From     time.Time
+	To       time.Time
+
+	// EarliestStart and LatestEnd, when set, bound slots to part of every
+	// day in the schedule's time zone.
+	EarliestStart *TimeOfDay
+	LatestEnd     *TimeOfDay
+
+	// Granularity aligns slot starts to multiples of it counted from
+	// midnight in the schedule's time zone. It defaults to the granularity
+	// of the schedule's booking policy, or else 15 minutes.
+	Granularity time.Duration
+	Count       int
+}
+
+// findSlots returns up to q.Count of the earliest slots within the query
+// window that an appointment could be booked in. Slots are alternatives and
+// may overlap each other; consecutive slots start at least q.Granularity
+// apart. Slots keep to the schedule's booking policy, and a duration the
+// policy does not allow is rejected.
+func findSlots(store Store, scheduleID int, q SlotQuery) ([]Interval, error) {
+	s, err := store.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	loc := s.Location()
+
+	if v := s.BookingPolicy.durationViolation(q.Duration); v != nil {
+		return nil, http_helpers.HttpError{
+			Message:    v.Message,
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+	q.Granularity = s.BookingPolicy.slotGranularity(q.Granularity)
+	q.From, q.To = s.BookingPolicy.window(q.From, q.To, q.Duration, time.Now())
+
+	align := func(t time.Time) time.Time {
+		return alignTime(t, loc, q.Granularity)
+	}
+
+	// Bookings just outside the window can still conflict with slots at its
+	// edges, by touching them or through buffers.
+	p := s.OverlapPolicy
+	margin := p.reach() + time.Second
+	busy := scheduleBusy(s, q.From.Add(-margin), q.To.Add(margin))
+	available := s.available(q.From, q.To)
+	slots := []Interval{}
+
+	next, nextAvailable := 0, 0
+	start := align(q.From)
+	for len(slots) < q.Count {
+		end := start.Add(q.Duration)
+		if end.After(q.To) {
+			break
+		}
+
+		if q.EarliestStart != nil && start.Before(q.EarliestStart.On(start)) {
+			start = align(q.EarliestStart.On(start))
+			continue
+		}
+		if q.LatestEnd != nil && end.After(q.LatestEnd.On(start)) {
+			tomorrow := TimeOfDay{}.On(start).AddDate(0, 0, 1)
+			if q.EarliestStart != nil {
+				tomorrow = q.EarliestStart.On(tomorrow)
+			}
+			start = align(tomorrow)
+			continue
+		}
+
+		// Skip ahead to the working hours the slot could fit in.
+		for nextAvailable < len(available) && available[nextAvailable].End.Before(end) {
+			nextAvailable++
+		}
+		if nextAvailable == len(available) {
+			break
+		}
+		if start.Before(available[nextAvailable].Start.Time) {
+			start = align(available[nextAvailable].Start.Time)
+			continue
+		}
+
+		blocked := p.blocked(Interval{Start: NewTimestamp(start), End: NewTimestamp(end)})
+		for next < len(busy) && !blocked.Start.Before(p.freeFrom(busy[next])) {
+			next++
+		}
+		if next < len(busy) && p.conflicts(busy[next], blocked) {
+			start = align(p.freeFrom(busy[next]).Add(p.bufferBefore()))
+			continue
+		}
+
+		slot := Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(end.In(loc)),
+		}
+		if ValidateAppointmentInput(s, slot) == nil {
+			slots = append(slots, slot.interval())
+		}
+		start = align(start.Add(q.Granularity))
+	}
+
+	return slots, nil
+}
+
+// alignTime rounds t up to the next multiple of granularity counted from
+// midnight in loc.
+func alignTime(t time.Time, loc *time.Location, granularity time.Duration) time.Time {
+	t = t.In(loc)
+	midnight := TimeOfDay{}.On(t)
+	steps := (t.Sub(midnight) + granularity - 1) / granularity
+	return midnight.Add(steps * granularity)
+}
@@ -0,0 +1,1151 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order on startup and PRAGMA user_version
+// records how many have run. Append new steps; never edit released ones.
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS schedules (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_name TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS appointments (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule_id INTEGER NOT NULL REFERENCES schedules(id),
+		start_time  INTEGER NOT NULL,
+		end_time    INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS appointments_schedule_start
+		ON appointments (schedule_id, start_time);`,
+
+	// Times are stored as Unix seconds. Rows written before this step came
+	// from integer clients, hence legacy_time defaulting to true.
+	`ALTER TABLE schedules ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'UTC';
+	ALTER TABLE appointments ADD COLUMN legacy_time INTEGER NOT NULL DEFAULT 1;`,
+
+	`ALTER TABLE appointments ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';`,
+
+	// exceptions holds comma separated Unix seconds.
+	`ALTER TABLE appointments ADD COLUMN exceptions TEXT NOT NULL DEFAULT '';
+	ALTER TABLE appointments ADD COLUMN series_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE appointments ADD COLUMN recurrence_id INTEGER;`,
+
+	`CREATE TABLE meetings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT
+	);
+	ALTER TABLE appointments ADD COLUMN meeting_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX appointments_meeting ON appointments (meeting_id);`,
+
+	`ALTER TABLE schedules ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE appointments ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+
+	`ALTER TABLE schedules ADD COLUMN overlap_intervals TEXT NOT NULL DEFAULT 'closed';
+	ALTER TABLE schedules ADD COLUMN buffer_before_minutes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE schedules ADD COLUMN buffer_after_minutes INTEGER NOT NULL DEFAULT 0;`,
+
+	// working_hours holds the JSON representation, or '' for none.
+	`ALTER TABLE schedules ADD COLUMN working_hours TEXT NOT NULL DEFAULT '';`,
+
+	// A blackout belongs to either a schedule or a calendar; the other ID is 0.
+	`CREATE TABLE calendars (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		name      TEXT NOT NULL,
+		time_zone TEXT NOT NULL DEFAULT 'UTC'
+	);
+
+	CREATE TABLE blackouts (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule_id INTEGER NOT NULL DEFAULT 0,
+		calendar_id INTEGER NOT NULL DEFAULT 0,
+		start_time  INTEGER NOT NULL,
+		end_time    INTEGER NOT NULL,
+		recurrence  TEXT NOT NULL DEFAULT '',
+		reason      TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX blackouts_schedule ON blackouts (schedule_id);
+	CREATE INDEX blackouts_calendar ON blackouts (calendar_id);
+
+	CREATE TABLE calendar_subscriptions (
+		schedule_id INTEGER NOT NULL,
+		calendar_id INTEGER NOT NULL,
+		PRIMARY KEY (schedule_id, calendar_id)
+	);`,
+
+	`ALTER TABLE schedules ADD COLUMN min_duration_minutes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE schedules ADD COLUMN max_duration_minutes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE schedules ADD COLUMN granularity_minutes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE schedules ADD COLUMN min_lead_time_minutes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE schedules ADD COLUMN max_advance_days INTEGER NOT NULL DEFAULT 0;`,
+
+	// attributes holds a JSON object, or '' when there are none.
+	`ALTER TABLE appointments ADD COLUMN title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE appointments ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE appointments ADD COLUMN location TEXT NOT NULL DEFAULT '';
+	ALTER TABLE appointments ADD COLUMN attributes TEXT NOT NULL DEFAULT '';`,
+
+	// status_changes holds a JSON array, or '' before the first transition.
+	`ALTER TABLE appointments ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
+	ALTER TABLE appointments ADD COLUMN status_changes TEXT NOT NULL DEFAULT '';`,
+
+	`CREATE TABLE holds (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule_id INTEGER NOT NULL,
+		start_time  INTEGER NOT NULL,
+		end_time    INTEGER NOT NULL,
+		legacy_time INTEGER NOT NULL DEFAULT 0,
+		expires_at  INTEGER NOT NULL
+	);
+	CREATE INDEX holds_schedule ON holds (schedule_id);
+	CREATE INDEX holds_expires_at ON holds (expires_at);`,
+}
+
+// SQLiteStore persists schedules in an embedded SQLite database so they
+// survive restarts.
+type SQLiteStore struct {
+	db *sql.DB
+	q  sqlQueryer
+}
+
+// sqlQueryer is satisfied by both *sql.DB and *sql.Tx, letting the same
+// queries run inside or outside of Atomically.
+type sqlQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLiteStore opens (or creates) the database at path and makes sure the
+// schema exists.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; sharing one connection avoids
+	// SQLITE_BUSY errors between concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err = migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db, q: db}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sqlite migration %v: %v", version+1, err)
+		}
+		if _, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (st *SQLiteStore) Close() error {
+	return st.db.Close()
+}
+
+// Atomically runs fn inside a single SQLite transaction. SQLite serializes
+// writers database-wide, which covers the requested schedules.
+func (st *SQLiteStore) Atomically(scheduleIDs []int, fn func(tx Store) error) error {
+	if st.db == nil {
+		// Already running inside a transaction.
+		return fn(st)
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(&SQLiteStore{q: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (st *SQLiteStore) CreateSchedule(s Schedule) (Schedule, error) {
+	workingHours, err := encodeWorkingHours(s.WorkingHours)
+	if err != nil {
+		return s, err
+	}
+
+	p, b := s.OverlapPolicy, s.BookingPolicy
+	res, err := st.q.Exec(
+		`INSERT INTO schedules (owner_name, time_zone, overlap_intervals, buffer_before_minutes, buffer_after_minutes,
+		working_hours, min_duration_minutes, max_duration_minutes, granularity_minutes, min_lead_time_minutes,
+		max_advance_days) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.OwnerName, s.TimeZone, p.Intervals, p.BufferBeforeMinutes, p.BufferAfterMinutes, workingHours,
+		b.MinDurationMinutes, b.MaxDurationMinutes, b.GranularityMinutes, b.MinLeadTimeMinutes, b.MaxAdvanceDays,
+	)
+	if err != nil {
+		return s, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return s, err
+	}
+
+	s.ID = int(id)
+	if err = st.saveSubscriptions(s); err != nil {
+		return s, err
+	}
+
+	s.Appointments = make(map[int]Appointment)
+	s.index = appointmentIndex{}
+	s.Version = 1
+	s.Blackouts, err = st.scheduleBlackouts(s.ID)
+	return s, err
+}
+
+func (st *SQLiteStore) GetSchedule(id int) (Schedule, error) {
+	return st.getSchedule(id, func() ([]Appointment, error) {
+		return st.ListAppointments(id)
+	})
+}
+
+func (st *SQLiteStore) GetScheduleBetween(id int, from, to time.Time) (Schedule, error) {
+	return st.getSchedule(id, func() ([]Appointment, error) {
+		return st.ListAppointmentsBetween(id, from, to)
+	})
+}
+
+// getSchedule loads a schedule with the appointments listed by appointments.
+func (st *SQLiteStore) getSchedule(id int, appointments func() ([]Appointment, error)) (Schedule, error) {
+	s := Schedule{ID: id}
+	p, b := &s.OverlapPolicy, &s.BookingPolicy
+	var workingHours string
+	err := st.q.QueryRow(
+		`SELECT owner_name, time_zone, overlap_intervals, buffer_before_minutes, buffer_after_minutes, working_hours,
+		min_duration_minutes, max_duration_minutes, granularity_minutes, min_lead_time_minutes, max_advance_days,
+		version FROM schedules WHERE id = ?`,
+		id,
+	).Scan(&s.OwnerName, &s.TimeZone, &p.Intervals, &p.BufferBeforeMinutes, &p.BufferAfterMinutes, &workingHours,
+		&b.MinDurationMinutes, &b.MaxDurationMinutes, &b.GranularityMinutes, &b.MinLeadTimeMinutes, &b.MaxAdvanceDays,
+		&s.Version)
+	if err == sql.ErrNoRows {
+		return s, scheduleNotFound(id)
+	}
+	if err != nil {
+		return s, err
+	}
+	if s.WorkingHours, err = decodeWorkingHours(workingHours); err != nil {
+		return s, err
+	}
+
+	listed, err := appointments()
+	if err != nil {
+		return s, err
+	}
+
+	s.Appointments = make(map[int]Appointment, len(listed))
+	for _, a := range listed {
+		s.Appointments[a.ID] = a
+	}
+	s.index = newAppointmentIndex(s.Appointments)
+
+	if s.CalendarIDs, err = st.calendarIDs(id); err != nil {
+		return s, err
+	}
+	if s.Holds, err = st.scheduleHolds(id); err != nil {
+		return s, err
+	}
+	s.Blackouts, err = st.scheduleBlackouts(id)
+	return s, err
+}
+
+func encodeWorkingHours(w *WorkingHours) (string, error) {
+	if w == nil {
+		return "", nil
+	}
+
+	encoded, err := json.Marshal(w)
+	return string(encoded), err
+}
+
+func decodeWorkingHours(value string) (*WorkingHours, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var w WorkingHours
+	err := json.Unmarshal([]byte(value), &w)
+	return &w, err
+}
+
+func (st *SQLiteStore) UpdateSchedule(s Schedule) (updated Schedule, err error) {
+	err = st.Atomically([]int{s.ID}, func(tx Store) error {
+		q := tx.(*SQLiteStore).q
+
+		// Appointments are read back in the new zone, changing them too.
+		_, err := q.Exec(
+			`UPDATE appointments SET version = version + 1
+			WHERE schedule_id = ? AND (SELECT time_zone FROM schedules WHERE id = ?) != ?`,
+			s.ID, s.ID, s.TimeZone,
+		)
+		if err != nil {
+			return err
+		}
+
+		workingHours, err := encodeWorkingHours(s.WorkingHours)
+		if err != nil {
+			return err
+		}
+
+		p, b := s.OverlapPolicy, s.BookingPolicy
+		res, err := q.Exec(
+			`UPDATE schedules SET owner_name = ?, time_zone = ?, overlap_intervals = ?, buffer_before_minutes = ?,
+			buffer_after_minutes = ?, working_hours = ?, min_duration_minutes = ?, max_duration_minutes = ?,
+			granularity_minutes = ?, min_lead_time_minutes = ?, max_advance_days = ?, version = version + 1
+			WHERE id = ?`,
+			s.OwnerName, s.TimeZone, p.Intervals, p.BufferBeforeMinutes, p.BufferAfterMinutes, workingHours,
+			b.MinDurationMinutes, b.MaxDurationMinutes, b.GranularityMinutes, b.MinLeadTimeMinutes, b.MaxAdvanceDays, s.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return scheduleNotFound(s.ID)
+		}
+
+		if _, err = q.Exec(`DELETE FROM calendar_subscriptions WHERE schedule_id = ?`, s.ID); err != nil {
+			return err
+		}
+		if err = tx.(*SQLiteStore).saveSubscriptions(s); err != nil {
+			return err
+		}
+
+		updated, err = tx.GetSchedule(s.ID)
+		return err
+	})
+	return
+}
+
+func (st *SQLiteStore) DeleteSchedule(id int) (deleted Schedule, err error) {
+	err = st.Atomically([]int{id}, func(tx Store) error {
+		deleted, err = tx.GetSchedule(id)
+		if err != nil {
+			return err
+		}
+
+		q := tx.(*SQLiteStore).q
+		if _, err = q.Exec(`DELETE FROM appointments WHERE schedule_id = ?`, id); err != nil {
+			return err
+		}
+		if _, err = q.Exec(`DELETE FROM blackouts WHERE schedule_id = ?`, id); err != nil {
+			return err
+		}
+		if _, err = q.Exec(`DELETE FROM holds WHERE schedule_id = ?`, id); err != nil {
+			return err
+		}
+		if _, err = q.Exec(`DELETE FROM calendar_subscriptions WHERE schedule_id = ?`, id); err != nil {
+			return err
+		}
+		_, err = q.Exec(`DELETE FROM schedules WHERE id = ?`, id)
+		return err
+	})
+	return
+}
+
+func (st *SQLiteStore) ListSchedules(q ScheduleQuery) ([]Schedule, int, error) {
+	var where []string
+	var args []interface{}
+	if q.OwnerName != "" {
+		where = append(where, `owner_name = ?`)
+		args = append(args, q.OwnerName)
+	}
+	if q.OwnerNamePrefix != "" {
+		// LIKE ignores case in SQLite, so compare the leading characters.
+		where = append(where, `substr(owner_name, 1, ?) = ?`)
+		args = append(args, utf8.RuneCountInString(q.OwnerNamePrefix), q.OwnerNamePrefix)
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = ` WHERE ` + strings.Join(where, ` AND `)
+	}
+
+	var total int
+	if err := st.q.QueryRow(`SELECT count(*) FROM schedules`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	op, direction := `>`, ` ASC`
+	if q.Descending {
+		op, direction = `<`, ` DESC`
+	}
+	orderBy := `id` + direction
+	if q.SortBy == SortByOwnerName {
+		orderBy = `owner_name` + direction + `, ` + orderBy
+	}
+
+	if q.After != nil {
+		if q.SortBy == SortByOwnerName {
+			where = append(where, `(owner_name, id) `+op+` (?, ?)`)
+			args = append(args, q.After.OwnerName, q.After.ID)
+		} else {
+			where = append(where, `id `+op+` ?`)
+			args = append(args, q.After.ID)
+		}
+		filter = ` WHERE ` + strings.Join(where, ` AND `)
+	}
+
+	rows, err := st.q.Query(
+		`SELECT id, owner_name, time_zone FROM schedules`+filter+` ORDER BY `+orderBy+` LIMIT ?`,
+		append(args, q.Limit)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		var s Schedule
+		if err = rows.Scan(&s.ID, &s.OwnerName, &s.TimeZone); err != nil {
+			return nil, 0, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, total, rows.Err()
+}
+
+func (st *SQLiteStore) CreateAppointment(a Appointment) (created Appointment, err error) {
+	err = st.Atomically([]int{a.ScheduleID}, func(tx Store) error {
+		q := tx.(*SQLiteStore)
+		if err := q.scheduleExists(a.ScheduleID); err != nil {
+			return err
+		}
+
+		if a.Status == "" {
+			a.Status = StatusConfirmed
+		}
+		statusChanges, err := encodeStatusChanges(a.StatusChanges)
+		if err != nil {
+			return err
+		}
+
+		res, err := q.q.Exec(
+			`INSERT INTO appointments (schedule_id, start_time, end_time, legacy_time, recurrence, exceptions, series_id,
+			recurrence_id, meeting_id, title, description, location, attributes, status, status_changes)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			a.ScheduleID, a.StartTime.Unix(), a.EndTime.Unix(), a.StartTime.Legacy, recurrenceString(a.Recurrence),
+			exceptionsString(a.Exceptions), a.SeriesID, recurrenceIDValue(a.RecurrenceID), a.MeetingID,
+			a.Title, a.Description, a.Location, attributesString(a.Attributes), a.Status, statusChanges,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		a.ID, a.Version = int(id), 1
+		created = a
+		return q.touch(a.ScheduleID)
+	})
+	return
+}
+
+func (st *SQLiteStore) GetAppointment(scheduleID, appointmentID int) (Appointment, error) {
+	if err := st.scheduleExists(scheduleID); err != nil {
+		return Appointment{}, err
+	}
+
+	row := st.q.QueryRow(
+		`SELECT `+sqliteAppointmentColumns+` FROM appointments a JOIN schedules s ON s.id = a.schedule_id
+		WHERE a.schedule_id = ? AND a.id = ?`,
+		scheduleID, appointmentID,
+	)
+
+	a, err := scanAppointment(row)
+	if err == sql.ErrNoRows {
+		return a, appointmentNotFound(appointmentID)
+	}
+
+	return a, err
+}
+
+func (st *SQLiteStore) UpdateAppointment(a Appointment) (Appointment, error) {
+	err := st.Atomically([]int{a.ScheduleID}, func(tx Store) error {
+		current, err := tx.GetAppointment(a.ScheduleID, a.ID)
+		if err != nil {
+			return err
+		}
+
+		a.Version = current.Version + 1
+		return tx.(*SQLiteStore).saveAppointment(a, a.ScheduleID)
+	})
+	return a, err
+}
+
+func (st *SQLiteStore) MoveAppointment(a Appointment, fromScheduleID int) (Appointment, error) {
+	err := st.Atomically([]int{fromScheduleID, a.ScheduleID}, func(tx Store) error {
+		current, err := tx.GetAppointment(fromScheduleID, a.ID)
+		if err != nil {
+			return err
+		}
+		if err = tx.(*SQLiteStore).scheduleExists(a.ScheduleID); err != nil {
+			return err
+		}
+
+		a.Version = current.Version + 1
+		return tx.(*SQLiteStore).saveAppointment(a, fromScheduleID, a.ScheduleID)
+	})
+	return a, err
+}
+
+// saveAppointment overwrites the stored row for a.ID with a and bumps the
+// versions of the schedules it touches.
+func (st *SQLiteStore) saveAppointment(a Appointment, scheduleIDs ...int) error {
+	statusChanges, err := encodeStatusChanges(a.StatusChanges)
+	if err != nil {
+		return err
+	}
+
+	_, err = st.q.Exec(
+		`UPDATE appointments SET schedule_id = ?, start_time = ?, end_time = ?, legacy_time = ?, recurrence = ?,
+		exceptions = ?, series_id = ?, recurrence_id = ?, meeting_id = ?, title = ?, description = ?, location = ?,
+		attributes = ?, status = ?, status_changes = ?, version = ? WHERE id = ?`,
+		a.ScheduleID, a.StartTime.Unix(), a.EndTime.Unix(), a.StartTime.Legacy, recurrenceString(a.Recurrence),
+		exceptionsString(a.Exceptions), a.SeriesID, recurrenceIDValue(a.RecurrenceID), a.MeetingID,
+		a.Title, a.Description, a.Location, attributesString(a.Attributes), a.Status, statusChanges, a.Version, a.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return st.touch(scheduleIDs...)
+}
+
+// touch bumps the versions of schedules whose appointments changed.
+func (st *SQLiteStore) touch(scheduleIDs ...int) error {
+	for _, id := range scheduleIDs {
+		if _, err := st.q.Exec(`UPDATE schedules SET version = version + 1 WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (st *SQLiteStore) DeleteAppointment(scheduleID, appointmentID int) (deleted Appointment, err error) {
+	err = st.Atomically([]int{scheduleID}, func(tx Store) error {
+		deleted, err = tx.GetAppointment(scheduleID, appointmentID)
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.(*SQLiteStore).q.Exec(`DELETE FROM appointments WHERE id = ?`, appointmentID); err != nil {
+			return err
+		}
+		return tx.(*SQLiteStore).touch(scheduleID)
+	})
+	return
+}
+
+func (st *SQLiteStore) ListAppointments(scheduleID int) ([]Appointment, error) {
+	return st.queryAppointments(scheduleID, ``)
+}
+
+func (st *SQLiteStore) ListAppointmentsBetween(scheduleID int, from, to time.Time) ([]Appointment, error) {
+	return st.queryAppointments(scheduleID, ` AND a.start_time < ? AND (a.recurrence != '' OR a.end_time > ?)`, to.Unix(), from.Unix())
+}
+
+// queryAppointments lists a schedule's appointments ordered by start time,
+// narrowed by an optional extra condition on the appointments table.
+func (st *SQLiteStore) queryAppointments(scheduleID int, condition string, args ...interface{}) ([]Appointment, error) {
+	if err := st.scheduleExists(scheduleID); err != nil {
+		return nil, err
+	}
+
+	rows, err := st.q.Query(
+		`SELECT `+sqliteAppointmentColumns+` FROM appointments a JOIN schedules s ON s.id = a.schedule_id
+		WHERE a.schedule_id = ?`+condition+` ORDER BY a.start_time, a.id`,
+		append([]interface{}{scheduleID}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appointments := []Appointment{}
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, a)
+	}
+
+	return appointments, rows.Err()
+}
+
+func (st *SQLiteStore) CreateMeeting(m Meeting) (Meeting, error) {
+	res, err := st.q.Exec(`INSERT INTO meetings DEFAULT VALUES`)
+	if err != nil {
+		return m, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return m, err
+	}
+
+	m.ID = int(id)
+	return m, nil
+}
+
+func (st *SQLiteStore) GetMeeting(id int) (Meeting, error) {
+	var found int
+	err := st.q.QueryRow(`SELECT 1 FROM meetings WHERE id = ?`, id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return Meeting{}, meetingNotFound(id)
+	}
+	if err != nil {
+		return Meeting{}, err
+	}
+
+	rows, err := st.q.Query(
+		`SELECT `+sqliteAppointmentColumns+` FROM appointments a JOIN schedules s ON s.id = a.schedule_id
+		WHERE a.meeting_id = ?`,
+		id,
+	)
+	if err != nil {
+		return Meeting{}, err
+	}
+	defer rows.Close()
+
+	var appointments []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return Meeting{}, err
+		}
+		appointments = append(appointments, a)
+	}
+
+	return newMeeting(id, appointments), rows.Err()
+}
+
+func (st *SQLiteStore) DeleteMeeting(id int) (deleted Meeting, err error) {
+	err = st.Atomically(nil, func(tx Store) error {
+		deleted, err = tx.GetMeeting(id)
+		if err != nil {
+			return err
+		}
+
+		q := tx.(*SQLiteStore).q
+		if _, err = q.Exec(`DELETE FROM appointments WHERE meeting_id = ?`, id); err != nil {
+			return err
+		}
+		if _, err = q.Exec(`DELETE FROM meetings WHERE id = ?`, id); err != nil {
+			return err
+		}
+		return tx.(*SQLiteStore).touch(deleted.ScheduleIDs...)
+	})
+	return
+}
+
+func (st *SQLiteStore) CreateCalendar(c Calendar) (Calendar, error) {
+	res, err := st.q.Exec(`INSERT INTO calendars (name, time_zone) VALUES (?, ?)`, c.Name, c.TimeZone)
+	if err != nil {
+		return c, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return c, err
+	}
+
+	c.ID = int(id)
+	c.Blackouts = []Blackout{}
+	return c, nil
+}
+
+func (st *SQLiteStore) GetCalendar(id int) (Calendar, error) {
+	c := Calendar{ID: id}
+	err := st.q.QueryRow(`SELECT name, time_zone FROM calendars WHERE id = ?`, id).Scan(&c.Name, &c.TimeZone)
+	if err == sql.ErrNoRows {
+		return c, calendarNotFound(id)
+	}
+	if err != nil {
+		return c, err
+	}
+
+	if c.Blackouts, err = st.queryBlackouts(`WHERE b.calendar_id = ? ORDER BY b.id`, id); c.Blackouts == nil {
+		c.Blackouts = []Blackout{}
+	}
+	return c, err
+}
+
+func (st *SQLiteStore) DeleteCalendar(id int) (deleted Calendar, err error) {
+	err = st.Atomically(nil, func(tx Store) error {
+		deleted, err = tx.GetCalendar(id)
+		if err != nil {
+			return err
+		}
+
+		txStore := tx.(*SQLiteStore)
+		if err = txStore.touchSubscribers(id); err != nil {
+			return err
+		}
+		for _, table := range []string{"blackouts", "calendar_subscriptions"} {
+			if _, err = txStore.q.Exec(`DELETE FROM `+table+` WHERE calendar_id = ?`, id); err != nil {
+				return err
+			}
+		}
+		_, err = txStore.q.Exec(`DELETE FROM calendars WHERE id = ?`, id)
+		return err
+	})
+	return
+}
+
+func (st *SQLiteStore) CreateBlackout(b Blackout) (created Blackout, err error) {
+	err = st.Atomically([]int{b.ScheduleID}, func(tx Store) error {
+		txStore := tx.(*SQLiteStore)
+		if err := txStore.blackoutOwnerExists(b); err != nil {
+			return err
+		}
+
+		res, err := txStore.q.Exec(
+			`INSERT INTO blackouts (schedule_id, calendar_id, start_time, end_time, recurrence, reason)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			b.ScheduleID, b.CalendarID, b.StartTime.Unix(), b.EndTime.Unix(), recurrenceString(b.Recurrence), b.Reason,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		created = b
+		created.ID = int(id)
+		return txStore.touchBlackoutOwner(b)
+	})
+	return
+}
+
+func (st *SQLiteStore) DeleteBlackout(b Blackout) (deleted Blackout, err error) {
+	err = st.Atomically([]int{b.ScheduleID}, func(tx Store) error {
+		txStore := tx.(*SQLiteStore)
+		if err := txStore.blackoutOwnerExists(b); err != nil {
+			return err
+		}
+
+		found, err := txStore.queryBlackouts(`WHERE b.id = ? AND b.schedule_id = ? AND b.calendar_id = ?`,
+			b.ID, b.ScheduleID, b.CalendarID)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return blackoutNotFound(b.ID)
+		}
+		deleted = found[0]
+
+		if _, err = txStore.q.Exec(`DELETE FROM blackouts WHERE id = ?`, b.ID); err != nil {
+			return err
+		}
+		return txStore.touchBlackoutOwner(b)
+	})
+	return
+}
+
+func (st *SQLiteStore) CreateHold(h Hold) (created Hold, err error) {
+	err = st.Atomically([]int{h.ScheduleID}, func(tx Store) error {
+		q := tx.(*SQLiteStore)
+		if err := q.scheduleExists(h.ScheduleID); err != nil {
+			return err
+		}
+
+		res, err := q.q.Exec(
+			`INSERT INTO holds (schedule_id, start_time, end_time, legacy_time, expires_at) VALUES (?, ?, ?, ?, ?)`,
+			h.ScheduleID, h.StartTime.Unix(), h.EndTime.Unix(), h.StartTime.Legacy, h.ExpiresAt.Unix(),
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		created = h
+		created.ID = int(id)
+		return q.touch(h.ScheduleID)
+	})
+	return
+}
+
+func (st *SQLiteStore) DeleteHold(scheduleID, holdID int) (deleted Hold, err error) {
+	err = st.Atomically([]int{scheduleID}, func(tx Store) error {
+		q := tx.(*SQLiteStore)
+		holds, err := q.scheduleHolds(scheduleID)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range holds {
+			if h.ID == holdID {
+				deleted = h
+				if _, err = q.q.Exec(`DELETE FROM holds WHERE id = ?`, holdID); err != nil {
+					return err
+				}
+				return q.touch(scheduleID)
+			}
+		}
+
+		if err = q.scheduleExists(scheduleID); err != nil {
+			return err
+		}
+		return holdNotFound(holdID)
+	})
+	return
+}
+
+// DeleteExpiredHolds bumps the version of every schedule it sweeps, whose
+// representation loses the holds.
+func (st *SQLiteStore) DeleteExpiredHolds(now time.Time) (deleted int, err error) {
+	err = st.Atomically(nil, func(tx Store) error {
+		q := tx.(*SQLiteStore)
+		_, err := q.q.Exec(
+			`UPDATE schedules SET version = version + 1
+			WHERE id IN (SELECT schedule_id FROM holds WHERE expires_at <= ?)`,
+			now.Unix(),
+		)
+		if err != nil {
+			return err
+		}
+
+		res, err := q.q.Exec(`DELETE FROM holds WHERE expires_at <= ?`, now.Unix())
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		deleted = int(rows)
+		return err
+	})
+	return
+}
+
+// scheduleHolds reads the holds on a schedule in its time zone, by start
+// time.
+func (st *SQLiteStore) scheduleHolds(scheduleID int) ([]Hold, error) {
+	rows, err := st.q.Query(
+		`SELECT h.id, h.start_time, h.end_time, h.legacy_time, h.expires_at, s.time_zone
+		FROM holds h JOIN schedules s ON s.id = h.schedule_id
+		WHERE h.schedule_id = ? ORDER BY h.start_time, h.id`,
+		scheduleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []Hold
+	for rows.Next() {
+		h := Hold{ScheduleID: scheduleID}
+		var start, end, expiresAt int64
+		var legacy bool
+		var timeZone string
+		if err = rows.Scan(&h.ID, &start, &end, &legacy, &expiresAt, &timeZone); err != nil {
+			return nil, err
+		}
+
+		loc := Schedule{TimeZone: timeZone}.Location()
+		h.StartTime = storedTimestamp(start, legacy, loc)
+		h.EndTime = storedTimestamp(end, legacy, loc)
+		h.ExpiresAt = storedTimestamp(expiresAt, false, loc)
+		holds = append(holds, h)
+	}
+
+	return holds, rows.Err()
+}
+
+func (st *SQLiteStore) blackoutOwnerExists(b Blackout) error {
+	if b.ScheduleID != 0 {
+		return st.scheduleExists(b.ScheduleID)
+	}
+
+	var found int
+	err := st.q.QueryRow(`SELECT 1 FROM calendars WHERE id = ?`, b.CalendarID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return calendarNotFound(b.CalendarID)
+	}
+
+	return err
+}
+
+// touchBlackoutOwner bumps the versions of the schedules b applies to.
+func (st *SQLiteStore) touchBlackoutOwner(b Blackout) error {
+	if b.ScheduleID != 0 {
+		return st.touch(b.ScheduleID)
+	}
+
+	return st.touchSubscribers(b.CalendarID)
+}
+
+// touchSubscribers bumps the versions of the schedules subscribed to a
+// calendar.
+func (st *SQLiteStore) touchSubscribers(calendarID int) error {
+	_, err := st.q.Exec(
+		`UPDATE schedules SET version = version + 1
+		WHERE id IN (SELECT schedule_id FROM calendar_subscriptions WHERE calendar_id = ?)`,
+		calendarID,
+	)
+	return err
+}
+
+func (st *SQLiteStore) saveSubscriptions(s Schedule) error {
+	for _, calendarID := range s.CalendarIDs {
+		_, err := st.q.Exec(
+			`INSERT INTO calendar_subscriptions (schedule_id, calendar_id) VALUES (?, ?)`, s.ID, calendarID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (st *SQLiteStore) calendarIDs(scheduleID int) ([]int, error) {
+	rows, err := st.q.Query(
+		`SELECT calendar_id FROM calendar_subscriptions WHERE schedule_id = ? ORDER BY calendar_id`, scheduleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// scheduleBlackouts returns the blackouts of a schedule and its calendars.
+func (st *SQLiteStore) scheduleBlackouts(scheduleID int) ([]Blackout, error) {
+	return st.queryBlackouts(
+		`WHERE b.schedule_id = ?1 OR b.calendar_id IN
+		(SELECT calendar_id FROM calendar_subscriptions WHERE schedule_id = ?1)
+		ORDER BY b.start_time, b.id`,
+		scheduleID,
+	)
+}
+
+// queryBlackouts reads blackouts matching condition, each in its owner's time
+// zone.
+func (st *SQLiteStore) queryBlackouts(condition string, args ...interface{}) ([]Blackout, error) {
+	rows, err := st.q.Query(
+		`SELECT b.id, b.schedule_id, b.calendar_id, b.start_time, b.end_time, b.recurrence, b.reason,
+		COALESCE(s.time_zone, c.time_zone, 'UTC')
+		FROM blackouts b LEFT JOIN schedules s ON s.id = b.schedule_id LEFT JOIN calendars c ON c.id = b.calendar_id
+		`+condition,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blackouts []Blackout
+	for rows.Next() {
+		var b Blackout
+		var start, end int64
+		var recurrence, timeZone string
+		err = rows.Scan(&b.ID, &b.ScheduleID, &b.CalendarID, &start, &end, &recurrence, &b.Reason, &timeZone)
+		if err != nil {
+			return nil, err
+		}
+
+		if recurrence != "" {
+			if b.Recurrence, err = ParseRecurrenceRule(recurrence); err != nil {
+				return nil, err
+			}
+		}
+
+		loc := Calendar{TimeZone: timeZone}.Location()
+		b.StartTime = storedTimestamp(start, false, loc)
+		b.EndTime = storedTimestamp(end, false, loc)
+		blackouts = append(blackouts, b)
+	}
+
+	return blackouts, rows.Err()
+}
+
+func (st *SQLiteStore) scheduleExists(id int) error {
+	var found int
+	err := st.q.QueryRow(`SELECT 1 FROM schedules WHERE id = ?`, id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return scheduleNotFound(id)
+	}
+
+	return err
+}
+
+const sqliteAppointmentColumns = `a.id, a.schedule_id, a.start_time, a.end_time, a.legacy_time, a.recurrence,
+	a.exceptions, a.series_id, a.recurrence_id, a.meeting_id, a.title, a.description, a.location, a.attributes,
+	a.status, a.status_changes, a.version, s.time_zone`
+
+type sqlScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAppointment reads a row selected with sqliteAppointmentColumns.
+func scanAppointment(row sqlScanner) (Appointment, error) {
+	var a Appointment
+	var start, end int64
+	var legacy bool
+	var recurrence, exceptions, attributes, statusChanges, timeZone string
+	var recurrenceID sql.NullInt64
+	err := row.Scan(&a.ID, &a.ScheduleID, &start, &end, &legacy, &recurrence, &exceptions, &a.SeriesID, &recurrenceID,
+		&a.MeetingID, &a.Title, &a.Description, &a.Location, &attributes, &a.Status, &statusChanges, &a.Version,
+		&timeZone)
+	if err != nil {
+		return a, err
+	}
+
+	if statusChanges != "" {
+		if err = json.Unmarshal([]byte(statusChanges), &a.StatusChanges); err != nil {
+			return a, err
+		}
+	}
+
+	if attributes != "" {
+		if err = json.Unmarshal([]byte(attributes), &a.Attributes); err != nil {
+			return a, err
+		}
+	}
+
+	if recurrence != "" {
+		rule, err := ParseRecurrenceRule(recurrence)
+		if err != nil {
+			return a, err
+		}
+		a.Recurrence = rule
+	}
+
+	loc := Schedule{TimeZone: timeZone}.Location()
+	a.StartTime = storedTimestamp(start, legacy, loc)
+	a.EndTime = storedTimestamp(end, legacy, loc)
+
+	if recurrenceID.Valid {
+		ts := storedTimestamp(recurrenceID.Int64, legacy, loc)
+		a.RecurrenceID = &ts
+	}
+
+	if exceptions != "" {
+		for _, value := range strings.Split(exceptions, ",") {
+			sec, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return a, err
+			}
+			a.Exceptions = append(a.Exceptions, storedTimestamp(sec, legacy, loc))
+		}
+	}
+
+	return a, nil
+}
+
+func storedTimestamp(sec int64, legacy bool, loc *time.Location) Timestamp {
+	if legacy {
+		return UnixTimestamp(sec)
+	}
+
+	return NewTimestamp(time.Unix(sec, 0).In(loc))
+}
+
+func recurrenceString(r *RecurrenceRule) string {
+	if r == nil {
+		return ""
+	}
+
+	return r.String()
+}
+
+func exceptionsString(exceptions []Timestamp) string {
+	var values []string
+	for _, ts := range exceptions {
+		values = append(values, strconv.FormatInt(ts.Unix(), 10))
+	}
+
+	return strings.Join(values, ",")
+}
+
+func attributesString(attributes map[string]json.RawMessage) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+
+	encoded, _ := json.Marshal(attributes)
+	return string(encoded)
+}
+
+func encodeStatusChanges(changes []StatusChange) (string, error) {
+	if len(changes) == 0 {
+		return "", nil
+	}
+
+	encoded, err := json.Marshal(changes)
+	return string(encoded), err
+}
+
+func recurrenceIDValue(ts *Timestamp) interface{} {
+	if ts == nil {
+		return nil
+	}
+
+	return ts.Unix()
+}
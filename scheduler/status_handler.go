@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+func (h *Handler) ConfirmAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.transitionAppointment(w, r, StatusConfirmed)
+}
+
+func (h *Handler) CompleteAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.transitionAppointment(w, r, StatusCompleted)
+}
+
+func (h *Handler) NoShowAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.transitionAppointment(w, r, StatusNoShow)
+}
+
+func (h *Handler) CancelAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	h.transitionAppointment(w, r, StatusCancelled)
+}
+
+func (h *Handler) transitionAppointment(w http.ResponseWriter, r *http.Request, status string) {
+	scheduleID, err := convertIDParam(r, "scheduleID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+
+	appointmentID, err := convertIDParam(r, "appointmentID")
+	if err != nil {
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid appointment ID")
+		return
+	}
+
+	// The body, giving the reason for the change, is optional.
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil && err != io.EOF {
+		log.Println("transitionAppointment Err: ", err.Error())
+		http_helpers.RespondWithError(w, http.StatusBadRequest, "Invalid Request Body")
+		return
+	}
+	defer r.Body.Close()
+
+	a, err := transitionAppointment(h.Store, scheduleID, appointmentID, status, body.Reason, r.Header.Get("If-Match"))
+	if err != nil {
+		log.Println("transitionAppointment - ", err.Error())
+		respondWithServiceError(w, err, "Unable to change appointment status")
+		return
+	}
+
+	w.Header().Set("ETag", appointmentTag(a))
+	http_helpers.RespondWithJSON(w, http.StatusOK, a)
+}
@@ -0,0 +1,177 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Appointment Status Handlers", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+		s     Schedule
+	)
+
+	book := func(start string, status string) (*httptest.ResponseRecorder, Appointment) {
+		t, _ := time.Parse(time.RFC3339, start)
+		reqBody := fmt.Sprintf(`{"start_time": %q, "end_time": %q, "status": %q}`,
+			start, t.Add(time.Hour).Format(time.RFC3339), status)
+		recorder := serve(h.CreateAppointmentHandler, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID))
+
+		var a Appointment
+		json.NewDecoder(recorder.Body).Decode(&a)
+		return recorder, a
+	}
+
+	transition := func(handlerFunc http.HandlerFunc, a Appointment, reqBody string) (*httptest.ResponseRecorder, Appointment) {
+		recorder := serve(handlerFunc, "POST", "/", reqBody, "scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID))
+
+		var updated Appointment
+		json.NewDecoder(recorder.Body).Decode(&updated)
+		return recorder, updated
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+	})
+
+	It("Should book appointments confirmed unless asked for tentative", func() {
+		recorder, a := book("2019-06-03T09:00:00-06:00", "")
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(a.Status).To(Equal(StatusConfirmed))
+
+		recorder, a = book("2019-06-04T09:00:00-06:00", "tentative")
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(a.Status).To(Equal(StatusTentative))
+
+		recorder, _ = book("2019-06-05T09:00:00-06:00", "completed")
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("Should move through the lifecycle recording reasons and times", func() {
+		_, a := book("2019-06-03T09:00:00-06:00", "tentative")
+
+		before := time.Now().Add(-time.Second)
+		recorder, a := transition(h.ConfirmAppointmentHandler, a, `{"reason": "Deposit paid"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("ETag")).To(Equal(fmt.Sprintf(`"%v-%v-2"`, s.ID, a.ID)))
+
+		recorder, a = transition(h.CompleteAppointmentHandler, a, "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(a.Status).To(Equal(StatusCompleted))
+		Expect(a.StatusChanges).To(HaveLen(2))
+		Expect(a.StatusChanges[0].Status).To(Equal(StatusConfirmed))
+		Expect(a.StatusChanges[0].Reason).To(Equal("Deposit paid"))
+		Expect(a.StatusChanges[0].ChangedAt.After(before)).To(BeTrue())
+		Expect(a.StatusChanges[1].Status).To(Equal(StatusCompleted))
+
+		fetched, _ := store.GetAppointment(s.ID, a.ID)
+		Expect(fetched.Status).To(Equal(StatusCompleted))
+	})
+
+	It("Should accept an empty body of unknown length", func() {
+		_, a := book("2019-06-03T09:00:00-06:00", "tentative")
+		params := []string{"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID)}
+
+		recorder := serveChunked(h.ConfirmAppointmentHandler, "POST", "/", "{", params...)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder = serveChunked(h.ConfirmAppointmentHandler, "POST", "/", "", params...)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		fetched, _ := store.GetAppointment(s.ID, a.ID)
+		Expect(fetched.Status).To(Equal(StatusConfirmed))
+	})
+
+	It("Should reject transitions the lifecycle does not allow", func() {
+		_, tentative := book("2019-06-03T09:00:00-06:00", "tentative")
+		recorder, _ := transition(h.CompleteAppointmentHandler, tentative, "")
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+		recorder, _ = transition(h.NoShowAppointmentHandler, tentative, "")
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+
+		_, confirmed := book("2019-06-04T09:00:00-06:00", "")
+		recorder, noShow := transition(h.NoShowAppointmentHandler, confirmed, "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		recorder, _ = transition(h.ConfirmAppointmentHandler, noShow, "")
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+
+		recorder, cancelled := transition(h.CancelAppointmentHandler, noShow, "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		recorder, _ = transition(h.CancelAppointmentHandler, cancelled, "")
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("Should free the time of cancelled appointments while keeping them listed", func() {
+		_, a := book("2019-06-03T09:00:00-06:00", "")
+		recorder, _ := book("2019-06-03T09:30:00-06:00", "")
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+		recorder, _ = transition(h.CancelAppointmentHandler, a, `{"reason": "Called away to the Wall"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		recorder, replacement := book("2019-06-03T09:30:00-06:00", "")
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+		recorder = serve(h.AppointmentDetailsHandler, "GET", "/", "", "scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID))
+		var fetched Appointment
+		json.NewDecoder(recorder.Body).Decode(&fetched)
+		Expect(fetched.Status).To(Equal(StatusCancelled))
+		Expect(fetched.StatusChanges[0].Reason).To(Equal("Called away to the Wall"))
+
+		list := func(filter string) []Appointment {
+			recorder := serve(h.ListAppointmentsHandler, "GET",
+				"/?from=2019-06-03T00:00:00-06:00&to=2019-06-04T00:00:00-06:00"+filter, "", "scheduleID", strconv.Itoa(s.ID))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resBody struct {
+				Appointments []Appointment `json:"appointments"`
+			}
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			return resBody.Appointments
+		}
+		Expect(list("")).To(HaveLen(2))
+		cancelled := list("&status=cancelled")
+		Expect(cancelled).To(HaveLen(1))
+		Expect(cancelled[0].ID).To(Equal(a.ID))
+		confirmed := list("&status=confirmed")
+		Expect(confirmed).To(HaveLen(1))
+		Expect(confirmed[0].ID).To(Equal(replacement.ID))
+
+		recorder = serve(h.ListAppointmentsHandler, "GET",
+			"/?from=2019-06-03T00:00:00-06:00&to=2019-06-04T00:00:00-06:00&status=postponed", "", "scheduleID", strconv.Itoa(s.ID))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should not change the status through an update", func() {
+		_, a := book("2019-06-03T09:00:00-06:00", "")
+
+		recorder := serve(h.PatchAppointmentHandler, "PATCH", "/", `{"status": "cancelled"}`,
+			"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID))
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("Should honour If-Match and limit the reason's length", func() {
+		_, a := book("2019-06-03T09:00:00-06:00", "")
+
+		recorder := serveWithHeaders(h.CancelAppointmentHandler, "POST", "/", "", map[string]string{"If-Match": `"7"`},
+			"scheduleID", strconv.Itoa(s.ID), "appointmentID", strconv.Itoa(a.ID))
+		Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+
+		reason, _ := json.Marshal(map[string]string{"reason": string(bytes.Repeat([]byte("a"), 501))})
+		recorder, _ = transition(h.CancelAppointmentHandler, a, string(reason))
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+})
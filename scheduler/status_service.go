@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const (
+	StatusTentative = "tentative"
+	StatusConfirmed = "confirmed"
+	StatusCompleted = "completed"
+	StatusNoShow    = "no_show"
+	StatusCancelled = "cancelled"
+
+	maxStatusReasonLength = 500
+)
+
+// statusTransitions lists the statuses an appointment can move to from each
+// status. Cancelled is final.
+var statusTransitions = map[string][]string{
+	StatusTentative: {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusCompleted, StatusNoShow, StatusCancelled},
+	StatusCompleted: {StatusCancelled},
+	StatusNoShow:    {StatusCancelled},
+	StatusCancelled: nil,
+}
+
+// StatusChange records an appointment moving to Status.
+type StatusChange struct {
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt Timestamp `json:"changed_at"`
+}
+
+// busy reports whether a takes up its time on the schedule. Cancelled
+// appointments are kept for the record but never conflict.
+func (a Appointment) busy() bool {
+	return a.Status != StatusCancelled
+}
+
+// checkInitialStatus defaults the status of a new appointment to confirmed.
+// Appointments can only be booked tentative or confirmed, and their history
+// starts empty.
+func checkInitialStatus(a *Appointment) error {
+	switch a.Status {
+	case "":
+		a.Status = StatusConfirmed
+	case StatusTentative, StatusConfirmed:
+	default:
+		return http_helpers.HttpError{
+			Message:    fmt.Sprintf("Appointments must be booked %v or %v", StatusTentative, StatusConfirmed),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+	a.StatusChanges = nil
+
+	return nil
+}
+
+// transitionAppointment moves an appointment to status, recording when and
+// why. Transitions outside statusTransitions are rejected with a 409.
+func transitionAppointment(store Store, scheduleID, appointmentID int, status, reason, ifMatch string) (updated Appointment, err error) {
+	if len(reason) > maxStatusReasonLength {
+		return updated, http_helpers.HttpError{
+			Message:    fmt.Sprintf("reason must be at most %v bytes", maxStatusReasonLength),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	err = store.Atomically([]int{scheduleID}, func(tx Store) error {
+		s, err := tx.GetSchedule(scheduleID)
+		if err != nil {
+			log.Println("transitionAppointment - ", err.Error())
+			return err
+		}
+
+		a, found := s.Appointments[appointmentID]
+		if !found {
+			return appointmentNotFound(appointmentID)
+		}
+		if err = checkIfMatch(ifMatch, appointmentTag(a)); err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, next := range statusTransitions[a.Status] {
+			allowed = allowed || next == status
+		}
+		if !allowed {
+			return http_helpers.HttpError{
+				Message:    fmt.Sprintf("A %v appointment cannot become %v", a.Status, status),
+				StatusCode: http.StatusConflict,
+			}
+		}
+
+		a.Status = status
+		a.StatusChanges = append(a.StatusChanges, StatusChange{
+			Status:    status,
+			Reason:    reason,
+			ChangedAt: NewTimestamp(time.Now().Truncate(time.Second).In(s.Location())),
+		})
+
+		updated, err = tx.UpdateAppointment(a)
+		return err
+	})
+	return
+}
@@ -1,19 +1,228 @@
 package scheduler
 
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const defaultTimeZone = "UTC"
+
 type Schedule struct {
 	ID           int                 `json:"id"`
 	OwnerName    string              `json:"owner_name"`
+	TimeZone     string              `json:"time_zone"`
 	Appointments map[int]Appointment `json:"appointments"`
+
+	OverlapPolicy OverlapPolicy `json:"overlap_policy"`
+	BookingPolicy BookingPolicy `json:"booking_policy"`
+
+	// WorkingHours, when set, limits when appointments can be booked.
+	WorkingHours *WorkingHours `json:"working_hours,omitempty"`
+
+	// CalendarIDs lists the holiday calendars the schedule subscribes to.
+	// Blackouts holds the schedule's own blackouts and those of its
+	// calendars, by start time; stores fill it in and it cannot be changed
+	// directly.
+	CalendarIDs []int      `json:"calendar_ids,omitempty"`
+	Blackouts   []Blackout `json:"blackouts,omitempty"`
+
+	// Holds lists the schedule's holds, including expired ones until they
+	// are swept. Stores fill it in; holds are created and deleted separately.
+	Holds []Hold `json:"holds,omitempty"`
+
+	// Version counts changes to the schedule and to its appointments. It
+	// backs the ETag of the schedule's representation.
+	Version int `json:"version"`
+
+	// index orders Appointments by time. Changes to Appointments should go
+	// through putAppointment and removeAppointment to keep it current.
+	index appointmentIndex
+}
+
+// Location resolves the schedule's IANA time zone, defaulting to UTC.
+func (s Schedule) Location() *time.Location {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
 }
 
 type Appointment struct {
-	ID         int `json:"id"`
-	ScheduleID int `json:"schedule_id"`
-	StartTime  int `json:"start_time"`
-	EndTime    int `json:"end_time"`
+	ID         int             `json:"id"`
+	ScheduleID int             `json:"schedule_id"`
+	StartTime  Timestamp       `json:"start_time"`
+	EndTime    Timestamp       `json:"end_time"`
+	Recurrence *RecurrenceRule `json:"recurrence,omitempty"`
+
+	// Exceptions lists the original starts of occurrences removed from a
+	// recurring series (RFC 5545 EXDATE).
+	Exceptions []Timestamp `json:"exceptions,omitempty"`
+
+	// RecurrenceID holds the original start of an occurrence. It is set on
+	// expanded occurrences and, together with SeriesID, on appointments
+	// that replace a single occurrence of a series.
+	RecurrenceID *Timestamp `json:"recurrence_id,omitempty"`
+	SeriesID     int        `json:"series_id,omitempty"`
+
+	// MeetingID links the copies of a Meeting held by each attendee.
+	MeetingID int `json:"meeting_id,omitempty"`
+
+	AppointmentDetails
+
+	// Status follows the lifecycle in statusTransitions, defaulting to
+	// confirmed when stores create an appointment without one.
+	// StatusChanges records each transition since booking.
+	Status        string         `json:"status"`
+	StatusChanges []StatusChange `json:"status_changes,omitempty"`
+
+	// Version counts changes to the appointment. Stores assign it; values
+	// passed in are ignored.
+	Version int `json:"version"`
+}
+
+// Meeting books the same time on several schedules at once. Every attendee's
+// schedule holds its own copy of the appointment, linked by MeetingID; the
+// meeting's times and recurrence are those of its copies.
+type Meeting struct {
+	ID           int             `json:"id"`
+	ScheduleIDs  []int           `json:"schedule_ids"`
+	StartTime    Timestamp       `json:"start_time"`
+	EndTime      Timestamp       `json:"end_time"`
+	Recurrence   *RecurrenceRule `json:"recurrence,omitempty"`
+	Appointments []Appointment   `json:"appointments"`
+}
+
+// newMeeting assembles a meeting from its appointments, ordered by schedule.
+func newMeeting(id int, appointments []Appointment) Meeting {
+	m := Meeting{ID: id, ScheduleIDs: []int{}, Appointments: appointments}
+	if m.Appointments == nil {
+		m.Appointments = []Appointment{}
+	}
+
+	sort.Slice(m.Appointments, func(i, j int) bool {
+		return m.Appointments[i].ScheduleID < m.Appointments[j].ScheduleID
+	})
+	for _, a := range m.Appointments {
+		m.ScheduleIDs = append(m.ScheduleIDs, a.ScheduleID)
+	}
+	if len(m.Appointments) > 0 {
+		first := m.Appointments[0]
+		m.StartTime, m.EndTime, m.Recurrence = first.StartTime, first.EndTime, first.Recurrence
+	}
+
+	return m
 }
 
-var SchedulesCreatedCount int
-var ScheduleCollection = make(map[int]Schedule)
+// Store persists schedules and their appointments. Lookups for missing
+// entities return an http_helpers.NotFoundError. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	// Atomically runs fn with exclusive write access to the given schedules.
+	// Reads and writes made through tx are isolated from other writers to
+	// those schedules, so checks performed inside fn still hold when fn
+	// writes. fn must perform all validation before its first write.
+	Atomically(scheduleIDs []int, fn func(tx Store) error) error
+
+	CreateSchedule(s Schedule) (Schedule, error)
+	GetSchedule(id int) (Schedule, error)
+	// GetScheduleBetween is GetSchedule with only the appointments that can
+	// have occurrences intersecting [from, to), for requests that look at
+	// part of a schedule.
+	GetScheduleBetween(id int, from, to time.Time) (Schedule, error)
+	// UpdateSchedule saves the owner name, time zone, overlap policy,
+	// working hours and calendar subscriptions of s.
+	UpdateSchedule(s Schedule) (Schedule, error)
+	DeleteSchedule(id int) (Schedule, error)
+	// ListSchedules returns one page of schedules matching q, without their
+	// appointments, along with the total number of matches.
+	ListSchedules(q ScheduleQuery) ([]Schedule, int, error)
+
+	CreateAppointment(a Appointment) (Appointment, error)
+	GetAppointment(scheduleID, appointmentID int) (Appointment, error)
+	UpdateAppointment(a Appointment) (Appointment, error)
+	// MoveAppointment saves a, keeping its ID, on schedule a.ScheduleID after
+	// removing it from fromScheduleID.
+	MoveAppointment(a Appointment, fromScheduleID int) (Appointment, error)
+	DeleteAppointment(scheduleID, appointmentID int) (Appointment, error)
+	ListAppointments(scheduleID int) ([]Appointment, error)
+	// ListAppointmentsBetween narrows ListAppointments to the appointments
+	// that can have occurrences intersecting [from, to): one-off appointments
+	// within it and series starting before to. Series known to end before
+	// from may be left out.
+	ListAppointmentsBetween(scheduleID int, from, to time.Time) ([]Appointment, error)
+
+	// CreateMeeting allocates an ID for a meeting between m.ScheduleIDs.
+	// Its appointments are created separately, with MeetingID set.
+	CreateMeeting(m Meeting) (Meeting, error)
+	GetMeeting(id int) (Meeting, error)
+	// DeleteMeeting removes a meeting along with all of its appointments.
+	DeleteMeeting(id int) (Meeting, error)
 
-var AppointmentsCreatedCount int
+	CreateCalendar(c Calendar) (Calendar, error)
+	GetCalendar(id int) (Calendar, error)
+	// DeleteCalendar removes a calendar along with its blackouts and
+	// subscriptions.
+	DeleteCalendar(id int) (Calendar, error)
+
+	// CreateBlackout saves b on schedule b.ScheduleID or, when that is zero,
+	// on calendar b.CalendarID.
+	CreateBlackout(b Blackout) (Blackout, error)
+	// DeleteBlackout removes blackout b.ID from the schedule or calendar b
+	// belongs to.
+	DeleteBlackout(b Blackout) (Blackout, error)
+
+	// CreateHold saves h on schedule h.ScheduleID.
+	CreateHold(h Hold) (Hold, error)
+	DeleteHold(scheduleID, holdID int) (Hold, error)
+	// DeleteExpiredHolds removes every hold expiring at or before now,
+	// returning how many there were. The schedules they were on change
+	// version.
+	DeleteExpiredHolds(now time.Time) (int, error)
+}
+
+func scheduleNotFound(id int) error {
+	return http_helpers.NotFoundError{
+		Message:    fmt.Sprintf("no schedule found for ID: %v", id),
+		EntityType: "Schedule",
+	}
+}
+
+func meetingNotFound(id int) error {
+	return http_helpers.NotFoundError{
+		Message:    fmt.Sprintf("no meeting found for ID: %v", id),
+		EntityType: "Meeting",
+	}
+}
+
+func calendarNotFound(id int) error {
+	return http_helpers.NotFoundError{
+		Message:    fmt.Sprintf("no calendar found for ID: %v", id),
+		EntityType: "Calendar",
+	}
+}
+
+func blackoutNotFound(id int) error {
+	return http_helpers.NotFoundError{
+		Message:    fmt.Sprintf("no blackout found for ID: %v", id),
+		EntityType: "Blackout",
+	}
+}
+
+func holdNotFound(id int) error {
+	return http_helpers.NotFoundError{
+		Message:    fmt.Sprintf("no hold found for ID: %v", id),
+		EntityType: "Hold",
+	}
+}
+
+func appointmentNotFound(id int) error {
+	return http_helpers.NotFoundError{
+		Message:    fmt.Sprintf("no appointment found for ID: %v", id),
+		EntityType: "Appointment",
+	}
+}
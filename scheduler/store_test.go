@@ -0,0 +1,754 @@
+package scheduler_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Stores", func() {
+	Context("MemoryStore", func() {
+		itBehavesLikeAStore(func() Store {
+			return NewMemoryStore()
+		})
+
+		It("Should keep state separate between store instances", func() {
+			store := NewMemoryStore()
+			s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+
+			_, err := NewMemoryStore().GetSchedule(s.ID)
+
+			Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+		})
+
+		It("Should lock a calendar's subscribers while it changes", func() {
+			store := NewMemoryStore()
+			c, _ := store.CreateCalendar(Calendar{Name: "Westeros"})
+			s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", CalendarIDs: []int{c.ID}})
+
+			done := make(chan struct{})
+			store.Atomically([]int{s.ID}, func(tx Store) error {
+				go func() {
+					store.DeleteCalendar(c.ID)
+					close(done)
+				}()
+				Consistently(done, 50*time.Millisecond).ShouldNot(BeClosed())
+				return nil
+			})
+			Eventually(done).Should(BeClosed())
+
+			fetched, _ := store.GetSchedule(s.ID)
+			Expect(fetched.CalendarIDs).To(BeEmpty())
+
+			_, err := store.UpdateSchedule(Schedule{ID: s.ID, OwnerName: "Tyrion Lannister", CalendarIDs: []int{c.ID}})
+			Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+		})
+
+		It("Should delete a meeting whose copy moves while it waits", func() {
+			store := NewMemoryStore()
+			var ids []int
+			for _, name := range []string{"Tyrion Lannister", "Arya Stark", "Sansa Stark"} {
+				s, _ := store.CreateSchedule(Schedule{OwnerName: name})
+				ids = append(ids, s.ID)
+			}
+			m, _ := store.CreateMeeting(Meeting{ScheduleIDs: ids[:2]})
+			var copies []Appointment
+			for _, id := range ids[:2] {
+				a, _ := store.CreateAppointment(Appointment{
+					ScheduleID: id,
+					MeetingID:  m.ID,
+					StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+					EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+				})
+				copies = append(copies, a)
+			}
+
+			done := make(chan struct{})
+			var err error
+			store.Atomically(ids[1:], func(tx Store) error {
+				go func() {
+					_, err = store.DeleteMeeting(m.ID)
+					close(done)
+				}()
+				Consistently(done, 50*time.Millisecond).ShouldNot(BeClosed())
+
+				moved := copies[1]
+				moved.ScheduleID = ids[2]
+				_, err := tx.MoveAppointment(moved, ids[1])
+				return err
+			})
+			Eventually(done).Should(BeClosed())
+
+			Expect(err).NotTo(HaveOccurred())
+			for _, id := range ids {
+				s, _ := store.GetSchedule(id)
+				Expect(s.Appointments).To(BeEmpty())
+			}
+		})
+	})
+
+	Context("SQLiteStore", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "scheduler-sqlite")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		itBehavesLikeAStore(func() Store {
+			store, err := NewSQLiteStore(filepath.Join(dir, "scheduler.db"))
+			Expect(err).NotTo(HaveOccurred())
+			return store
+		})
+
+		It("Should upgrade a database created before time zones were stored", func() {
+			path := filepath.Join(dir, "legacy.db")
+			db, err := sql.Open("sqlite", path)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = db.Exec(`
+				CREATE TABLE schedules (id INTEGER PRIMARY KEY AUTOINCREMENT, owner_name TEXT NOT NULL);
+				CREATE TABLE appointments (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					schedule_id INTEGER NOT NULL REFERENCES schedules(id),
+					start_time INTEGER NOT NULL,
+					end_time INTEGER NOT NULL
+				);
+				INSERT INTO schedules (owner_name) VALUES ('Tyrion Lannister');
+				INSERT INTO appointments (schedule_id, start_time, end_time) VALUES (1, 5, 9);
+			`)
+			Expect(err).NotTo(HaveOccurred())
+			db.Close()
+
+			store, err := NewSQLiteStore(path)
+			Expect(err).NotTo(HaveOccurred())
+			defer store.Close()
+
+			s, err := store.GetSchedule(1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.TimeZone).To(Equal("UTC"))
+			Expect(s.Appointments[1].StartTime).To(Equal(UnixTimestamp(5)))
+			Expect(s.Appointments[1].EndTime).To(Equal(UnixTimestamp(9)))
+		})
+
+		It("Should persist schedules and appointments across reopening the database", func() {
+			path := filepath.Join(dir, "persisted.db")
+			store, err := NewSQLiteStore(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+			a, _ := store.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(5), EndTime: UnixTimestamp(9)})
+			Expect(store.Close()).To(Succeed())
+
+			reopened, err := NewSQLiteStore(path)
+			Expect(err).NotTo(HaveOccurred())
+			defer reopened.Close()
+
+			fetched, err := reopened.GetSchedule(s.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched.OwnerName).To(Equal("Tyrion Lannister"))
+			Expect(fetched.Appointments).To(Equal(map[int]Appointment{a.ID: a}))
+		})
+	})
+})
+
+func itBehavesLikeAStore(newStore func() Store) {
+	var store Store
+
+	BeforeEach(func() {
+		store = newStore()
+	})
+
+	It("Should assign incrementing IDs to created schedules", func() {
+		first, err := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		Expect(err).NotTo(HaveOccurred())
+		second, err := store.CreateSchedule(Schedule{OwnerName: "Cersei Lannister"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(second.ID).To(Equal(first.ID + 1))
+		Expect(first.Appointments).To(BeEmpty())
+	})
+
+	It("Should update a schedule's owner and time zone", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC"})
+		start := time.Date(2019, 6, 3, 15, 0, 0, 0, time.UTC)
+		a, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+		})
+
+		s.OwnerName, s.TimeZone = "Tyrion, Hand of the Queen", "America/Denver"
+		updated, err := store.UpdateSchedule(s)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.OwnerName).To(Equal("Tyrion, Hand of the Queen"))
+		Expect(updated.Appointments).To(HaveLen(1))
+
+		fetched, _ := store.GetAppointment(s.ID, a.ID)
+		Expect(fetched.StartTime.Format(time.RFC3339)).To(Equal("2019-06-03T09:00:00-06:00"))
+
+		_, err = store.UpdateSchedule(Schedule{ID: s.ID + 1, TimeZone: "UTC"})
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should save a schedule's working hours", func() {
+		hours := &WorkingHours{
+			TimeZone: "America/Denver",
+			Weekly:   map[string][]TimeRange{"monday": {{Start: TimeOfDay{Hour: 9}, End: TimeOfDay{Hour: 17, Minute: 30}}}},
+			Overrides: []DateOverride{
+				{Date: "2019-12-25", Ranges: []TimeRange{}},
+			},
+		}
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC", WorkingHours: hours})
+
+		fetched, _ := store.GetSchedule(s.ID)
+		Expect(fetched.WorkingHours).To(Equal(hours))
+
+		s.WorkingHours = nil
+		store.UpdateSchedule(s)
+
+		fetched, _ = store.GetSchedule(s.ID)
+		Expect(fetched.WorkingHours).To(BeNil())
+	})
+
+	It("Should save holds and sweep them once expired", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		expiring, err := store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2019, 6, 4, 9, 0, 0, 0, s.Location())),
+			EndTime:    NewTimestamp(time.Date(2019, 6, 4, 10, 0, 0, 0, s.Location())),
+			ExpiresAt:  NewTimestamp(now.In(s.Location())),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		lasting, _ := store.CreateHold(Hold{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, s.Location())),
+			EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, s.Location())),
+			ExpiresAt:  NewTimestamp(now.Add(time.Minute).In(s.Location())),
+		})
+
+		fetched, _ := store.GetSchedule(s.ID)
+		Expect(fetched.Version).To(Equal(3))
+		Expect(fetched.Holds).To(HaveLen(2))
+		Expect(fetched.Holds[0].ID).To(Equal(lasting.ID))
+		Expect(fetched.Holds[0].StartTime.Equal(lasting.StartTime.Time)).To(BeTrue())
+		Expect(fetched.Holds[0].ExpiresAt.Equal(lasting.ExpiresAt.Time)).To(BeTrue())
+		Expect(fetched.Holds[0].StartTime.Location().String()).To(Equal("America/Denver"))
+
+		deleted, err := store.DeleteExpiredHolds(now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(Equal(1))
+
+		fetched, _ = store.GetSchedule(s.ID)
+		Expect(fetched.Version).To(Equal(4))
+		Expect(fetched.Holds).To(HaveLen(1))
+		Expect(fetched.Holds[0].ID).To(Equal(lasting.ID))
+
+		_, err = store.DeleteHold(s.ID, expiring.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+		_, err = store.DeleteHold(s.ID, lasting.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		fetched, _ = store.GetSchedule(s.ID)
+		Expect(fetched.Holds).To(BeEmpty())
+	})
+
+	It("Should save blackouts on schedules and the calendars they subscribe to", func() {
+		day := time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC)
+		rule, _ := ParseRecurrenceRule("FREQ=YEARLY")
+		c, _ := store.CreateCalendar(Calendar{Name: "Westeros", TimeZone: "UTC"})
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC", CalendarIDs: []int{c.ID}})
+
+		own, err := store.CreateBlackout(Blackout{
+			ScheduleID: s.ID, StartTime: NewTimestamp(day.AddDate(0, 0, 1)), EndTime: NewTimestamp(day.AddDate(0, 0, 2)),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		holiday, err := store.CreateBlackout(Blackout{
+			CalendarID: c.ID, StartTime: NewTimestamp(day), EndTime: NewTimestamp(day.AddDate(0, 0, 1)),
+			Recurrence: rule, Reason: "Name day",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		fetched, _ := store.GetSchedule(s.ID)
+		Expect(fetched.Version).To(Equal(3))
+		Expect(fetched.CalendarIDs).To(Equal([]int{c.ID}))
+		Expect(fetched.Blackouts).To(HaveLen(2))
+		Expect(fetched.Blackouts[0].ID).To(Equal(holiday.ID))
+		Expect(fetched.Blackouts[0].Recurrence).To(Equal(rule))
+		Expect(fetched.Blackouts[0].Reason).To(Equal("Name day"))
+		Expect(fetched.Blackouts[1].ID).To(Equal(own.ID))
+		Expect(fetched.Blackouts[1].EndTime.Equal(day.AddDate(0, 0, 2))).To(BeTrue())
+
+		calendar, _ := store.GetCalendar(c.ID)
+		Expect(calendar.Blackouts).To(HaveLen(1))
+
+		deleted, err := store.DeleteBlackout(Blackout{ID: own.ID, ScheduleID: s.ID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted.ID).To(Equal(own.ID))
+		_, err = store.DeleteBlackout(Blackout{ID: own.ID, ScheduleID: s.ID})
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+		_, err = store.DeleteBlackout(Blackout{ID: holiday.ID, ScheduleID: s.ID})
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+
+		_, err = store.DeleteCalendar(c.ID)
+		Expect(err).NotTo(HaveOccurred())
+		fetched, _ = store.GetSchedule(s.ID)
+		Expect(fetched.CalendarIDs).To(BeEmpty())
+		Expect(fetched.Blackouts).To(BeEmpty())
+		_, err = store.GetCalendar(c.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should save a schedule's booking policy", func() {
+		policy := BookingPolicy{
+			MinDurationMinutes: 15, MaxDurationMinutes: 120, GranularityMinutes: 15, MinLeadTimeMinutes: 60, MaxAdvanceDays: 90,
+		}
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC", BookingPolicy: policy})
+
+		fetched, _ := store.GetSchedule(s.ID)
+		Expect(fetched.BookingPolicy).To(Equal(policy))
+
+		s.BookingPolicy = BookingPolicy{}
+		store.UpdateSchedule(s)
+
+		fetched, _ = store.GetSchedule(s.ID)
+		Expect(fetched.BookingPolicy).To(BeZero())
+	})
+
+	It("Should save a schedule's overlap policy", func() {
+		policy := OverlapPolicy{Intervals: HalfOpenIntervals, BufferBeforeMinutes: 10, BufferAfterMinutes: 5}
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "UTC", OverlapPolicy: policy})
+
+		fetched, _ := store.GetSchedule(s.ID)
+		Expect(fetched.OverlapPolicy).To(Equal(policy))
+
+		s.OverlapPolicy = OverlapPolicy{Intervals: ClosedIntervals}
+		store.UpdateSchedule(s)
+
+		fetched, _ = store.GetSchedule(s.ID)
+		Expect(fetched.OverlapPolicy).To(Equal(OverlapPolicy{Intervals: ClosedIntervals}))
+	})
+
+	It("Should delete a schedule along with its appointments", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		a, _ := store.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(5), EndTime: UnixTimestamp(9)})
+
+		deleted, err := store.DeleteSchedule(s.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted.Appointments).To(Equal(map[int]Appointment{a.ID: a}))
+
+		_, err = store.GetSchedule(s.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+		_, err = store.GetAppointment(s.ID, a.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should not expose stored appointments to mutation through a returned schedule", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		a, _ := store.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(5), EndTime: UnixTimestamp(9)})
+
+		fetched, _ := store.GetSchedule(s.ID)
+		delete(fetched.Appointments, a.ID)
+
+		appointments, _ := store.ListAppointments(s.ID)
+		Expect(appointments).To(Equal([]Appointment{a}))
+	})
+
+	It("Should return a NotFoundError for appointments on a missing schedule", func() {
+		_, err := store.CreateAppointment(Appointment{ScheduleID: 42, StartTime: UnixTimestamp(5), EndTime: UnixTimestamp(9)})
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+
+		_, err = store.GetAppointment(42, 1)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+
+		_, err = store.DeleteAppointment(42, 1)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+
+		_, err = store.ListAppointments(42)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should return a NotFoundError for a missing appointment on an existing schedule", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+
+		_, err := store.GetAppointment(s.ID, 42)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+
+		_, err = store.DeleteAppointment(s.ID, 42)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should list appointments sorted by start time", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		late, _ := store.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(20), EndTime: UnixTimestamp(25)})
+		early, _ := store.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(5), EndTime: UnixTimestamp(9)})
+
+		appointments, err := store.ListAppointments(s.ID)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(appointments).To(Equal([]Appointment{early, late}))
+	})
+
+	It("Should filter, sort and page through schedules", func() {
+		for _, name := range []string{"Tyrion Lannister", "Arya Stark", "Tywin Lannister", "tyrion lannister", "Tyrion Lannister"} {
+			store.CreateSchedule(Schedule{OwnerName: name, TimeZone: "UTC"})
+		}
+
+		ownerNames := func(schedules []Schedule) (names []string) {
+			for _, s := range schedules {
+				names = append(names, s.OwnerName)
+			}
+			return
+		}
+
+		page, total, err := store.ListSchedules(ScheduleQuery{SortBy: SortByID, Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(5))
+		Expect(page[0].ID).To(BeNumerically("<", page[1].ID))
+		Expect(page[0].TimeZone).To(Equal("UTC"))
+		Expect(page[0].Appointments).To(BeEmpty())
+
+		page, total, _ = store.ListSchedules(ScheduleQuery{OwnerName: "Tyrion Lannister", SortBy: SortByID, Limit: 10})
+		Expect(total).To(Equal(2))
+		Expect(ownerNames(page)).To(Equal([]string{"Tyrion Lannister", "Tyrion Lannister"}))
+
+		page, total, _ = store.ListSchedules(ScheduleQuery{OwnerNamePrefix: "Ty", SortBy: SortByOwnerName, Descending: true, Limit: 2})
+		Expect(total).To(Equal(3))
+		Expect(ownerNames(page)).To(Equal([]string{"Tywin Lannister", "Tyrion Lannister"}))
+
+		q := ScheduleQuery{OwnerNamePrefix: "Ty", SortBy: SortByOwnerName, Descending: true, Limit: 2}
+		q.After = &ScheduleCursor{SortBy: SortByOwnerName, Descending: true, OwnerName: page[1].OwnerName, ID: page[1].ID}
+		next, total, _ := store.ListSchedules(q)
+		Expect(total).To(Equal(3))
+		Expect(next).To(HaveLen(1))
+		Expect(next[0].OwnerName).To(Equal("Tyrion Lannister"))
+		Expect(next[0].ID).To(BeNumerically("<", page[1].ID))
+	})
+
+	It("Should list only appointments that can intersect a window", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		day := time.Date(2019, 6, 3, 0, 0, 0, 0, time.UTC)
+		rule, _ := ParseRecurrenceRule("FREQ=DAILY")
+		for _, a := range []Appointment{
+			{StartTime: NewTimestamp(day.Add(-2 * time.Hour)), EndTime: NewTimestamp(day.Add(-time.Hour))},
+			{StartTime: NewTimestamp(day.Add(-time.Hour)), EndTime: NewTimestamp(day.Add(time.Hour))},
+			{StartTime: NewTimestamp(day.Add(-48 * time.Hour)), EndTime: NewTimestamp(day.Add(-47 * time.Hour)), Recurrence: rule},
+			{StartTime: NewTimestamp(day.Add(25 * time.Hour)), EndTime: NewTimestamp(day.Add(26 * time.Hour))},
+		} {
+			a.ScheduleID = s.ID
+			store.CreateAppointment(a)
+		}
+
+		between, err := store.ListAppointmentsBetween(s.ID, day, day.Add(24*time.Hour))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(between).To(HaveLen(2))
+		Expect(between[0].Recurrence).NotTo(BeNil())
+		Expect(between[1].EndTime.Equal(day.Add(time.Hour))).To(BeTrue())
+
+		partial, err := store.GetScheduleBetween(s.ID, day, day.Add(24*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(partial.OwnerName).To(Equal("Tyrion Lannister"))
+		Expect(partial.Appointments).To(HaveLen(2))
+		Expect(partial.Appointments).To(HaveKey(between[0].ID))
+		Expect(partial.Appointments).To(HaveKey(between[1].ID))
+	})
+
+	It("Should allocate unique appointment IDs under concurrent inserts", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+
+		var wg sync.WaitGroup
+		ids := make([]int, 20)
+		for i := range ids {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer GinkgoRecover()
+				a, err := store.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(int64(i*10 + 1)), EndTime: UnixTimestamp(int64(i*10 + 5))})
+				Expect(err).NotTo(HaveOccurred())
+				ids[i] = a.ID
+			}(i)
+		}
+		wg.Wait()
+
+		seen := make(map[int]bool)
+		for _, id := range ids {
+			Expect(seen[id]).To(BeFalse())
+			seen[id] = true
+		}
+	})
+
+	It("Should isolate check-then-insert inside Atomically from concurrent writers", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		errBooked := errors.New("already booked")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store.Atomically([]int{s.ID}, func(tx Store) error {
+					existing, err := tx.ListAppointments(s.ID)
+					if err != nil {
+						return err
+					}
+					if len(existing) > 0 {
+						return errBooked
+					}
+					_, err = tx.CreateAppointment(Appointment{ScheduleID: s.ID, StartTime: UnixTimestamp(5), EndTime: UnixTimestamp(9)})
+					return err
+				})
+			}()
+		}
+		wg.Wait()
+
+		appointments, _ := store.ListAppointments(s.ID)
+		Expect(appointments).To(HaveLen(1))
+	})
+
+	It("Should round-trip RFC 3339 appointments in the schedule's time zone", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		denver := s.Location()
+		a, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, denver)),
+			EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, denver)),
+		})
+
+		fetched, err := store.GetAppointment(s.ID, a.ID)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.StartTime.Legacy).To(BeFalse())
+		Expect(fetched.StartTime.Equal(a.StartTime.Time)).To(BeTrue())
+		Expect(fetched.EndTime.Equal(a.EndTime.Time)).To(BeTrue())
+		Expect(fetched.StartTime.Location().String()).To(Equal("America/Denver"))
+	})
+
+	It("Should round-trip recurrence rules", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		rule, _ := ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20190801T000000Z")
+		a, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+			Recurrence: rule,
+		})
+
+		fetched, err := store.GetAppointment(s.ID, a.ID)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.Recurrence).To(Equal(rule))
+	})
+
+	It("Should round-trip appointment details", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		a, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+			AppointmentDetails: AppointmentDetails{
+				Title:       "Small council",
+				Description: "Debts to the Iron Bank",
+				Location:    "Red Keep",
+				Attributes:  map[string]json.RawMessage{"room": json.RawMessage(`"tower of the hand"`)},
+			},
+		})
+
+		fetched, err := store.GetAppointment(s.ID, a.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.AppointmentDetails).To(Equal(a.AppointmentDetails))
+
+		fetched.Attributes = nil
+		fetched.Title = "Feast"
+		_, err = store.UpdateAppointment(fetched)
+		Expect(err).NotTo(HaveOccurred())
+
+		fetched, _ = store.GetAppointment(s.ID, a.ID)
+		Expect(fetched.Title).To(Equal("Feast"))
+		Expect(fetched.Attributes).To(BeEmpty())
+	})
+
+	It("Should default appointments to confirmed and round-trip status changes", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		a, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)),
+			EndTime:    NewTimestamp(time.Date(2019, 6, 3, 10, 0, 0, 0, time.UTC)),
+		})
+		Expect(a.Status).To(Equal(StatusConfirmed))
+
+		changedAt := NewTimestamp(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+		a.Status = StatusCancelled
+		a.StatusChanges = []StatusChange{{Status: StatusCancelled, Reason: "Called away", ChangedAt: changedAt}}
+		_, err := store.UpdateAppointment(a)
+		Expect(err).NotTo(HaveOccurred())
+
+		fetched, _ := store.GetAppointment(s.ID, a.ID)
+		Expect(fetched.Status).To(Equal(StatusCancelled))
+		Expect(fetched.StatusChanges).To(HaveLen(1))
+		Expect(fetched.StatusChanges[0].Reason).To(Equal("Called away"))
+		Expect(fetched.StatusChanges[0].ChangedAt.Equal(changedAt.Time)).To(BeTrue())
+	})
+
+	It("Should update appointments and round-trip exceptions and series links", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		start := time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+		rule, _ := ParseRecurrenceRule("FREQ=DAILY")
+		series, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+			Recurrence: rule,
+		})
+
+		series.Exceptions = []Timestamp{NewTimestamp(start.AddDate(0, 0, 1))}
+		_, err := store.UpdateAppointment(series)
+		Expect(err).NotTo(HaveOccurred())
+
+		recurrenceID := NewTimestamp(start.AddDate(0, 0, 1))
+		override, _ := store.CreateAppointment(Appointment{
+			ScheduleID:   s.ID,
+			StartTime:    NewTimestamp(start.AddDate(0, 0, 1).Add(2 * time.Hour)),
+			EndTime:      NewTimestamp(start.AddDate(0, 0, 1).Add(3 * time.Hour)),
+			SeriesID:     series.ID,
+			RecurrenceID: &recurrenceID,
+		})
+
+		fetched, _ := store.GetAppointment(s.ID, series.ID)
+		Expect(fetched.Exceptions).To(HaveLen(1))
+		Expect(fetched.Exceptions[0].Equal(start.AddDate(0, 0, 1))).To(BeTrue())
+
+		fetched, _ = store.GetAppointment(s.ID, override.ID)
+		Expect(fetched.SeriesID).To(Equal(series.ID))
+		Expect(fetched.RecurrenceID.Equal(recurrenceID.Time)).To(BeTrue())
+	})
+
+	It("Should return a NotFoundError when updating a missing appointment", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+
+		_, err := store.UpdateAppointment(Appointment{ID: 42, ScheduleID: s.ID})
+
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should move an appointment to another schedule, keeping its ID and meeting", func() {
+		tyrion, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		arya, _ := store.CreateSchedule(Schedule{OwnerName: "Arya Stark"})
+		sansa, _ := store.CreateSchedule(Schedule{OwnerName: "Sansa Stark"})
+		start := time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+
+		m, _ := store.CreateMeeting(Meeting{ScheduleIDs: []int{tyrion.ID, arya.ID}})
+		for _, scheduleID := range []int{tyrion.ID, arya.ID} {
+			store.CreateAppointment(Appointment{
+				ScheduleID: scheduleID,
+				StartTime:  NewTimestamp(start),
+				EndTime:    NewTimestamp(start.Add(time.Hour)),
+				MeetingID:  m.ID,
+			})
+		}
+		appointments, _ := store.ListAppointments(arya.ID)
+
+		moved := appointments[0]
+		moved.ScheduleID = sansa.ID
+		_, err := store.MoveAppointment(moved, arya.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = store.GetAppointment(arya.ID, moved.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+		fetched, err := store.GetAppointment(sansa.ID, moved.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.MeetingID).To(Equal(m.ID))
+
+		meeting, _ := store.GetMeeting(m.ID)
+		Expect(meeting.ScheduleIDs).To(Equal([]int{tyrion.ID, sansa.ID}))
+
+		_, err = store.MoveAppointment(moved, arya.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+
+	It("Should count versions of schedules and their appointments", func() {
+		s, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		Expect(s.Version).To(Equal(1))
+		start := time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+
+		a, _ := store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+		})
+		Expect(a.Version).To(Equal(1))
+
+		a.EndTime = NewTimestamp(start.Add(2 * time.Hour))
+		a.Version = 42
+		updated, _ := store.UpdateAppointment(a)
+		Expect(updated.Version).To(Equal(2))
+
+		fetched, _ := store.GetAppointment(s.ID, a.ID)
+		Expect(fetched.Version).To(Equal(2))
+
+		s.TimeZone = "America/Denver"
+		s, _ = store.UpdateSchedule(s)
+		Expect(s.Version).To(Equal(4))
+		Expect(s.Appointments[a.ID].Version).To(Equal(3))
+
+		store.DeleteAppointment(s.ID, a.ID)
+		s, _ = store.GetSchedule(s.ID)
+		Expect(s.Version).To(Equal(5))
+	})
+
+	It("Should find and delete meetings along with their appointments", func() {
+		tyrion, _ := store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister"})
+		arya, _ := store.CreateSchedule(Schedule{OwnerName: "Arya Stark"})
+		start := time.Date(2019, 6, 3, 9, 0, 0, 0, time.UTC)
+
+		m, err := store.CreateMeeting(Meeting{ScheduleIDs: []int{arya.ID, tyrion.ID}})
+		Expect(err).NotTo(HaveOccurred())
+		for _, scheduleID := range []int{arya.ID, tyrion.ID} {
+			store.CreateAppointment(Appointment{
+				ScheduleID: scheduleID,
+				StartTime:  NewTimestamp(start),
+				EndTime:    NewTimestamp(start.Add(time.Hour)),
+				MeetingID:  m.ID,
+			})
+		}
+		other, _ := store.CreateAppointment(Appointment{
+			ScheduleID: tyrion.ID,
+			StartTime:  NewTimestamp(start.Add(2 * time.Hour)),
+			EndTime:    NewTimestamp(start.Add(3 * time.Hour)),
+		})
+
+		fetched, err := store.GetMeeting(m.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.ScheduleIDs).To(Equal([]int{tyrion.ID, arya.ID}))
+		Expect(fetched.Appointments).To(HaveLen(2))
+		Expect(fetched.Appointments[0].MeetingID).To(Equal(m.ID))
+		Expect(fetched.StartTime.Equal(start)).To(BeTrue())
+
+		deleted, err := store.DeleteMeeting(m.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted.Appointments).To(HaveLen(2))
+
+		appointments, _ := store.ListAppointments(tyrion.ID)
+		Expect(appointments).To(HaveLen(1))
+		Expect(appointments[0].ID).To(Equal(other.ID))
+
+		_, err = store.GetMeeting(m.ID)
+		Expect(err).To(BeAssignableToTypeOf(http_helpers.NotFoundError{}))
+	})
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is an appointment boundary. It is exchanged as an RFC 3339 string.
+//
+// For compatibility with clients written against the original integer API, a
+// bare JSON integer is also accepted and read as seconds since the Unix epoch.
+// Such timestamps are flagged Legacy and are written back out as integers, so
+// those clients never see a format they do not understand. The integer 0 keeps
+// its historical meaning of "no time given".
+type Timestamp struct {
+	time.Time
+	Legacy bool
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// UnixTimestamp builds a Legacy timestamp from integer seconds.
+func UnixTimestamp(sec int64) Timestamp {
+	if sec == 0 {
+		return Timestamp{Legacy: true}
+	}
+
+	return Timestamp{Time: time.Unix(sec, 0).UTC(), Legacy: true}
+}
+
+// ParseTimestamp reads either an RFC 3339 string or integer Unix seconds, as
+// found in query parameters.
+func ParseTimestamp(value string) (Timestamp, error) {
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return UnixTimestamp(sec), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q: expected RFC 3339 or Unix seconds", value)
+	}
+
+	return NewTimestamp(t), nil
+}
+
+// In returns the timestamp in loc, truncated to the second precision that
+// appointments are stored with. Legacy timestamps stay in UTC.
+func (t Timestamp) In(loc *time.Location) Timestamp {
+	if t.IsZero() {
+		return t
+	}
+
+	t.Time = t.Time.Truncate(time.Second)
+	if !t.Legacy {
+		t.Time = t.Time.In(loc)
+	}
+
+	return t
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.Legacy {
+		if t.IsZero() {
+			return []byte("0"), nil
+		}
+		return []byte(strconv.FormatInt(t.Unix(), 10)), nil
+	}
+
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(t.Format(time.RFC3339))
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	if bytes.Equal(data, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %v: expected RFC 3339", string(data))
+		}
+
+		*t = NewTimestamp(parsed)
+		return nil
+	}
+
+	sec, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %v: expected RFC 3339 string or integer Unix seconds", string(data))
+	}
+
+	*t = UnixTimestamp(sec)
+	return nil
+}
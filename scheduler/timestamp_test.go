@@ -0,0 +1,82 @@
+package scheduler_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Timestamp", func() {
+	Context("#UnmarshalJSON", func() {
+		It("Should parse RFC 3339 strings", func() {
+			var ts Timestamp
+			err := json.Unmarshal([]byte(`"2019-06-03T09:30:00-06:00"`), &ts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ts.Legacy).To(BeFalse())
+			Expect(ts.Equal(time.Date(2019, 6, 3, 15, 30, 0, 0, time.UTC))).To(BeTrue())
+		})
+
+		It("Should parse integers as legacy Unix seconds", func() {
+			var ts Timestamp
+			err := json.Unmarshal([]byte(`1559575800`), &ts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ts).To(Equal(UnixTimestamp(1559575800)))
+			Expect(ts.Legacy).To(BeTrue())
+		})
+
+		It("Should treat the integer 0 as an unset time", func() {
+			var ts Timestamp
+			err := json.Unmarshal([]byte(`0`), &ts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ts.IsZero()).To(BeTrue())
+		})
+
+		It("Should reject values that are neither RFC 3339 nor integers", func() {
+			var ts Timestamp
+			Expect(json.Unmarshal([]byte(`true`), &ts)).NotTo(Succeed())
+			Expect(json.Unmarshal([]byte(`"next tuesday"`), &ts)).NotTo(Succeed())
+			Expect(json.Unmarshal([]byte(`5.5`), &ts)).NotTo(Succeed())
+		})
+	})
+
+	Context("#MarshalJSON", func() {
+		It("Should write RFC 3339 with the timestamp's offset", func() {
+			denver, _ := time.LoadLocation("America/Denver")
+			ts := NewTimestamp(time.Date(2019, 6, 3, 9, 30, 0, 0, denver))
+
+			out, err := json.Marshal(ts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(`"2019-06-03T09:30:00-06:00"`))
+		})
+
+		It("Should write legacy timestamps back out as integers", func() {
+			out, err := json.Marshal(UnixTimestamp(5))
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(`5`))
+		})
+	})
+
+	Context("#ParseTimestamp", func() {
+		It("Should accept RFC 3339 and Unix seconds", func() {
+			ts, err := ParseTimestamp("2019-06-03T09:30:00Z")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ts.Equal(time.Date(2019, 6, 3, 9, 30, 0, 0, time.UTC))).To(BeTrue())
+
+			ts, err = ParseTimestamp("42")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ts).To(Equal(UnixTimestamp(42)))
+
+			_, err = ParseTimestamp("blamo")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,345 @@
+package scheduler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/ckaminer/schedule-api/scheduler"
+)
+
+var _ = Describe("Update Handlers", func() {
+	var (
+		store *MemoryStore
+		h     *Handler
+		s     Schedule
+		a     Appointment
+		start time.Time
+	)
+
+	request := func(method, body string, params map[string]string) *http.Request {
+		r, _ := http.NewRequest(method, "/", bytes.NewReader([]byte(body)))
+		if method == "PATCH" {
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+
+		rctx := chi.NewRouteContext()
+		for key, value := range params {
+			rctx.URLParams.Add(key, value)
+		}
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	scheduleRequest := func(method, body string) *http.Request {
+		return request(method, body, map[string]string{"scheduleID": strconv.Itoa(s.ID)})
+	}
+
+	appointmentRequest := func(method, body string) *http.Request {
+		return request(method, body, map[string]string{
+			"scheduleID":    strconv.Itoa(s.ID),
+			"appointmentID": strconv.Itoa(a.ID),
+		})
+	}
+
+	BeforeEach(func() {
+		store = NewMemoryStore()
+		h = NewHandler(store)
+
+		s, _ = store.CreateSchedule(Schedule{OwnerName: "Tyrion Lannister", TimeZone: "America/Denver"})
+		start = time.Date(2019, 6, 3, 9, 0, 0, 0, s.Location())
+		a, _ = store.CreateAppointment(Appointment{
+			ScheduleID: s.ID,
+			StartTime:  NewTimestamp(start),
+			EndTime:    NewTimestamp(start.Add(time.Hour)),
+		})
+	})
+
+	Context("Schedules", func() {
+		It("Should replace the owner name and time zone with PUT", func() {
+			recorder := httptest.NewRecorder()
+			r := scheduleRequest("PUT", `{"owner_name": "Tyrion, Hand of the Queen"}`)
+
+			http.HandlerFunc(h.UpdateScheduleHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resBody ScheduleResponse
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody.ID).To(Equal(s.ID))
+			Expect(resBody.OwnerName).To(Equal("Tyrion, Hand of the Queen"))
+			Expect(resBody.TimeZone).To(Equal("UTC"))
+			Expect(resBody.Appointments).To(HaveLen(1))
+		})
+
+		It("Should only change the patched members with PATCH", func() {
+			recorder := httptest.NewRecorder()
+			r := scheduleRequest("PATCH", `{"owner_name": "Tyrion, Hand of the Queen"}`)
+
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.OwnerName).To(Equal("Tyrion, Hand of the Queen"))
+			Expect(stored.TimeZone).To(Equal("America/Denver"))
+		})
+
+		It("Should reset the time zone when it is removed with PATCH", func() {
+			recorder := httptest.NewRecorder()
+			r := scheduleRequest("PATCH", `{"time_zone": null}`)
+
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.TimeZone).To(Equal("UTC"))
+		})
+
+		It("Should return a StatusUnprocessableEntity when changing read-only members or the time zone is unknown", func() {
+			for _, body := range []string{
+				`{"id": 42}`,
+				`{"appointments": []}`,
+				`{"time_zone": "Westeros/Kings_Landing"}`,
+			} {
+				recorder := httptest.NewRecorder()
+
+				http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", body))
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), body)
+			}
+
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.OwnerName).To(Equal("Tyrion Lannister"))
+		})
+
+		It("Should accept its own representation back with PUT", func() {
+			recorder := httptest.NewRecorder()
+			stored, _ := store.GetSchedule(s.ID)
+			current, _ := json.Marshal(stored)
+
+			http.HandlerFunc(h.UpdateScheduleHandler).ServeHTTP(recorder, scheduleRequest("PUT", string(current)))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("Should change the overlap policy unless existing appointments would conflict", func() {
+			store.CreateAppointment(Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(start.Add(time.Hour + time.Minute)),
+				EndTime:    NewTimestamp(start.Add(2 * time.Hour)),
+			})
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", `{"overlap_policy": {"intervals": "half_open"}}`))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			recorder = httptest.NewRecorder()
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", `{"overlap_policy": {"buffer_after_minutes": 5}}`))
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/problem+json"))
+
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.OverlapPolicy).To(Equal(OverlapPolicy{Intervals: HalfOpenIntervals}))
+
+			for _, body := range []string{`{"overlap_policy": {"intervals": "open"}}`, `{"overlap_policy": {"buffer_before_minutes": -5}}`} {
+				recorder = httptest.NewRecorder()
+				http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", body))
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), body)
+			}
+		})
+
+		It("Should set working hours with PATCH, keeping appointments already booked outside them", func() {
+			recorder := httptest.NewRecorder()
+			r := scheduleRequest("PATCH", `{"working_hours": {"weekly": {"monday": [{"start": "13:00", "end": "17:00"}]}}}`)
+
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var resBody map[string]interface{}
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody["working_hours"]).To(Equal(map[string]interface{}{
+				"weekly": map[string]interface{}{
+					"monday": []interface{}{map[string]interface{}{"start": "13:00", "end": "17:00"}},
+				},
+			}))
+			Expect(resBody["appointments"]).To(HaveLen(1))
+
+			recorder = httptest.NewRecorder()
+			http.HandlerFunc(h.PatchAppointmentHandler).ServeHTTP(recorder, appointmentRequest("PATCH", `{"end_time": "2019-06-03T10:30:00-06:00"}`))
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+			recorder = httptest.NewRecorder()
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", `{"working_hours": null}`))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.WorkingHours).To(BeNil())
+		})
+
+		It("Should return a StatusUnprocessableEntity for invalid working hours", func() {
+			for _, body := range []string{
+				`{"working_hours": {"time_zone": "Westeros/Winterfell"}}`,
+				`{"working_hours": {"weekly": {"moonday": []}}}`,
+				`{"working_hours": {"weekly": {"monday": [{"start": "17:00", "end": "09:00"}]}}}`,
+				`{"working_hours": {"weekly": {"monday": [{"start": "09:00", "end": "12:00"}, {"start": "11:00", "end": "13:00"}]}}}`,
+				`{"working_hours": {"overrides": [{"date": "2019-02-30", "ranges": []}]}}`,
+				`{"working_hours": {"overrides": [{"date": "2019-12-25"}, {"date": "2019-12-25"}]}}`,
+			} {
+				recorder := httptest.NewRecorder()
+
+				http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", body))
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), body)
+			}
+		})
+
+		It("Should set a booking policy with PATCH and reject invalid ones", func() {
+			recorder := httptest.NewRecorder()
+			r := scheduleRequest("PATCH", `{"booking_policy": {"min_duration_minutes": 15, "max_duration_minutes": 120, "granularity_minutes": 15}}`)
+
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			stored, _ := store.GetSchedule(s.ID)
+			Expect(stored.BookingPolicy).To(Equal(BookingPolicy{MinDurationMinutes: 15, MaxDurationMinutes: 120, GranularityMinutes: 15}))
+			Expect(stored.Appointments).To(HaveLen(1))
+
+			for _, body := range []string{
+				`{"booking_policy": {"min_duration_minutes": -1}}`,
+				`{"booking_policy": {"min_duration_minutes": 180}}`,
+				`{"booking_policy": {"granularity_minutes": 7}}`,
+				`{"booking_policy": {"min_lead_time_minutes": 1000000}}`,
+				`{"booking_policy": {"max_advance_days": -3}}`,
+			} {
+				recorder = httptest.NewRecorder()
+
+				http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, scheduleRequest("PATCH", body))
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), body)
+			}
+		})
+
+		It("Should return a StatusUnsupportedMediaType for a PATCH that is not a merge patch", func() {
+			recorder := httptest.NewRecorder()
+			r := scheduleRequest("PATCH", `[{"op": "replace", "path": "/owner_name", "value": "Tywin"}]`)
+			r.Header.Set("Content-Type", "application/json-patch+json")
+
+			http.HandlerFunc(h.PatchScheduleHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+	})
+
+	Context("Appointments", func() {
+		It("Should move an appointment with PATCH, keeping its ID, even when overlapping its old time", func() {
+			recorder := httptest.NewRecorder()
+			r := appointmentRequest("PATCH", `{"end_time": "2019-06-03T10:30:00-06:00"}`)
+
+			http.HandlerFunc(h.PatchAppointmentHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			stored, _ := store.GetAppointment(s.ID, a.ID)
+			Expect(stored.StartTime.Equal(start)).To(BeTrue())
+			Expect(stored.EndTime.Equal(start.Add(90 * time.Minute))).To(BeTrue())
+		})
+
+		It("Should replace the times and recurrence with PUT", func() {
+			recorder := httptest.NewRecorder()
+			r := appointmentRequest("PUT", `
+				{
+					"start_time": "2019-06-04T15:00:00Z",
+					"end_time": "2019-06-04T16:00:00Z",
+					"recurrence": "FREQ=DAILY;COUNT=3"
+				}
+			`)
+
+			http.HandlerFunc(h.UpdateAppointmentHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var resBody map[string]interface{}
+			json.NewDecoder(recorder.Body).Decode(&resBody)
+			Expect(resBody["id"]).To(BeEquivalentTo(a.ID))
+			Expect(resBody["start_time"]).To(Equal("2019-06-04T09:00:00-06:00"))
+			Expect(resBody["recurrence"]).To(Equal("FREQ=DAILY;COUNT=3"))
+		})
+
+		It("Should return a StatusUnprocessableEntity and keep the appointment when the new time conflicts", func() {
+			store.CreateAppointment(Appointment{
+				ScheduleID: s.ID,
+				StartTime:  NewTimestamp(start.Add(2 * time.Hour)),
+				EndTime:    NewTimestamp(start.Add(3 * time.Hour)),
+			})
+
+			recorder := httptest.NewRecorder()
+			r := appointmentRequest("PATCH", `{"end_time": "2019-06-03T11:00:00-06:00"}`)
+
+			http.HandlerFunc(h.PatchAppointmentHandler).ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+
+			stored, _ := store.GetAppointment(s.ID, a.ID)
+			Expect(stored.EndTime.Equal(start.Add(time.Hour))).To(BeTrue())
+		})
+
+		It("Should only apply the booking policy to the times that change", func() {
+			s.BookingPolicy = BookingPolicy{MinDurationMinutes: 30, GranularityMinutes: 15, MinLeadTimeMinutes: 60}
+			store.UpdateSchedule(s)
+
+			patch := func(body string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				http.HandlerFunc(h.PatchAppointmentHandler).ServeHTTP(recorder, appointmentRequest("PATCH", body))
+				return recorder
+			}
+
+			Expect(patch(`{"recurrence": "FREQ=DAILY;COUNT=2"}`).Code).To(Equal(http.StatusOK))
+			Expect(patch(`{"end_time": "2019-06-03T10:10:00-06:00"}`).Code).To(Equal(http.StatusUnprocessableEntity))
+			Expect(patch(`{"end_time": "2019-06-03T10:30:00-06:00"}`).Code).To(Equal(http.StatusOK))
+
+			recorder := patch(`{"start_time": "2019-06-03T09:15:00-06:00"}`)
+			Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			var problem Problem
+			json.NewDecoder(recorder.Body).Decode(&problem)
+			Expect(problem.Violations).To(HaveLen(1))
+			Expect(problem.Violations[0].Rule).To(Equal(RuleMinLeadTime))
+		})
+
+		It("Should return a StatusUnprocessableEntity when changing read-only members", func() {
+			for _, body := range []string{`{"id": 42}`, `{"schedule_id": 42}`, `{"meeting_id": 42}`} {
+				recorder := httptest.NewRecorder()
+
+				http.HandlerFunc(h.PatchAppointmentHandler).ServeHTTP(recorder, appointmentRequest("PATCH", body))
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity), body)
+			}
+		})
+
+		It("Should return a StatusBadRequest for a malformed body", func() {
+			for _, body := range []string{`blamo`, `[]`, `{"start_time": "yesterday"}`} {
+				recorder := httptest.NewRecorder()
+
+				http.HandlerFunc(h.PatchAppointmentHandler).ServeHTTP(recorder, appointmentRequest("PATCH", body))
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest), body)
+			}
+		})
+
+		It("Should return a StatusNotFound for a missing appointment", func() {
+			a.ID = 42
+			recorder := httptest.NewRecorder()
+
+			http.HandlerFunc(h.UpdateAppointmentHandler).ServeHTTP(recorder, appointmentRequest("PUT", `{}`))
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
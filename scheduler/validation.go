@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Rules an appointment can break, as reported in a Violation.
+const (
+	RuleStartRequired   = "start_required"
+	RuleEndAfterStart   = "end_after_start"
+	RuleSameTimeFormat  = "same_time_format"
+	RuleUntilAfterStart = "until_after_start"
+	RuleNoSelfOverlap   = "no_self_overlap"
+	RuleNoConflict      = "no_conflict"
+
+	RuleWithinWorkingHours = "within_working_hours"
+	RuleNoBlackout         = "no_blackout"
+	RuleNoHold             = "no_hold"
+
+	RuleMinDuration = "min_duration"
+	RuleMaxDuration = "max_duration"
+	RuleGranularity = "granularity"
+	RuleMinLeadTime = "min_lead_time"
+	RuleMaxAdvance  = "max_advance"
+)
+
+// bookingPolicyRules are the rules of a schedule's BookingPolicy.
+var bookingPolicyRules = []string{RuleMinDuration, RuleMaxDuration, RuleGranularity, RuleMinLeadTime, RuleMaxAdvance}
+
+// maxReportedConflicts bounds the conflicts listed in a Violation; a series
+// can collide with hundreds of occurrences.
+const maxReportedConflicts = 20
+
+// Violation describes one rule broken by a proposed appointment and the
+// member of the appointment responsible for it.
+type Violation struct {
+	Rule      string     `json:"rule"`
+	Field     string     `json:"field"`
+	Message   string     `json:"message"`
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+
+	// BlackoutIDs lists the blackouts a no_blackout violation falls within.
+	BlackoutIDs []int `json:"blackout_ids,omitempty"`
+	// HoldIDs lists the holds a no_hold violation conflicts with.
+	HoldIDs []int `json:"hold_ids,omitempty"`
+}
+
+// Conflict identifies an existing appointment, or one occurrence of a series,
+// overlapping the proposed one.
+type Conflict struct {
+	AppointmentID int        `json:"appointment_id"`
+	RecurrenceID  *Timestamp `json:"recurrence_id,omitempty"`
+	StartTime     Timestamp  `json:"start_time"`
+	EndTime       Timestamp  `json:"end_time"`
+}
+
+// ValidationError is returned by ValidateAppointmentInput with every rule the
+// appointment breaks. Services may set Detail and Status to give context, such
+// as the schedule of a meeting attendee; Status defaults to 422.
+type ValidationError struct {
+	Detail     string
+	Status     int
+	Violations []Violation
+}
+
+func (e ValidationError) Error() string {
+	var messages []string
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+
+	return fmt.Sprintf("%v: %v", e.detail(), strings.Join(messages, "; "))
+}
+
+func (e ValidationError) detail() string {
+	if e.Detail == "" {
+		return "Invalid appointment time"
+	}
+	return e.Detail
+}
+
+func (e ValidationError) status() int {
+	if e.Status == 0 {
+		return http.StatusUnprocessableEntity
+	}
+	return e.Status
+}
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+func (e ValidationError) Problem() Problem {
+	return Problem{
+		Type:       "urn:schedule-api:problem:invalid-appointment",
+		Title:      "Appointment rejected",
+		Status:     e.status(),
+		Detail:     e.detail(),
+		Violations: e.Violations,
+	}
+}
+
+// hasOnly reports whether every violation is of one of rules.
+func (e ValidationError) hasOnly(rules ...string) bool {
+	return len(e.Violations) > 0 && e.without(rules...) == nil
+}
+
+// without returns e less its violations of the given rules, or nil when none
+// remain.
+func (e ValidationError) without(rules ...string) error {
+	var violations []Violation
+	for _, v := range e.Violations {
+		ignored := false
+		for _, rule := range rules {
+			ignored = ignored || v.Rule == rule
+		}
+		if !ignored {
+			violations = append(violations, v)
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	e.Violations = violations
+	return e
+}
+
+// conflictViolation reports the existing occurrences overlapping a proposed
+// appointment, earliest first.
+func conflictViolation(conflicts []Appointment) Violation {
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].StartTime.Before(conflicts[j].StartTime.Time)
+	})
+
+	v := Violation{
+		Rule:    RuleNoConflict,
+		Field:   "start_time",
+		Message: fmt.Sprintf("Overlaps %v existing appointment(s)", len(conflicts)),
+	}
+	for i, c := range conflicts {
+		if i == maxReportedConflicts {
+			break
+		}
+		v.Conflicts = append(v.Conflicts, Conflict{
+			AppointmentID: c.ID,
+			RecurrenceID:  c.RecurrenceID,
+			StartTime:     c.StartTime,
+			EndTime:       c.EndTime,
+		})
+	}
+
+	return v
+}
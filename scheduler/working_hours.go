@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ckaminer/go-utils/http_helpers"
+)
+
+const overrideDateLayout = "2006-01-02"
+
+var workingDays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// TimeRange is part of a day, from Start up to End.
+type TimeRange struct {
+	Start TimeOfDay `json:"start"`
+	End   TimeOfDay `json:"end"`
+}
+
+// DateOverride replaces the weekly ranges on a single date, given as
+// YYYY-MM-DD. Without any ranges the whole date is unavailable.
+type DateOverride struct {
+	Date   string      `json:"date"`
+	Ranges []TimeRange `json:"ranges"`
+}
+
+// WorkingHours limits when appointments can be booked on a schedule. Weekly
+// holds the available ranges for each day of the week, keyed by its lowercase
+// English name; days left out are unavailable. Times are wall-clock times in
+// TimeZone, which defaults to the schedule's.
+//
+// Ranges meeting at midnight join up, so a range ending at 24:00 followed by
+// one starting at 00:00 the next day allows appointments running overnight.
+type WorkingHours struct {
+	TimeZone  string                 `json:"time_zone,omitempty"`
+	Weekly    map[string][]TimeRange `json:"weekly"`
+	Overrides []DateOverride         `json:"overrides,omitempty"`
+}
+
+func (w *WorkingHours) location(s Schedule) *time.Location {
+	if w.TimeZone != "" {
+		if loc, err := time.LoadLocation(w.TimeZone); err == nil {
+			return loc
+		}
+	}
+
+	return s.Location()
+}
+
+// available returns the times within reach of [from, to) at which s can be
+// booked: its working hours less its blackouts, sorted and merged. Intervals
+// are not clipped to the window.
+func (s Schedule) available(from, to time.Time) []Interval {
+	return subtractIntervals(s.workingTime(from, to), s.blackedOut(from, to))
+}
+
+// workingTime returns the working hours of s within reach of [from, to),
+// sorted and merged, in the working hours' time zone. A schedule without
+// working hours works throughout.
+func (s Schedule) workingTime(from, to time.Time) []Interval {
+	w := s.WorkingHours
+	if w == nil {
+		return []Interval{{Start: NewTimestamp(from), End: NewTimestamp(to)}}
+	}
+
+	overrides := make(map[string][]TimeRange, len(w.Overrides))
+	for _, o := range w.Overrides {
+		overrides[o.Date] = o.Ranges
+	}
+	weekly := make(map[time.Weekday][]TimeRange, len(w.Weekly))
+	for day, ranges := range w.Weekly {
+		weekly[workingDays[day]] = ranges
+	}
+
+	// Days either side are included so that ranges running into the window
+	// across midnight are joined to it.
+	loc := w.location(s)
+	var intervals []Interval
+	for day := (TimeOfDay{}).On(from.In(loc)).AddDate(0, 0, -1); day.Before(to.Add(24 * time.Hour)); day = day.AddDate(0, 0, 1) {
+		ranges, found := overrides[day.Format(overrideDateLayout)]
+		if !found {
+			ranges = weekly[day.Weekday()]
+		}
+		for _, r := range ranges {
+			intervals = append(intervals, Interval{
+				Start: NewTimestamp(r.Start.On(day)),
+				End:   NewTimestamp(r.End.On(day)),
+			})
+		}
+	}
+
+	return mergeIntervals(intervals)
+}
+
+// unavailable returns the times within [from, to) outside s's working hours
+// or blacked out, in the schedule's time zone.
+func (s Schedule) unavailable(from, to time.Time) []Interval {
+	loc := s.Location()
+
+	var gaps []Interval
+	cursor := from
+	for _, a := range s.available(from, to) {
+		if !a.End.After(cursor) {
+			continue
+		}
+		if !a.Start.Before(to) {
+			break
+		}
+		if a.Start.After(cursor) {
+			gaps = append(gaps, Interval{Start: NewTimestamp(cursor.In(loc)), End: a.Start.In(loc)})
+		}
+		cursor = a.End.Time
+	}
+	if cursor.Before(to) {
+		gaps = append(gaps, Interval{Start: NewTimestamp(cursor.In(loc)), End: NewTimestamp(to.In(loc))})
+	}
+
+	return gaps
+}
+
+// within reports whether i lies inside one of the sorted, merged available
+// intervals.
+func within(available []Interval, i Interval) bool {
+	k := sort.Search(len(available), func(k int) bool {
+		return !available[k].End.Before(i.End.Time)
+	})
+
+	return k < len(available) && !available[k].Start.After(i.Start.Time)
+}
+
+// outsideWorkingHours describes occurrence o of a falling outside working
+// hours.
+func outsideWorkingHours(a Appointment, o Appointment) string {
+	if a.Recurrence == nil {
+		return "Appointment is outside working hours"
+	}
+
+	return fmt.Sprintf("Occurrence starting %v is outside working hours", o.StartTime.Format(time.RFC3339))
+}
+
+// checkWorkingHours validates the working hours of s, if any, and sorts their
+// ranges.
+func checkWorkingHours(s *Schedule) error {
+	w := s.WorkingHours
+	if w == nil {
+		return nil
+	}
+
+	invalid := func(format string, args ...interface{}) error {
+		return http_helpers.HttpError{
+			Message:    fmt.Sprintf(format, args...),
+			StatusCode: http.StatusUnprocessableEntity,
+		}
+	}
+
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return invalid("Unknown working_hours time_zone %q", w.TimeZone)
+	}
+
+	if w.Weekly == nil {
+		w.Weekly = make(map[string][]TimeRange)
+	}
+	for day, ranges := range w.Weekly {
+		if _, found := workingDays[day]; !found {
+			return invalid("Unknown working_hours day %q", day)
+		}
+		if !validRanges(ranges) {
+			return invalid("Invalid working_hours ranges on %v", day)
+		}
+	}
+
+	dates := make(map[string]bool, len(w.Overrides))
+	for i, o := range w.Overrides {
+		if o.Ranges == nil {
+			w.Overrides[i].Ranges = []TimeRange{}
+		}
+		if _, err := time.Parse(overrideDateLayout, o.Date); err != nil {
+			return invalid("Invalid working_hours override date %q", o.Date)
+		}
+		if dates[o.Date] {
+			return invalid("Duplicate working_hours override for %v", o.Date)
+		}
+		dates[o.Date] = true
+		if !validRanges(o.Ranges) {
+			return invalid("Invalid working_hours ranges on %v", o.Date)
+		}
+	}
+
+	return nil
+}
+
+// validRanges sorts ranges and reports whether each one ends after it starts
+// without overlapping the next.
+func validRanges(ranges []TimeRange) bool {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start.minutes() < ranges[j].Start.minutes()
+	})
+
+	for i, r := range ranges {
+		if r.Start.minutes() >= r.End.minutes() {
+			return false
+		}
+		if i > 0 && r.Start.minutes() < ranges[i-1].End.minutes() {
+			return false
+		}
+	}
+
+	return true
+}
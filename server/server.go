@@ -1,14 +1,53 @@
 package server
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/ckaminer/schedule-api/router"
+	"github.com/ckaminer/schedule-api/scheduler"
 )
 
 func StartServer() {
-	r := router.InitializeRouter()
+	store, err := newStore()
+	if err != nil {
+		log.Fatal("StartServer - unable to initialize store: ", err.Error())
+	}
+
+	h := scheduler.NewHandler(store)
+	if os.Getenv("IDEMPOTENCY_WINDOW") != "" || os.Getenv("IDEMPOTENCY_MAX_KEYS") != "" {
+		window := scheduler.DefaultIdempotencyWindow
+		if os.Getenv("IDEMPOTENCY_WINDOW") != "" {
+			window, err = time.ParseDuration(os.Getenv("IDEMPOTENCY_WINDOW"))
+			if err != nil || window <= 0 {
+				log.Fatal("StartServer - invalid IDEMPOTENCY_WINDOW: ", os.Getenv("IDEMPOTENCY_WINDOW"))
+			}
+		}
+		maxKeys := scheduler.DefaultIdempotencyMaxEntries
+		if os.Getenv("IDEMPOTENCY_MAX_KEYS") != "" {
+			maxKeys, err = strconv.Atoi(os.Getenv("IDEMPOTENCY_MAX_KEYS"))
+			if err != nil || maxKeys <= 0 {
+				log.Fatal("StartServer - invalid IDEMPOTENCY_MAX_KEYS: ", os.Getenv("IDEMPOTENCY_MAX_KEYS"))
+			}
+		}
+		h.Idempotency = scheduler.NewIdempotencyCache(window, maxKeys)
+	}
+
+	sweepInterval := time.Minute
+	if os.Getenv("HOLD_SWEEP_INTERVAL") != "" {
+		sweepInterval, err = time.ParseDuration(os.Getenv("HOLD_SWEEP_INTERVAL"))
+		if err != nil || sweepInterval <= 0 {
+			log.Fatal("StartServer - invalid HOLD_SWEEP_INTERVAL: ", os.Getenv("HOLD_SWEEP_INTERVAL"))
+		}
+	}
+	go scheduler.SweepHolds(context.Background(), store, sweepInterval)
+
+	r := router.InitializeRouter(h)
 
 	port := "8080"
 	if os.Getenv("PORT") != "" {
@@ -16,3 +55,21 @@ func StartServer() {
 	}
 	http.ListenAndServe(":"+port, r)
 }
+
+// newStore selects the storage backend from the STORE environment variable.
+// "memory" (the default) keeps data in process; "sqlite" persists it to the
+// file named by SQLITE_PATH.
+func newStore() (scheduler.Store, error) {
+	switch os.Getenv("STORE") {
+	case "", "memory":
+		return scheduler.NewMemoryStore(), nil
+	case "sqlite":
+		path := "scheduler.db"
+		if os.Getenv("SQLITE_PATH") != "" {
+			path = os.Getenv("SQLITE_PATH")
+		}
+		return scheduler.NewSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown STORE %q", os.Getenv("STORE"))
+	}
+}